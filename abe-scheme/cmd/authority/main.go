@@ -9,17 +9,24 @@ package main
 
 import (
 	"bytes"
+	"crypto/ecdsa"
+	"crypto/x509"
 	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	_ "github.com/lib/pq"
+	"github.com/pzkt/abe-scripts/abe-scheme/internal/config"
 	"github.com/pzkt/abe-scripts/abe-scheme/internal/crypto"
 	"github.com/pzkt/abe-scripts/abe-scheme/internal/utils"
 	"github.com/pzkt/abe-scripts/abe-scheme/internal/utils/policyConfig"
@@ -27,28 +34,50 @@ import (
 
 var scheme *crypto.ABEscheme
 var setup_time int64
+var cfg config.Config
 
-const databaseURL = "http://localhost:8080"
-const authorityUUID = "497dcba3-ecbf-4587-a2dd-5eb0665e6880"
+// the published policy config with an index of its purpose trees and its version, guarded by policyMutex
+var policyMutex sync.Mutex
+var published policyConfig.Config
+var configVersion int
+
+// signs the policy config entries, it stays the same for all versions
+var relationsKey *ecdsa.PrivateKey
 
 func main() {
+	cfg = utils.Assure(config.Load(os.Args[1:]))
 	setup_time = time.Now().Unix()
 
 	scheme = crypto.Setup()
-	updatePolicyConfig()
+	relationsKey = utils.Assure(loadRelationsKey(cfg.RelationsKey))
+	configVersion = utils.Assure(publishedVersion())
+	utils.Assure(updatePolicyConfig(utils.Assure(loadPurposeTrees())))
+	log.Printf("published policy config version %d\n", configVersion)
+	go watchPurposeTrees()
 
 	r := mux.NewRouter()
 	r.HandleFunc("/get_key", getKey).Methods("GET")
 	r.HandleFunc("/get_time_key", getTimestampedKey).Methods("GET")
+	r.HandleFunc("/purposes", getPurposes).Methods("GET")
+	r.HandleFunc("/purposes", editHandler(addPurpose)).Methods("POST")
+	r.HandleFunc("/purposes/move", editHandler(movePurpose)).Methods("POST")
+	r.HandleFunc("/purposes/rename", editHandler(renamePurpose)).Methods("POST")
+	r.HandleFunc("/purposes", editHandler(removePurpose)).Methods("DELETE")
 
-	log.Println("key authority server started on port :8081")
-	log.Fatal(http.ListenAndServe(":8081", r))
+	log.Printf("key authority server started on port %s\n", cfg.AuthorityAddr())
+	log.Fatal(http.ListenAndServe(cfg.AuthorityAddr(), r))
 }
 
 // request a key from the key authority. We do not go over verification or authentication of key requests for demonstration purposes
 func getKey(w http.ResponseWriter, r *http.Request) {
-	attributes := r.URL.Query()["attribute"]
-	fmt.Printf("generating key for attributes %v\n", attributes)
+	attributes := qualifyAttributes(r.URL.Query()["attribute"])
+	numeric, err := numericAttributes(r.URL.Query()["value"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	fmt.Printf("generating key for attributes %v and values %v\n", attributes, r.URL.Query()["value"])
+	attributes = append(attributes, numeric...)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(scheme.KeyGen(attributes))
@@ -56,76 +85,221 @@ func getKey(w http.ResponseWriter, r *http.Request) {
 
 // request a key from the key authority that contains timestamp attributes
 func getTimestampedKey(w http.ResponseWriter, r *http.Request) {
-	attributes := r.URL.Query()["attribute"]
-	fmt.Printf("generating timestamped key for attributes %v\n", attributes)
+	attributes := qualifyAttributes(r.URL.Query()["attribute"])
+	numeric, err := numericAttributes(r.URL.Query()["value"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	now := time.Now()
+	epochLength := time.Duration(cfg.TimeEpoch) * time.Second
+	timeAttributes, err := utils.TimeAttributes(now, epochLength)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Printf("generating timestamped key for attributes %v and values %v\n", attributes, r.URL.Query()["value"])
+	attributes = append(attributes, numeric...)
 
+	//the key only satisfies time bound policies during the current epoch
+	validUntil := utils.EpochStart(utils.Epoch(now, epochLength)+1, epochLength)
+	w.Header().Set("X-Valid-Until", validUntil.Format(time.RFC3339))
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(scheme.KeyGen(append(attributes, generateTimestamp()...)))
+	json.NewEncoder(w).Encode(scheme.KeyGen(append(attributes, timeAttributes...)))
 }
 
-// uptate the policy config entry in the database
-func updatePolicyConfig() {
+// numeric user attributes are given as name=value, e.g. clearance=3 or expiry=2025-06-30
+// the key gets one attribute per bit of the value, which policies like "clearance >= 3" compare against
+func numericAttributes(values []string) ([]string, error) {
+	out := []string{}
+	for _, v := range values {
+		name, value, found := strings.Cut(v, "=")
+		if !found || name == "" {
+			return nil, fmt.Errorf("invalid value %q, expected name=value", v)
+		}
+		if name == utils.TimeAttribute {
+			return nil, fmt.Errorf("%s is reserved for the epoch of time bound keys", name)
+		}
+		n, err := utils.ParseNumericValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("value of %s: %w", name, err)
+		}
+		out = append(out, utils.BitAttributes(name, uint(n), utils.ComparisonValueSize)...)
+	}
+	return out, nil
+}
 
-	writeKey := crypto.GenerateSignatureKey()
+// policies refer to purposes by their tree, so keys need the qualified purposes as attributes
+// a bare purpose that appears in several trees is granted in all of them
+func qualifyAttributes(attributes []string) []string {
+	policyMutex.Lock()
+	current := published
+	policyMutex.Unlock()
+
+	for _, a := range attributes {
+		if trees := current.Ambiguous(a); trees != nil {
+			log.Printf("warning: key attribute %s is ambiguous and granted for the trees %s\n", a, strings.Join(trees, ", "))
+		}
+	}
+	return current.QualifyAttributes(attributes)
+}
+
+// the configured purpose trees, or the example trees if no file is configured
+func loadPurposeTrees() ([]*utils.Tree, error) {
+	if cfg.PurposeTrees == "" {
+		log.Println("no purpose tree file configured, publishing the example purpose trees")
+		return utils.ExamplePurposeTrees(), nil
+	}
+	return utils.LoadPurposeTrees(cfg.PurposeTrees)
+}
 
+// publish the purpose trees again whenever the file changes. Invalid files are reported and the
+// previously published trees stay in place
+func watchPurposeTrees() {
+	if cfg.PurposeTrees == "" || cfg.PurposeTreesReload == 0 {
+		return
+	}
+
+	lastModified := time.Time{}
+	if info, err := os.Stat(cfg.PurposeTrees); err == nil {
+		lastModified = info.ModTime()
+	}
+
+	for range time.Tick(time.Duration(cfg.PurposeTreesReload) * time.Second) {
+		info, err := os.Stat(cfg.PurposeTrees)
+		if err != nil {
+			log.Printf("checking purpose tree file failed: %v", err)
+			continue
+		}
+		if !info.ModTime().After(lastModified) {
+			continue
+		}
+		lastModified = info.ModTime()
+
+		trees, err := utils.LoadPurposeTrees(cfg.PurposeTrees)
+		if err != nil {
+			log.Printf("keeping the published purpose trees: %v", err)
+			continue
+		}
+		policyMutex.Lock()
+		version, err := updatePolicyConfig(trees)
+		policyMutex.Unlock()
+		if err != nil {
+			log.Printf("publishing purpose trees from %s failed: %v", cfg.PurposeTrees, err)
+			continue
+		}
+		log.Printf("published purpose trees from %s as version %d", cfg.PurposeTrees, version)
+	}
+}
+
+// uptate the policy config entry in the database, every update is published as a new version
+// the caller must hold policyMutex
+func updatePolicyConfig(trees []*utils.Tree) (int, error) {
 	newPolicyConfig := policyConfig.Config{
-		PurposeTrees: utils.ExamplePurposeTrees(),
+		Version:      configVersion + 1,
+		PurposeTrees: trees,
+		Inheritance:  utils.Assure(policyConfig.ParseInheritanceMode(cfg.Inheritance)),
 		Scheme:       crypto.ABEscheme{PublicKey: scheme.PublicKey},
+		TimeEpoch:    time.Duration(cfg.TimeEpoch) * time.Second,
 	}
+	data := utils.ToBytes(newPolicyConfig)
+	authority := utils.Assure(uuid.Parse(cfg.AuthorityUUID))
 
-	createdTime := time.Now()
-	uuid := utils.Assure(uuid.Parse(authorityUUID))
+	//the versioned entry first, so the latest entry never refers to a version that does not exist
+	for _, id := range []uuid.UUID{policyConfig.VersionID(authority, newPolicyConfig.Version), authority} {
+		if err := writeRelation(id, data); err != nil {
+			return configVersion, err
+		}
+	}
 
-	publicKey := writeKey.PublicKey
+	configVersion = newPolicyConfig.Version
+	published = newPolicyConfig
+	published.BuildIndex()
+	return configVersion, nil
+}
 
-	//curve is an interface type and can't be marshaled, we remove it and the database can add it back
-	publicKey.Curve = nil
-	marshaledPublicWriteKey := utils.ToBytes(publicKey)
+// the version of the policy config currently stored in the database, 0 if none was published yet
+func publishedVersion() (int, error) {
+	resp, err := http.Get(fmt.Sprintf("%s/entries/relations/%s", cfg.DatabaseURL, cfg.AuthorityUUID))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
 
-	var checkSum bytes.Buffer
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return 0, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("get policy config failed: %s", body)
+	}
 
-	// needed for MessagePack encoding
-	/* 	for _, c := range newPolicyConfig.PurposeTrees {
-		c.DisconnectParents()
-	} */
+	var record utils.Record
+	if err := json.Unmarshal(body, &record); err != nil {
+		return 0, err
+	}
+	var latest policyConfig.Config
+	utils.FromBytes(record.Data, &latest)
+	return latest.Version, nil
+}
+
+// the database only accepts changes to an entry that are signed with its current write key,
+// so the key has to survive restarts of the authority to publish new policy config versions
+func loadRelationsKey(path string) (*ecdsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		key := crypto.GenerateSignatureKey()
+		der, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("created a new relations key in %s\n", path)
+		return key, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0o600)
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	for _, s := range [][]byte{utils.ToBytes("relations"), uuid[:], []byte{}, marshaledPublicWriteKey, utils.ToBytes(newPolicyConfig), utils.ToBytes(createdTime)} {
-		checkSum.Write(s)
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s does not contain a PEM encoded key", path)
 	}
+	return x509.ParseECPrivateKey(block.Bytes)
+}
 
-	signature := crypto.Sign(writeKey, checkSum.Bytes())
+func writeRelation(id uuid.UUID, data []byte) error {
+	publicKey := relationsKey.PublicKey
+
+	//curve is an interface type and can't be marshaled, we remove it and the database can add it back
+	publicKey.Curve = nil
+	marshaledPublicWriteKey := utils.ToBytes(publicKey)
 
 	newRecord := utils.Record{
 		Table:           "relations",
-		ID:              uuid,
+		ID:              id,
 		PrivateWriteKey: []byte{},
 		PublicWriteKey:  marshaledPublicWriteKey,
-		Data:            utils.ToBytes(newPolicyConfig),
-		Created:         createdTime,
-		Signature:       signature,
+		Data:            data,
+		Created:         time.Now(),
 	}
+	newRecord.Signature = crypto.Sign(relationsKey, newRecord.Checksum())
 
 	jsonData := utils.Assure(json.Marshal(newRecord))
-	resp := utils.Assure(http.Post(databaseURL+"/entries", "application/json", bytes.NewBuffer(jsonData)))
+	resp, err := http.Post(cfg.DatabaseURL+"/entries", "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
 	defer resp.Body.Close()
 
-	body := utils.Assure(io.ReadAll(resp.Body))
-
-	if resp.StatusCode != http.StatusOK {
-		log.Fatalf("entry add failed: %s", body)
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
 	}
-}
-
-// turn the current date to an array of attributes that represent the current timestamp
-func generateTimestamp() []string {
-	valueSize := 15
-	value := time.Now().Unix()
-
-	out := []string{}
-	for i := valueSize - 1; i >= 0; i-- {
-		// Shift and mask to get each bit
-		bit := (value >> i) & 1
-		out = append(out, strings.Repeat("*", valueSize-i-1)+fmt.Sprintf("%d", bit)+strings.Repeat("*", i))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("entry add failed: %s", body)
 	}
-	return out
+	return nil
 }
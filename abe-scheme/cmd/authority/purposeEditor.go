@@ -0,0 +1,150 @@
+/*
+
+Endpoints for editing the purpose trees while the authority is running
+Every successful edit is validated and published as a new policy config version, older versions stay in the database
+so existing ciphertexts can still be related to the trees they were encrypted under
+A change of the purpose tree file replaces all edits made through these endpoints
+
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/pzkt/abe-scripts/abe-scheme/internal/utils"
+)
+
+// a single edit, tree is the name of the purpose tree the values are looked up in, as in qualified purposes
+// such as health:Research, or the id of its root
+type purposeEdit struct {
+	Tree     string `json:"tree"`
+	Value    string `json:"value"`
+	Parent   string `json:"parent,omitempty"`
+	NewValue string `json:"new_value,omitempty"`
+}
+
+type editFunc func(trees []*utils.Tree, edit purposeEdit) error
+
+// answered with 404, all other failed edits with 400
+var errUnknownTree = errors.New("unknown purpose tree")
+
+// the published purpose trees in the purpose tree file format
+func getPurposes(w http.ResponseWriter, r *http.Request) {
+	policyMutex.Lock()
+	response := struct {
+		Version int `json:"version"`
+		utils.PurposeFile
+	}{configVersion, utils.NewPurposeFile(published.PurposeTrees)}
+	policyMutex.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// apply the edit to a copy of the published trees and publish the result if it is valid
+func editHandler(apply editFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var edit purposeEdit
+		if err := json.NewDecoder(r.Body).Decode(&edit); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		policyMutex.Lock()
+		defer policyMutex.Unlock()
+
+		trees := utils.CloneTrees(published.PurposeTrees)
+		if err := apply(trees, edit); errors.Is(err, errUnknownTree) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := utils.ValidatePurposeTrees(trees); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		version, err := updatePolicyConfig(trees)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		log.Printf("published policy config version %d\n", version)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]int{"version": version})
+	}
+}
+
+func findPurpose(trees []*utils.Tree, tree string, value string) (*utils.Tree, error) {
+	for _, t := range trees {
+		if t.TreeName() != tree && t.ID != tree {
+			continue
+		}
+		node, found := t.FindValue(value)
+		if !found {
+			return nil, fmt.Errorf("purpose %q not found in tree %q", value, tree)
+		}
+		return node, nil
+	}
+	return nil, fmt.Errorf("%w %q", errUnknownTree, tree)
+}
+
+// add value as a new child of parent
+func addPurpose(trees []*utils.Tree, edit purposeEdit) error {
+	parent, err := findPurpose(trees, edit.Tree, edit.Parent)
+	if err != nil {
+		return err
+	}
+	parent.AddChild(edit.Value)
+	return nil
+}
+
+// move value with all of its descendants below parent
+func movePurpose(trees []*utils.Tree, edit purposeEdit) error {
+	node, err := findPurpose(trees, edit.Tree, edit.Value)
+	if err != nil {
+		return err
+	}
+	parent, err := findPurpose(trees, edit.Tree, edit.Parent)
+	if err != nil {
+		return err
+	}
+	if node.Parent == nil {
+		return fmt.Errorf("the root %q can not be moved", node.Value)
+	}
+	if node.Contains(parent) {
+		return fmt.Errorf("%q can not be moved below itself or its descendant %q", node.Value, parent.Value)
+	}
+	node.MoveTo(parent)
+	return nil
+}
+
+func renamePurpose(trees []*utils.Tree, edit purposeEdit) error {
+	node, err := findPurpose(trees, edit.Tree, edit.Value)
+	if err != nil {
+		return err
+	}
+	node.Value = edit.NewValue
+	return nil
+}
+
+// remove value, its children take its place below its parent
+func removePurpose(trees []*utils.Tree, edit purposeEdit) error {
+	node, err := findPurpose(trees, edit.Tree, edit.Value)
+	if err != nil {
+		return err
+	}
+	if node.Parent == nil {
+		return fmt.Errorf("the root %q can not be removed", node.Value)
+	}
+	node.Remove()
+	return nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pzkt/abe-scripts/abe-scheme/internal/utils"
+)
+
+func TestPurposeEdits(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		apply editFunc
+		edit  purposeEdit
+		want  string
+	}{
+		{"add", addPurpose, purposeEdit{Tree: "commerce", Value: "Returns", Parent: "Shipping"}, "General-Purpose/Shipping/Returns"},
+		{"move", movePurpose, purposeEdit{Tree: "commerce", Value: "Third-Party", Parent: "Admin"}, "General-Purpose/Admin/Third-Party/Email"},
+		{"rename", renamePurpose, purposeEdit{Tree: "commerce", Value: "Third-Party", NewValue: "Partners"}, "General-Purpose/Marketing/Partners/Email"},
+		{"remove", removePurpose, purposeEdit{Tree: "commerce", Value: "Third-Party"}, "General-Purpose/Marketing/Email"},
+	} {
+		trees := utils.ExamplePurposeTrees()
+		if err := tc.apply(trees, tc.edit); err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if err := utils.ValidatePurposeTrees(trees); err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+		}
+
+		value := tc.want[strings.LastIndex(tc.want, "/")+1:]
+		node, found := trees[0].FindValue(value)
+		if !found {
+			t.Errorf("%s: %s not found", tc.name, value)
+		} else if strings.Join(node.GetRootPath(), "/") != tc.want {
+			t.Errorf("%s: expected %s, got %v", tc.name, tc.want, node.GetRootPath())
+		}
+	}
+}
+
+func TestRejectedPurposeEdits(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		apply editFunc
+		edit  purposeEdit
+	}{
+		{"unknown parent", addPurpose, purposeEdit{Tree: "commerce", Value: "A", Parent: "Nothing"}},
+		{"below descendant", movePurpose, purposeEdit{Tree: "commerce", Value: "Marketing", Parent: "Email"}},
+		{"move root", movePurpose, purposeEdit{Tree: "commerce", Value: "General-Purpose", Parent: "Admin"}},
+		{"remove root", removePurpose, purposeEdit{Tree: "commerce", Value: "General-Purpose"}},
+	} {
+		if err := tc.apply(utils.ExamplePurposeTrees(), tc.edit); err == nil {
+			t.Errorf("%s: expected an error", tc.name)
+		}
+	}
+
+	//edits that succeed but produce invalid trees are rejected by the validation before publishing
+	trees := utils.ExamplePurposeTrees()
+	if err := renamePurpose(trees, purposeEdit{Tree: "commerce", Value: "Email", NewValue: "Phone"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := utils.ValidatePurposeTrees(trees); err == nil {
+		t.Error("renaming to an existing value should produce a duplicate")
+	}
+}
+
+// a renamed purpose keeps its id, so a new purpose with its old value needs an id of its own
+func TestReAddRenamedPurpose(t *testing.T) {
+	trees := utils.ExamplePurposeTrees()
+	direct, _ := trees[0].FindValue("Direct")
+	id := direct.ID
+	if err := renamePurpose(trees, purposeEdit{Tree: "commerce", Value: "Direct", NewValue: "Direct-Mail"}); err != nil {
+		t.Fatal(err)
+	}
+
+	//every edit starts from a copy of the published trees
+	trees = utils.CloneTrees(trees)
+	if err := addPurpose(trees, purposeEdit{Tree: "commerce", Value: "Direct", Parent: "Marketing"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := utils.ValidatePurposeTrees(trees); err != nil {
+		t.Fatal(err)
+	}
+
+	renamed, _ := trees[0].FindValue("Direct-Mail")
+	added, _ := trees[0].FindValue("Direct")
+	if renamed.ID != id {
+		t.Errorf("the renamed purpose changed its id from %s to %s", id, renamed.ID)
+	}
+	if added.ID == id {
+		t.Error("the new purpose has the id of the renamed one")
+	}
+}
+
+// trees are found by their name or the id of their root, unknown trees are not found before anything is published
+func TestEditedTree(t *testing.T) {
+	trees := utils.ExamplePurposeTrees()
+	if err := addPurpose(trees, purposeEdit{Tree: trees[1].ID, Value: "Cardiology", Parent: "Health-Record"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, found := trees[1].FindValue("Cardiology"); !found {
+		t.Error("the purpose was not added to the health tree")
+	}
+
+	published.PurposeTrees = utils.ExamplePurposeTrees()
+	request := httptest.NewRequest("POST", "/purposes", strings.NewReader(`{"tree": "retail", "value": "A", "parent": "General-Purpose"}`))
+	recorder := httptest.NewRecorder()
+	editHandler(addPurpose)(recorder, request)
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("expected %d for an unknown tree, got %d: %s", http.StatusNotFound, recorder.Code, recorder.Body)
+	}
+}
@@ -0,0 +1,192 @@
+/*
+
+homomorphic aggregates of encrypted numeric fields
+The Aggregated fields of an entry are BFV encrypted with the public analytics key of its table, see crypto.EncryptAggregateValue.
+The database adds them up over the entries that match public or searchable filters, and only clients whose ABE keys satisfy
+the policy of the analytics key can decrypt the secret key and with it the sum, the count and the mean
+
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pzkt/abe-scripts/abe-scheme/internal/crypto"
+	"github.com/pzkt/abe-scripts/abe-scheme/internal/utils"
+)
+
+const analyticsTable = "analytics_keys"
+
+// every table has at most one analytics key, its entry has the same ID for all clients
+func analyticsKeyID(table string) uuid.UUID {
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte("analytics-key/"+table))
+}
+
+// the public key stays readable for every client that uploads entries, the secret key is ABE encrypted
+type analyticsKeyEntry struct {
+	PublicKey []byte
+	SecretKey []byte
+}
+
+// entries that encrypt themselves instead of being encrypted as a whole, see putEntry
+type selfEncrypting interface {
+	encrypt(scheme *crypto.ABEscheme, readPolicy string) ([]byte, error)
+}
+
+func (k analyticsKeyEntry) encrypt(scheme *crypto.ABEscheme, readPolicy string) ([]byte, error) {
+	secretKey, err := scheme.Encrypt(k.SecretKey, readPolicy)
+	if err != nil {
+		return nil, err
+	}
+	return utils.ToBytes(analyticsKeyEntry{PublicKey: k.PublicKey, SecretKey: secretKey}), nil
+}
+
+type Aggregate struct {
+	Sum   uint64
+	Count uint64
+	Mean  float64
+}
+
+// create the analytics key of a table, the aggregates can be decrypted for the purposes of the key
+func (e *env) createAnalyticsKey(table string, purposes string, writePurposes string) error {
+	_, found, err := e.fetchEntry(analyticsTable, analyticsKeyID(table))
+	if err != nil {
+		return err
+	}
+	if found {
+		return fmt.Errorf("%s already has an analytics key, a new key could not add up the values encrypted before", table)
+	}
+
+	publicKey, secretKey := crypto.GenerateAnalyticsKey()
+	if err := e.modifyEntry(analyticsTable, analyticsKeyEntry{publicKey, secretKey}, purposes, writePurposes, analyticsKeyID(table)); err != nil {
+		return err
+	}
+	e.analyticsKeys[table] = publicKey
+	return nil
+}
+
+// the stored analytics key of a table, its secret key is still ABE encrypted
+func (e *env) analyticsKey(table string) (utils.Record, analyticsKeyEntry, error) {
+	var key analyticsKeyEntry
+	record, found, err := e.fetchEntry(analyticsTable, analyticsKeyID(table))
+	if err != nil {
+		return record, key, err
+	}
+	if !found {
+		return record, key, fmt.Errorf("%s has no analytics key", table)
+	}
+	utils.FromBytes(record.Data, &key)
+	return record, key, nil
+}
+
+func (e *env) analyticsPublicKey(table string) ([]byte, error) {
+	if key, found := e.analyticsKeys[table]; found {
+		return key, nil
+	}
+	_, key, err := e.analyticsKey(table)
+	if err != nil {
+		return nil, err
+	}
+	e.analyticsKeys[table] = key.PublicKey
+	return key.PublicKey, nil
+}
+
+// the encrypted values of the aggregated fields of an entry, nil if its table has none
+// aggregated fields must hold non-negative integers
+func (e *env) entryAggregates(table string, entry any) (map[string][]byte, error) {
+	fields := []string{}
+	for field, mode := range e.schemas[table] {
+		if mode == Aggregated {
+			fields = append(fields, field)
+		}
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	values, err := fieldValues(entry)
+	if err != nil {
+		return nil, err
+	}
+	numbers := map[string]uint64{}
+	for _, field := range fields {
+		value, found := values[field]
+		if !found {
+			continue
+		}
+		if numbers[field], err = strconv.ParseUint(value, 10, 64); err != nil {
+			return nil, fmt.Errorf("aggregated field %s must be a non-negative integer: %w", field, err)
+		}
+	}
+	if len(numbers) == 0 {
+		return nil, nil
+	}
+
+	publicKey, err := e.analyticsPublicKey(table)
+	if err != nil {
+		return nil, err
+	}
+	aggregates := map[string][]byte{}
+	for field, n := range numbers {
+		if aggregates[field], err = crypto.EncryptAggregateValue(publicKey, n); err != nil {
+			return nil, fmt.Errorf("aggregated field %s: %w", field, err)
+		}
+	}
+	return aggregates, nil
+}
+
+// the sum, count and mean of an aggregated field over the entries that match the filters on public and searchable fields
+// the database only adds the ciphertexts, the result is decrypted with the secret analytics key
+func (e *env) aggregate(table string, field string, filters map[string]string) (Aggregate, error) {
+	if e.schemas[table][field] != Aggregated {
+		return Aggregate{}, fmt.Errorf("field %s of %s is not aggregated", field, table)
+	}
+	params, err := e.filterParams(table, filters)
+	if err != nil {
+		return Aggregate{}, err
+	}
+
+	resp, err := http.Get(fmt.Sprintf("%s/aggregate/%s/%s?%s", cfg.DatabaseURL, table, field, params.Encode()))
+	if err != nil {
+		return Aggregate{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Aggregate{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Aggregate{}, fmt.Errorf("aggregate failed: %s", body)
+	}
+	var response struct{ Aggregate []byte }
+	if err := json.Unmarshal(body, &response); err != nil {
+		return Aggregate{}, err
+	}
+
+	record, key, err := e.analyticsKey(table)
+	if err != nil {
+		return Aggregate{}, err
+	}
+	abeKey, err := e.keyring.KeyFor(record.Policy.ReadPolicy, time.Now())
+	if err != nil {
+		return Aggregate{}, fmt.Errorf("analytics key of %s: %w", table, err)
+	}
+
+	secretKey, err := e.abeScheme.Decrypt(key.SecretKey, abeKey)
+	if err != nil {
+		return Aggregate{}, fmt.Errorf("analytics key of %s: %w", table, err)
+	}
+	sum, count, err := crypto.DecryptAggregate(secretKey, response.Aggregate)
+	if err != nil {
+		return Aggregate{}, err
+	}
+	return Aggregate{Sum: sum, Count: count, Mean: float64(sum) / float64(count)}, nil
+}
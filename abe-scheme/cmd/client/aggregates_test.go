@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/pzkt/abe-scripts/abe-scheme/internal/crypto"
+	"github.com/pzkt/abe-scripts/generate-pseudodata/generator"
+)
+
+func TestAggregate(t *testing.T) {
+	fakeDatabase(t)
+	scheme := crypto.Setup()
+	schema := Schema{"heart_rate": Aggregated, "blood_pressure": Aggregated}
+	owner := keywordTestClient(scheme, "commerce:Admin")
+	analyst := keywordTestClient(scheme, "health:Research")
+	outsider := keywordTestClient(scheme, "commerce:Shipping")
+	for _, client := range []*env{owner, analyst, outsider} {
+		client.setSchema("table_two", schema)
+	}
+
+	record := generator.GenerateCardiologyRecord("345")
+	if _, err := owner.addEntry("table_two", record, "Admin", "Admin"); err == nil {
+		t.Error("aggregated fields need an analytics key")
+	}
+	if err := owner.createAnalyticsKey("table_two", "Research", "Admin"); err != nil {
+		t.Fatal(err)
+	}
+	if err := owner.createAnalyticsKey("table_two", "Research", "Admin"); err == nil {
+		t.Error("an existing analytics key should not be replaced")
+	}
+
+	var sum uint64
+	for _, heartRate := range []int{60, 75, 90, 111} {
+		record.HeartRate = heartRate
+		sum += uint64(heartRate)
+		if _, err := owner.addEntry("table_two", record, "Admin", "Admin"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	aggregate, err := analyst.aggregate("table_two", "heart_rate", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if aggregate.Sum != sum || aggregate.Count != 4 || aggregate.Mean != float64(sum)/4 {
+		t.Errorf("got %+v, want sum %d of 4 values", aggregate, sum)
+	}
+
+	if _, err := outsider.aggregate("table_two", "heart_rate", nil); err == nil {
+		t.Error("a client without a key for the analytics policy should not decrypt the aggregate")
+	}
+	if _, err := analyst.aggregate("table_two", "stress_test_results", nil); err == nil {
+		t.Error("only aggregated fields can be aggregated")
+	}
+}
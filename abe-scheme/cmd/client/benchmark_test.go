@@ -4,22 +4,36 @@ import (
 	"bytes"
 	"crypto/rand"
 	"fmt"
+	"log"
+	"os"
 	"runtime"
 	"strconv"
 	"testing"
 
 	"github.com/google/uuid"
+	"github.com/pzkt/abe-scripts/abe-scheme/internal/config"
+	"github.com/pzkt/abe-scripts/abe-scheme/internal/utils"
+	"github.com/pzkt/abe-scripts/abe-scheme/internal/utils/policyConfig"
 	"github.com/pzkt/abe-scripts/generate-pseudodata/generator"
 )
 
 var attributeCounts = [...]int{1, 5, 10, 15, 20, 25, 30, 35, 40, 45, 50}
 
+// benchmarks pick up the environment from ABE_CONFIG and the ABE_* variables, go test owns the flags
+func TestMain(m *testing.M) {
+	var err error
+	if cfg, err = config.Load(nil); err != nil {
+		log.Fatal(err)
+	}
+	os.Exit(m.Run())
+}
+
 // average file size: 443 bytes
 func BenchmarkUploadSmallEntry(b *testing.B) {
 	for n := 0; n < b.N; n++ {
 		env := setup()
 		record := generator.GenerateRandomRecord(uuid.NewString())
-		env.addEntry("table_one", record, "Radiology AND Masked-Research", "Radiology AND Masked-Research")
+		utils.Assure(env.addEntry("table_one", record, "Radiology AND Masked-Research", "Radiology AND Masked-Research"))
 	}
 }
 
@@ -34,7 +48,7 @@ func BenchmarkUploadMediumEntry(b *testing.B) {
 			new_patient.Records = append(new_patient.Records, generator.GenerateRandomRecord(new_patient.ID))
 		}
 
-		env.addEntry("table_one", new_patient, "Radiology AND Masked-Research", "Radiology AND Masked-Research")
+		utils.Assure(env.addEntry("table_one", new_patient, "Radiology AND Masked-Research", "Radiology AND Masked-Research"))
 	}
 }
 
@@ -51,7 +65,7 @@ func BenchmarkUploadLargeEntry(b *testing.B) {
 		//generating the data takes a considerable amount of time. Don't count it to the total
 		b.ResetTimer()
 
-		env.addEntry("table_one", new_patient, "Radiology AND Masked-Research", "Radiology AND Masked-Research")
+		utils.Assure(env.addEntry("table_one", new_patient, "Radiology AND Masked-Research", "Radiology AND Masked-Research"))
 	}
 }
 
@@ -63,7 +77,7 @@ func BenchmarkModifyEntry(b *testing.B) {
 	for j := 0; j < 100; j++ {
 		new_patient.Records = append(new_patient.Records, generator.GenerateRandomRecord(new_patient.ID))
 	}
-	entryUUID := env.addEntry("table_one", new_patient, "Radiology AND Masked-Research", "Radiology AND Masked-Research")
+	entryUUID := utils.Assure(env.addEntry("table_one", new_patient, "Radiology AND Masked-Research", "Radiology AND Masked-Research"))
 
 	for n := 0; n < b.N; n++ {
 		new_patient = generator.GeneratePatient()
@@ -71,7 +85,7 @@ func BenchmarkModifyEntry(b *testing.B) {
 		for j := 0; j < 100; j++ {
 			new_patient.Records = append(new_patient.Records, generator.GenerateRandomRecord(new_patient.ID))
 		}
-		env.modifyEntry("table_one", new_patient, "Radiology AND Masked-Research", "Radiology AND Masked-Research", entryUUID)
+		utils.Try(env.modifyEntry("table_one", new_patient, "Radiology AND Masked-Research", "Radiology AND Masked-Research", entryUUID))
 	}
 }
 
@@ -84,13 +98,13 @@ func BenchmarkGetEntry(b *testing.B) {
 		new_patient.Records = append(new_patient.Records, generator.GenerateRandomRecord(new_patient.ID))
 	}
 
-	entryUUID := env.addEntry("table_one", new_patient, "Radiology AND Masked-Research", "Radiology AND Masked-Research")
+	entryUUID := utils.Assure(env.addEntry("table_one", new_patient, "Radiology AND Masked-Research", "Radiology AND Masked-Research"))
 
 	key := requestNewKey([]string{"General-Purpose"})
 
 	for n := 0; n < b.N; n++ {
 		data := env.getEntry("table_one", entryUUID).Data
-		decrypted_data := env.abeScheme.Decrypt(data, key)
+		decrypted_data := utils.Assure(env.abeScheme.Decrypt(data, key))
 		runtime.KeepAlive(decrypted_data)
 	}
 }
@@ -122,14 +136,36 @@ func BenchmarkUploadVariablePolicy(b *testing.B) {
 		}
 		policy.WriteString("attribute_" + strconv.Itoa(count-1))
 
-		entryUUID := env.addEntry("table_one", content, policy.String(), policy.String())
+		entryUUID := utils.Assure(env.addEntry("table_one", content, policy.String(), policy.String()))
 
 		b.Run(fmt.Sprintf("Attributes_%d", count), func(b *testing.B) {
 			for i := 0; i < b.N; i++ {
 				data := env.getEntry("table_one", entryUUID).Data
-				decrypted_data := env.abeScheme.Decrypt(data, key)
+				decrypted_data := utils.Assure(env.abeScheme.Decrypt(data, key))
 				runtime.KeepAlive(decrypted_data)
 			}
 		})
 	}
 }
+
+// translation of a purpose policy to an attribute policy for large purpose hierarchies, no servers needed
+func BenchmarkPolicyTranslation(b *testing.B) {
+	for _, size := range []int{100, 1000, 5000} {
+		pc := policyConfig.Config{PurposeTrees: []*utils.Tree{utils.SyntheticPurposeTree(size, 8)}}
+		last := size - 1
+		//the threshold is over siblings, ancestors it shares with the AND would be needed twice
+		policy := fmt.Sprintf("(Purpose-%d AND Purpose-%d) OR 2 of (Purpose-%d, Purpose-%d, Purpose-%d)",
+			last, last/2, last/3, last/3+1, last/3+2)
+
+		for _, indexed := range []bool{false, true} {
+			if indexed {
+				pc.BuildIndex()
+			}
+			b.Run(fmt.Sprintf("purposes=%d/indexed=%v", size, indexed), func(b *testing.B) {
+				for n := 0; n < b.N; n++ {
+					utils.Assure(toAttr(policy, pc))
+				}
+			})
+		}
+	}
+}
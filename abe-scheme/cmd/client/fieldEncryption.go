@@ -0,0 +1,131 @@
+/*
+
+field level encryption
+Fields of an entry can be tagged with a read policy of their own, e.g. `abe:"Health-Record AND Need-To-Know"`.
+Entries with tagged fields are encrypted field by field, untagged fields under the read policy of the entry,
+and decrypted into a partially populated struct with the fields the keys in the keyring can open
+
+*/
+
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/pzkt/abe-scripts/abe-scheme/internal/utils"
+	"github.com/pzkt/abe-scripts/generate-pseudodata/generator"
+)
+
+const fieldPolicyTag = "abe"
+
+// the exported fields of a struct, nil if the entry is not a struct or none of its fields has a policy of its own
+func taggedFields(entry any) []reflect.StructField {
+	t := reflect.TypeOf(entry)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	fields := []reflect.StructField{}
+	tagged := false
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || len(f.Index) > 1 {
+			continue
+		}
+		_, found := f.Tag.Lookup(fieldPolicyTag)
+		tagged = tagged || found
+		fields = append(fields, f)
+	}
+	if !tagged {
+		return nil
+	}
+	return fields
+}
+
+// one ciphertext per field, fields without a policy of their own are encrypted under the read policy of the entry
+// the time bounds of the entry apply to every field
+func (e *env) encryptFields(entry any, fields []reflect.StructField, readPolicy string, bounds TimeBounds) ([]byte, []utils.FieldPolicy, error) {
+	value := reflect.Indirect(reflect.ValueOf(entry))
+
+	ciphertexts := [][]byte{}
+	policies := []utils.FieldPolicy{}
+	for _, f := range fields {
+		policy := utils.FieldPolicy{Field: f.Name, Policy: readPolicy}
+		if purposes, found := f.Tag.Lookup(fieldPolicyTag); found {
+			fullPolicy, err := toBoundedAttr(purposes, e.policyConfig, bounds)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid policy of field %s: %w", f.Name, err)
+			}
+			policy.Purposes = purposes
+			policy.Policy = fullPolicy
+		}
+		cipher, err := e.abeScheme.Encrypt(utils.ToBytes(value.FieldByIndex(f.Index).Interface()), policy.Policy)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot encrypt field %s: %w", f.Name, err)
+		}
+		policies = append(policies, policy)
+		ciphertexts = append(ciphertexts, cipher)
+	}
+	return utils.ToBytes(ciphertexts), policies, nil
+}
+
+// decrypt the fields of a record with field level encryption into target, a pointer to the struct the entry was created from
+// fields without a key in the keyring that satisfies their policy are left unchanged and returned
+func (e *env) decryptFields(record utils.Record, target any) ([]string, error) {
+	value := reflect.ValueOf(target)
+	if value.Kind() != reflect.Pointer || value.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("fields can only be decrypted into a pointer to a struct, not %T", target)
+	}
+	value = value.Elem()
+
+	if len(record.Policy.Fields) == 0 {
+		return nil, fmt.Errorf("record %s is not encrypted field by field", record.ID)
+	}
+	var ciphertexts [][]byte
+	utils.FromBytes(record.Data, &ciphertexts)
+	if len(ciphertexts) != len(record.Policy.Fields) {
+		return nil, fmt.Errorf("record %s has %d field ciphertexts for %d field policies", record.ID, len(ciphertexts), len(record.Policy.Fields))
+	}
+
+	unreadable := []string{}
+	for i, policy := range record.Policy.Fields {
+		field := value.FieldByName(policy.Field)
+		if !field.IsValid() || !field.CanSet() {
+			return nil, fmt.Errorf("record %s has a field %s that %T does not have", record.ID, policy.Field, target)
+		}
+		key, err := e.keyring.KeyFor(policy.Policy, time.Now())
+		if err != nil {
+			unreadable = append(unreadable, policy.Field)
+			continue
+		}
+		plaintext, err := e.abeScheme.Decrypt(ciphertexts[i], key)
+		if err != nil {
+			return nil, fmt.Errorf("field %s of record %s: %w", policy.Field, record.ID, err)
+		}
+		utils.FromBytes(plaintext, field.Addr().Interface())
+	}
+	return unreadable, nil
+}
+
+// a patient whose contact details are readable for shipping, while the medical records need a health record purpose
+// the remaining fields use the read policy of the entry
+type PatientEntry struct {
+	ID               string                     `json:"id"`
+	Name             generator.Name             `json:"name" abe:"Shipping"`
+	DOB              time.Time                  `json:"date_of_birth"`
+	Address          string                     `json:"address" abe:"Shipping"`
+	Phone            string                     `json:"phone"`
+	Email            string                     `json:"email"`
+	Insurance        string                     `json:"insurance"`
+	EmergencyContact generator.EmergencyContact `json:"emergency_contact"`
+	CreatedAt        time.Time                  `json:"created_date"`
+	Records          []any                      `json:"records" abe:"Health-Record AND Need-To-Know"`
+}
+
+func NewPatientEntry(p generator.Patient) PatientEntry {
+	return PatientEntry(p)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/pzkt/abe-scripts/abe-scheme/internal/crypto"
+	"github.com/pzkt/abe-scripts/abe-scheme/internal/utils"
+	"github.com/pzkt/abe-scripts/generate-pseudodata/generator"
+)
+
+func fieldTestClient(scheme *crypto.ABEscheme, attributes ...string) *env {
+	client := &env{abeScheme: scheme, policyConfig: testConfig, keyring: utils.Assure(OpenKeyring("", ""))}
+	client.keyring.AddABEKey(scheme.KeyGen(attributes), time.Time{})
+	return client
+}
+
+func TestFieldEncryption(t *testing.T) {
+	scheme := crypto.Setup()
+	owner := fieldTestClient(scheme, "commerce:Admin")
+
+	patient := NewPatientEntry(generator.GeneratePatient())
+	patient.Records = []any{"cardiology", "oncology"}
+	//times are encoded in seconds
+	patient.DOB = patient.DOB.Truncate(time.Second)
+
+	fields := taggedFields(patient)
+	if len(fields) != 10 {
+		t.Fatalf("got %d fields", len(fields))
+	}
+	data, policies, err := owner.encryptFields(&patient, fields, utils.Assure(toAttr("Admin", testConfig)), TimeBounds{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := utils.Record{Data: data, Policy: utils.PolicyDescriptor{Fields: policies}}
+
+	tests := []struct {
+		attributes []string
+		unreadable []string
+	}{
+		{[]string{"commerce:Shipping"}, []string{"ID", "DOB", "Phone", "Email", "Insurance", "EmergencyContact", "CreatedAt", "Records"}},
+		{[]string{"health:Health-Record"}, []string{"ID", "Name", "DOB", "Address", "Phone", "Email", "Insurance", "EmergencyContact", "CreatedAt"}},
+		{[]string{"commerce:Admin"}, []string{"Name", "Address", "Records"}},
+		{[]string{"commerce:General-Purpose", "health:General-Purpose"}, []string{}},
+	}
+	for _, tc := range tests {
+		var got PatientEntry
+		unreadable, err := fieldTestClient(scheme, tc.attributes...).decryptFields(record, &got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(unreadable, tc.unreadable) {
+			t.Errorf("%v: unreadable %v, want %v", tc.attributes, unreadable, tc.unreadable)
+		}
+
+		//readable fields are restored, the others stay empty
+		if readable := !slices.Contains(unreadable, "Name"); (got.Name == patient.Name) != readable || (got.Address == patient.Address) != readable {
+			t.Errorf("%v: contact details %v %q", tc.attributes, got.Name, got.Address)
+		}
+		if readable := !slices.Contains(unreadable, "Records"); (len(got.Records) == 2) != readable {
+			t.Errorf("%v: records %v", tc.attributes, got.Records)
+		}
+		if readable := !slices.Contains(unreadable, "ID"); (got.ID == patient.ID) != readable || got.DOB.Equal(patient.DOB) != readable {
+			t.Errorf("%v: id %q and date of birth %v", tc.attributes, got.ID, got.DOB)
+		}
+	}
+
+	if _, err := owner.decrypt(record); err == nil {
+		t.Error("field encrypted records can not be decrypted as a whole")
+	}
+	var wrong generator.Name
+	if _, err := owner.decryptFields(record, &wrong); err == nil {
+		t.Error("fields should only be decrypted into a struct that has them")
+	}
+}
+
+func TestTaggedFields(t *testing.T) {
+	if taggedFields(generator.GeneratePatient()) != nil {
+		t.Error("entries without field policies are encrypted as a whole")
+	}
+	if taggedFields("text") != nil || taggedFields(nil) != nil {
+		t.Error("only structs can have field policies")
+	}
+	if fields := taggedFields(&PatientEntry{}); len(fields) != 10 {
+		t.Errorf("pointers to tagged structs have %d fields", len(fields))
+	}
+
+	client := fieldTestClient(crypto.Setup(), "commerce:Admin")
+	bad := struct {
+		Text string `abe:"Admin AND"`
+	}{"text"}
+	if _, _, err := client.encryptFields(bad, taggedFields(bad), "commerce:Admin", TimeBounds{}); err == nil {
+		t.Error("invalid field policies should be rejected")
+	}
+}
@@ -0,0 +1,185 @@
+/*
+
+public and searchable fields of entries
+The whole entry is always ABE encrypted. A schema can additionally store single fields next to the ciphertext:
+in plain text, so the database can filter and sort by them, or as blind index or deterministic ciphertext,
+so clients with the search key can find entries with a given value
+
+*/
+
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/pzkt/abe-scripts/abe-scheme/internal/crypto"
+	"github.com/pzkt/abe-scripts/abe-scheme/internal/utils"
+)
+
+type FieldMode int
+
+const (
+	Encrypted     FieldMode = iota // only part of the ABE encrypted data
+	Public                         // stored in plain text
+	Deterministic                  // deterministically encrypted with the search key, can be found and decrypted again
+	BlindIndexed                   // keyed hash with the search key, can only be found
+	Keyword                        // part of the encrypted keyword index of the table, see searchKeywords
+	Aggregated                     // BFV encrypted with the analytics key of the table, see aggregate
+)
+
+// how the fields of the entries of a table are stored, by their JSON name
+// fields that are not part of the schema are only ABE encrypted
+type Schema map[string]FieldMode
+
+func (e *env) setSchema(table string, schema Schema) {
+	e.schemas[table] = schema
+}
+
+// the top level fields of an entry as they are encoded in JSON, strings without their quotes
+func fieldValues(entry any) (map[string]string, error) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+	raw := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("only entries that are JSON objects can have public fields: %w", err)
+	}
+
+	out := map[string]string{}
+	for field, value := range raw {
+		var s string
+		if json.Unmarshal(value, &s) == nil {
+			out[field] = s
+		} else {
+			out[field] = string(value)
+		}
+	}
+	return out, nil
+}
+
+// the metadata and search fields of an entry according to the schema of its table
+func (e *env) entryFields(table string, entry any) (map[string]string, map[string]string, error) {
+	schema := e.schemas[table]
+	if len(schema) == 0 {
+		return nil, nil, nil
+	}
+	values, err := fieldValues(entry)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	metadata := map[string]string{}
+	search := map[string]string{}
+	for field, mode := range schema {
+		value, found := values[field]
+		if !found || (mode != Public && mode != Deterministic && mode != BlindIndexed) {
+			continue
+		}
+		if mode == Public {
+			metadata[field] = value
+			continue
+		}
+		token, err := e.searchToken(field, value, mode)
+		if err != nil {
+			return nil, nil, err
+		}
+		search[field] = token
+	}
+	return metadata, search, nil
+}
+
+func (e *env) searchToken(field string, value string, mode FieldMode) (string, error) {
+	if e.searchKey == nil {
+		return "", fmt.Errorf("field %s is searchable but no search_key is configured", field)
+	}
+	if mode == Deterministic {
+		return e.searchKey.EncryptDeterministic(field, value), nil
+	}
+	return e.searchKey.BlindIndex(field, value), nil
+}
+
+// the entries of a table whose fields have the given values, sorted by a public field or by creation if sortBy is empty
+// only public and searchable fields can be used, the database never sees the values of searchable fields
+func (e *env) findEntries(table string, filters map[string]string, sortBy string) ([]utils.Record, error) {
+	schema := e.schemas[table]
+	params, err := e.filterParams(table, filters)
+	if err != nil {
+		return nil, err
+	}
+	if sortBy != "" {
+		if schema[sortBy] != Public {
+			return nil, fmt.Errorf("entries can only be sorted by public fields, %s is not public", sortBy)
+		}
+		params.Set("sort", "metadata."+sortBy)
+	}
+
+	resp, err := http.Get(fmt.Sprintf("%s/entries/%s?%s", cfg.DatabaseURL, table, params.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("find entries failed: %s", body)
+	}
+
+	var records []utils.Record
+	return records, json.Unmarshal(body, &records)
+}
+
+// the query parameters for filters on public and searchable fields
+func (e *env) filterParams(table string, filters map[string]string) (url.Values, error) {
+	schema := e.schemas[table]
+	params := url.Values{}
+	for field, value := range filters {
+		switch mode := schema[field]; mode {
+		case Public:
+			params.Add("metadata."+field, value)
+		case Deterministic, BlindIndexed:
+			token, err := e.searchToken(field, value, mode)
+			if err != nil {
+				return nil, err
+			}
+			params.Add("search."+field, token)
+		case Keyword:
+			return nil, fmt.Errorf("field %s of %s is a keyword, search it with searchKeywords", field, table)
+		default:
+			return nil, fmt.Errorf("field %s of %s is only encrypted and can not be searched", field, table)
+		}
+	}
+	return params, nil
+}
+
+// the value of a public or deterministically encrypted field without decrypting the entry
+func (e *env) revealField(record utils.Record, field string) (string, error) {
+	switch e.schemas[record.Table][field] {
+	case Public:
+		return record.Metadata[field], nil
+	case Deterministic:
+		if e.searchKey == nil {
+			return "", fmt.Errorf("field %s is encrypted with the search key, but no search_key is configured", field)
+		}
+		return e.searchKey.DecryptDeterministic(field, record.Search[field])
+	default:
+		return "", fmt.Errorf("field %s of %s can only be read by decrypting the entry", field, record.Table)
+	}
+}
+
+// the search key is shared by all clients that may search the entries, it is never sent to the database
+func searchKeyFromConfig() *crypto.SearchKey {
+	if cfg.SearchKey == "" {
+		return nil
+	}
+	key := crypto.NewSearchKey(utils.Assure(hex.DecodeString(cfg.SearchKey)))
+	return &key
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/pzkt/abe-scripts/abe-scheme/internal/crypto"
+	"github.com/pzkt/abe-scripts/abe-scheme/internal/utils"
+)
+
+type fieldsTestEntry struct {
+	PatientID  string `json:"patient_id"`
+	ProviderID string `json:"provider_id"`
+	Date       string `json:"date"`
+	Visits     int    `json:"visits"`
+	Notes      string `json:"notes"`
+}
+
+func fieldsTestClient() *env {
+	key := crypto.NewSearchKey([]byte("0123456789abcdef"))
+	client := &env{schemas: make(map[string]Schema), searchKey: &key}
+	client.setSchema("table_one", Schema{
+		"date":        Public,
+		"visits":      Public,
+		"patient_id":  BlindIndexed,
+		"provider_id": Deterministic,
+		"notes":       Encrypted,
+	})
+	return client
+}
+
+func TestEntryFields(t *testing.T) {
+	client := fieldsTestClient()
+	entry := fieldsTestEntry{PatientID: "345", ProviderID: "dr-7", Date: "2025-03-01", Visits: 4, Notes: "secret"}
+
+	metadata, search, err := client.entryFields("table_one", entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metadata) != 2 || metadata["date"] != "2025-03-01" || metadata["visits"] != "4" {
+		t.Errorf("metadata %v", metadata)
+	}
+	if len(search) != 2 || search["patient_id"] != client.searchKey.BlindIndex("patient_id", "345") {
+		t.Errorf("search %v", search)
+	}
+
+	record := utils.Record{Table: "table_one", Metadata: metadata, Search: search}
+	if v, err := client.revealField(record, "provider_id"); err != nil || v != "dr-7" {
+		t.Errorf("deterministic field revealed as %q, %v", v, err)
+	}
+	if v, err := client.revealField(record, "date"); err != nil || v != "2025-03-01" {
+		t.Errorf("public field revealed as %q, %v", v, err)
+	}
+	for _, field := range []string{"patient_id", "notes"} {
+		if _, err := client.revealField(record, field); err == nil {
+			t.Errorf("%s should only be readable by decrypting the entry", field)
+		}
+	}
+
+	//tables without a schema only store the ciphertext
+	if metadata, search, err := client.entryFields("table_two", entry); metadata != nil || search != nil || err != nil {
+		t.Errorf("table without schema: %v %v %v", metadata, search, err)
+	}
+
+	client.searchKey = nil
+	if _, _, err := client.entryFields("table_one", entry); err == nil {
+		t.Error("searchable fields need a search key")
+	}
+}
+
+func TestFindEntries(t *testing.T) {
+	var query url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		json.NewEncoder(w).Encode([]utils.Record{{Table: "table_one"}})
+	}))
+	defer server.Close()
+
+	previous := cfg.DatabaseURL
+	cfg.DatabaseURL = server.URL
+	defer func() { cfg.DatabaseURL = previous }()
+
+	client := fieldsTestClient()
+	records, err := client.findEntries("table_one", map[string]string{"date": "2025-03-01", "patient_id": "345", "provider_id": "dr-7"}, "visits")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Errorf("got %d records", len(records))
+	}
+
+	//the database only sees the public values and the search tokens
+	want := url.Values{
+		"metadata.date":      {"2025-03-01"},
+		"search.patient_id":  {client.searchKey.BlindIndex("patient_id", "345")},
+		"search.provider_id": {client.searchKey.EncryptDeterministic("provider_id", "dr-7")},
+		"sort":               {"metadata.visits"},
+	}
+	if query.Encode() != want.Encode() {
+		t.Errorf("query %v, want %v", query, want)
+	}
+
+	if _, err := client.findEntries("table_one", map[string]string{"notes": "secret"}, ""); err == nil {
+		t.Error("encrypted fields should not be searchable")
+	}
+	if _, err := client.findEntries("table_one", nil, "patient_id"); err == nil {
+		t.Error("entries should only be sorted by public fields")
+	}
+}
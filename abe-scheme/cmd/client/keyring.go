@@ -0,0 +1,210 @@
+/*
+
+local keyring of the client
+ABE keys and the write keys of created entries are kept in a single file that is encrypted with a passphrase
+ABE keys are tagged with their attributes, the epoch of time bound keys and their expiry, so the key for a ciphertext can be picked from its policy
+
+*/
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/google/uuid"
+	"github.com/pzkt/abe-scripts/abe-scheme/internal/crypto"
+	"github.com/pzkt/abe-scripts/abe-scheme/internal/utils"
+	"golang.org/x/term"
+)
+
+// the passphrase is never read from config files or flags, where it would end up on disk or in the process list
+const keyringPassphraseEnv = "ABE_KEYRING_PASSPHRASE"
+
+// the keyring is always stored as cbor, independent of the encoding utils.ToBytes uses for records
+// times keep their nanoseconds, so expiry and the order of added keys survive a save
+var keyringEncoding = utils.Assure(cbor.EncOptions{Time: cbor.TimeRFC3339Nano}.EncMode())
+
+type ABEKeyEntry struct {
+	Key        []byte
+	Attributes []string
+	TimeBound  bool
+	Epoch      int       // only for time bound keys
+	Expires    time.Time // zero if the key does not expire
+	Added      time.Time
+}
+
+type WriteKeyEntry struct {
+	Table  string
+	Record uuid.UUID
+	Key    []byte // x509 encoded private key
+	Added  time.Time
+}
+
+type Keyring struct {
+	path       string
+	passphrase []byte
+
+	ABEKeys   []ABEKeyEntry
+	WriteKeys []WriteKeyEntry
+}
+
+// the contents of the file are only readable with the passphrase, a missing file is an empty keyring
+func OpenKeyring(path string, passphrase string) (*Keyring, error) {
+	k := &Keyring{path: path, passphrase: []byte(passphrase)}
+
+	sealed, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return k, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := crypto.OpenWithPassphrase(k.passphrase, sealed)
+	if err != nil {
+		return nil, fmt.Errorf("keyring %s: %w", path, err)
+	}
+	if err := cbor.Unmarshal(data, k); err != nil {
+		return nil, fmt.Errorf("keyring %s: %w", path, err)
+	}
+	return k, nil
+}
+
+// the passphrase from ABE_KEYRING_PASSPHRASE, or asked for on the terminal if it is not set
+func keyringPassphrase() (string, error) {
+	if passphrase := os.Getenv(keyringPassphraseEnv); passphrase != "" {
+		return passphrase, nil
+	}
+	stdin := int(os.Stdin.Fd())
+	if !term.IsTerminal(stdin) {
+		return "", fmt.Errorf("set %s or start the client on a terminal to enter the keyring passphrase", keyringPassphraseEnv)
+	}
+
+	fmt.Fprint(os.Stderr, "keyring passphrase: ")
+	passphrase, err := term.ReadPassword(stdin)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	if len(passphrase) == 0 {
+		return "", errors.New("the keyring passphrase must not be empty")
+	}
+	return string(passphrase), nil
+}
+
+// the file is replaced at once, so an interrupted save never leaves a broken keyring
+// a keyring without a file is only kept in memory
+func (k *Keyring) Save() error {
+	if k.path == "" {
+		return nil
+	}
+	data, err := keyringEncoding.Marshal(k)
+	if err != nil {
+		return err
+	}
+	sealed, err := crypto.SealWithPassphrase(k.passphrase, data)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(k.path), ".keyring-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(sealed); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), k.path)
+}
+
+// the attributes and the epoch are read from the key itself, validUntil is zero for keys that do not expire
+func (k *Keyring) AddABEKey(key []byte, validUntil time.Time) ABEKeyEntry {
+	attributes := crypto.KeyAttributes(key)
+	epoch, timeBound := utils.BitValue(utils.TimeAttribute, attributes, utils.TimeValueSize)
+	entry := ABEKeyEntry{
+		Key:        key,
+		Attributes: attributes,
+		TimeBound:  timeBound,
+		Epoch:      epoch,
+		Expires:    validUntil,
+		Added:      time.Now(),
+	}
+	k.ABEKeys = append(k.ABEKeys, entry)
+	return entry
+}
+
+func (k *Keyring) AddWriteKey(table string, record uuid.UUID, key *ecdsa.PrivateKey) error {
+	marshaled, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	k.WriteKeys = slices.DeleteFunc(k.WriteKeys, func(e WriteKeyEntry) bool { return e.Record == record })
+	k.WriteKeys = append(k.WriteKeys, WriteKeyEntry{Table: table, Record: record, Key: marshaled, Added: time.Now()})
+	return nil
+}
+
+func (k *Keyring) WriteKey(record uuid.UUID) (*ecdsa.PrivateKey, bool) {
+	for _, e := range k.WriteKeys {
+		if e.Record == record {
+			key, err := x509.ParseECPrivateKey(e.Key)
+			return key, err == nil
+		}
+	}
+	return nil, false
+}
+
+// a key that satisfies the policy of a ciphertext
+// keys that have not expired are preferred, an expired time bound key still decrypts data of its own epoch
+// among those the most recently added key is used
+func (k *Keyring) KeyFor(policy string, now time.Time) ([]byte, error) {
+	var best *ABEKeyEntry
+	for i := range k.ABEKeys {
+		e := &k.ABEKeys[i]
+		ok, err := CanDecrypt(policy, e.Attributes)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		if best == nil || e.preferredTo(best, now) {
+			best = e
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("no key in the keyring satisfies %s", policy)
+	}
+	return best.Key, nil
+}
+
+func (e *ABEKeyEntry) Expired(now time.Time) bool {
+	return !e.Expires.IsZero() && !now.Before(e.Expires)
+}
+
+func (e *ABEKeyEntry) preferredTo(other *ABEKeyEntry, now time.Time) bool {
+	if e.Expired(now) != other.Expired(now) {
+		return !e.Expired(now)
+	}
+	return e.Added.After(other.Added)
+}
+
+// remove the keys that expired before the given time, returns the number of removed keys
+func (k *Keyring) Prune(before time.Time) int {
+	n := len(k.ABEKeys)
+	k.ABEKeys = slices.DeleteFunc(k.ABEKeys, func(e ABEKeyEntry) bool { return e.Expired(before) })
+	return n - len(k.ABEKeys)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pzkt/abe-scripts/abe-scheme/internal/crypto"
+	"github.com/pzkt/abe-scripts/abe-scheme/internal/utils"
+)
+
+func TestKeyringRoundTrip(t *testing.T) {
+	scheme := crypto.Setup()
+	path := filepath.Join(t.TempDir(), "keyring")
+
+	k, err := OpenKeyring(path, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	timeAttributes := utils.Assure(utils.TimeAttributes(now, time.Hour))
+	k.AddABEKey(scheme.KeyGen([]string{"commerce:Admin"}), time.Time{})
+	entry := k.AddABEKey(scheme.KeyGen(append([]string{"commerce:Shipping"}, timeAttributes...)), now.Add(time.Hour))
+	if !entry.TimeBound || entry.Epoch != utils.Epoch(now, time.Hour) {
+		t.Errorf("time bound key not tagged with its epoch: %v %d", entry.TimeBound, entry.Epoch)
+	}
+
+	writeKey := crypto.GenerateSignatureKey()
+	record := uuid.New()
+	if err := k.AddWriteKey("table_one", record, writeKey); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	if data := utils.Assure(os.ReadFile(path)); bytes.Contains(data, []byte("commerce:Admin")) {
+		t.Error("the keyring file should be encrypted")
+	}
+
+	if _, err := OpenKeyring(path, "wrong"); err == nil {
+		t.Error("the keyring should not open with a wrong passphrase")
+	}
+
+	opened, err := OpenKeyring(path, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(opened.ABEKeys) != 2 || !slices.Equal(opened.ABEKeys[1].Attributes, entry.Attributes) {
+		t.Errorf("ABE keys not restored: %v", opened.ABEKeys)
+	}
+	restored, found := opened.WriteKey(record)
+	if !found || !restored.Equal(writeKey) {
+		t.Error("write key not restored")
+	}
+	if _, found := opened.WriteKey(uuid.New()); found {
+		t.Error("unknown record should have no write key")
+	}
+
+	//the keys read from disk still decrypt and the reopened keyring saves to the same file
+	key := utils.Assure(opened.KeyFor("commerce:Admin", now))
+	if !bytes.Equal(key, k.ABEKeys[0].Key) || !opened.ABEKeys[1].Expires.Equal(entry.Expires) {
+		t.Errorf("keys changed on disk, expiry %v, want %v", opened.ABEKeys[1].Expires, entry.Expires)
+	}
+	plaintext := utils.Assure(scheme.Decrypt(utils.Assure(scheme.Encrypt([]byte("data"), "commerce:Admin")), key))
+	if string(plaintext) != "data" {
+		t.Errorf("decrypted %q with the reopened key", plaintext)
+	}
+	opened.AddWriteKey("table_one", uuid.New(), crypto.GenerateSignatureKey())
+	if err := opened.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if reopened := utils.Assure(OpenKeyring(path, "correct horse")); len(reopened.WriteKeys) != 2 || len(reopened.ABEKeys) != 2 {
+		t.Errorf("second save not restored: %d write keys, %d ABE keys", len(reopened.WriteKeys), len(reopened.ABEKeys))
+	}
+}
+
+func TestKeyringPicksKey(t *testing.T) {
+	scheme := crypto.Setup()
+	k := utils.Assure(OpenKeyring("", ""))
+
+	now := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+	old := now.Add(-2 * time.Hour)
+	k.AddABEKey(scheme.KeyGen([]string{"commerce:Admin"}), time.Time{})
+	k.AddABEKey(scheme.KeyGen(append([]string{"commerce:Admin"}, utils.Assure(utils.TimeAttributes(old, time.Hour))...)), old.Add(time.Hour))
+	k.AddABEKey(scheme.KeyGen(append([]string{"commerce:Admin"}, utils.Assure(utils.TimeAttributes(now, time.Hour))...)), now.Add(time.Hour))
+	k.AddABEKey(scheme.KeyGen([]string{"commerce:Shipping"}), time.Time{})
+
+	for _, tc := range []struct {
+		policy string
+		want   int
+	}{
+		{"commerce:Shipping", 3},
+		{"commerce:Admin", 2},
+		{utils.Assure(toBoundedAttr("Admin", timedConfig, TimeBounds{Until: old})), 1},
+		{utils.Assure(toBoundedAttr("Admin", timedConfig, TimeBounds{After: now})), 2},
+		{"commerce:Marketing", -1},
+	} {
+		key, err := k.KeyFor(tc.policy, now)
+		if tc.want < 0 {
+			if err == nil {
+				t.Errorf("%s: expected no key", tc.policy)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tc.policy, err)
+			continue
+		}
+		if !slices.Equal(key, k.ABEKeys[tc.want].Key) {
+			t.Errorf("%s: expected key %d", tc.policy, tc.want)
+		}
+	}
+
+	if removed := k.Prune(now); removed != 1 || len(k.ABEKeys) != 3 {
+		t.Errorf("expected the expired key to be removed, removed %d", removed)
+	}
+}
+
+func TestKeyringPassphraseFromEnvironment(t *testing.T) {
+	t.Setenv(keyringPassphraseEnv, "correct horse")
+	passphrase, err := keyringPassphrase()
+	if err != nil || passphrase != "correct horse" {
+		t.Errorf("got %q, error %v", passphrase, err)
+	}
+}
@@ -0,0 +1,169 @@
+/*
+
+encrypted keyword index
+The Keyword fields of an entry are indexed at upload time with the index key of its table, see crypto.IndexKeywords.
+The index key is stored ABE encrypted in the search_indexes table, so only clients whose keys satisfy the policy of the index
+can index entries or search them. The database only receives trapdoors and never the keywords
+
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/pzkt/abe-scripts/abe-scheme/internal/crypto"
+	"github.com/pzkt/abe-scripts/abe-scheme/internal/utils"
+)
+
+const indexTable = "search_indexes"
+
+// every table has at most one index, its entry has the same ID for all clients
+func indexID(table string) uuid.UUID {
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte("search-index/"+table))
+}
+
+// a keyword is the JSON name of a field with its value, e.g. cancer_type=Melanoma
+func keyword(field string, value string) string {
+	return field + "=" + value
+}
+
+// create the index key of a table, readable for the purposes of the index
+// anyone who can read the index key can both add keywords and search, so the purposes must cover the data owners of the table
+func (e *env) createSearchIndex(table string, purposes string, writePurposes string) error {
+	_, found, err := e.fetchEntry(indexTable, indexID(table))
+	if err != nil {
+		return err
+	}
+	if found {
+		return fmt.Errorf("%s already has a search index, a new index key would make the indexed entries unsearchable", table)
+	}
+
+	key := crypto.NewIndexKey()
+	if err := e.modifyEntry(indexTable, key, purposes, writePurposes, indexID(table)); err != nil {
+		return err
+	}
+	e.indexKeys[table] = key
+	return nil
+}
+
+// the index key of a table, decrypted with a key from the keyring that satisfies the policy of the index
+func (e *env) indexKey(table string) ([]byte, error) {
+	if key, found := e.indexKeys[table]; found {
+		return key, nil
+	}
+	record, found, err := e.fetchEntry(indexTable, indexID(table))
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("%s has no search index", table)
+	}
+	data, err := e.decrypt(record)
+	if err != nil {
+		return nil, fmt.Errorf("search index of %s: %w", table, err)
+	}
+
+	var key []byte
+	utils.FromBytes(data, &key)
+	e.indexKeys[table] = key
+	return key, nil
+}
+
+// the keyword tags of an entry, nil if its table has no keyword fields
+func (e *env) entryKeywords(table string, entry any) (*utils.KeywordTags, error) {
+	fields := []string{}
+	for field, mode := range e.schemas[table] {
+		if mode == Keyword {
+			fields = append(fields, field)
+		}
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	values, err := fieldValues(entry)
+	if err != nil {
+		return nil, err
+	}
+	keywords := []string{}
+	for _, field := range fields {
+		if value, found := values[field]; found {
+			keywords = append(keywords, keyword(field, value))
+		}
+	}
+	if len(keywords) == 0 {
+		return nil, nil
+	}
+
+	key, err := e.indexKey(table)
+	if err != nil {
+		return nil, err
+	}
+	tags := crypto.IndexKeywords(key, keywords)
+	return &tags, nil
+}
+
+// the entries of a table whose keyword fields have all the given values, without their data
+func (e *env) searchKeywords(table string, keywords map[string]string) ([]utils.Record, error) {
+	if len(keywords) == 0 {
+		return nil, fmt.Errorf("no keywords to search %s for", table)
+	}
+	key, err := e.indexKey(table)
+	if err != nil {
+		return nil, err
+	}
+
+	trapdoors := [][]byte{}
+	for field, value := range keywords {
+		if e.schemas[table][field] != Keyword {
+			return nil, fmt.Errorf("field %s of %s is not a keyword", field, table)
+		}
+		trapdoors = append(trapdoors, crypto.Trapdoor(key, keyword(field, value)))
+	}
+
+	jsonData := utils.Assure(json.Marshal(map[string][][]byte{"trapdoors": trapdoors}))
+	resp, err := http.Post(fmt.Sprintf("%s/search/%s", cfg.DatabaseURL, table), "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("keyword search failed: %s", body)
+	}
+
+	var records []utils.Record
+	return records, json.Unmarshal(body, &records)
+}
+
+// like getEntry, but a missing entry is not an error
+func (e *env) fetchEntry(table string, recordID uuid.UUID) (utils.Record, bool, error) {
+	var record utils.Record
+	resp, err := http.Get(fmt.Sprintf("%s/entries/%s/%s", cfg.DatabaseURL, table, recordID))
+	if err != nil {
+		return record, false, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return record, false, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return record, false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return record, false, fmt.Errorf("get entry failed: %s", body)
+	}
+	return record, true, json.Unmarshal(body, &record)
+}
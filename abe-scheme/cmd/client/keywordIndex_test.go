@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pzkt/abe-scripts/abe-scheme/internal/crypto"
+	"github.com/pzkt/abe-scripts/abe-scheme/internal/utils"
+	"github.com/pzkt/abe-scripts/generate-pseudodata/generator"
+)
+
+// an in memory stand-in for the entry, write key, search and aggregate endpoints of the database, filters are ignored
+// updates of existing entries have to be signed with their write key, as in the database
+func fakeDatabase(t *testing.T) {
+	var mutex sync.Mutex
+	stored := map[uuid.UUID]utils.Record{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /entries", func(w http.ResponseWriter, r *http.Request) {
+		var record utils.Record
+		utils.Try(json.NewDecoder(r.Body).Decode(&record))
+		mutex.Lock()
+		defer mutex.Unlock()
+		if old, found := stored[record.ID]; found && !crypto.VerifyUpdate(old.PublicWriteKey, record) {
+			http.Error(w, "signature does not match the write key of the entry", http.StatusForbidden)
+			return
+		}
+		stored[record.ID] = record
+	})
+	mux.HandleFunc("GET /write_key/{table}/{id}", func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		record, found := stored[uuid.MustParse(r.PathValue("id"))]
+		mutex.Unlock()
+		if !found || record.Table != r.PathValue("table") {
+			http.Error(w, "record not found", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(utils.Record{PrivateWriteKey: record.PrivateWriteKey, PublicWriteKey: record.PublicWriteKey, Policy: record.Policy})
+	})
+	mux.HandleFunc("GET /entries/{table}/{id}", func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		record, found := stored[uuid.MustParse(r.PathValue("id"))]
+		mutex.Unlock()
+		if !found || record.Table != r.PathValue("table") {
+			http.Error(w, "record not found", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(record)
+	})
+	mux.HandleFunc("GET /aggregate/{table}/{field}", func(w http.ResponseWriter, r *http.Request) {
+		ciphertexts := [][]byte{}
+		mutex.Lock()
+		for _, record := range stored {
+			if ct, found := record.Aggregates[r.PathValue("field")]; found && record.Table == r.PathValue("table") {
+				ciphertexts = append(ciphertexts, ct)
+			}
+		}
+		mutex.Unlock()
+		aggregate, err := crypto.SumAggregateValues(ciphertexts)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string][]byte{"aggregate": aggregate})
+	})
+	mux.HandleFunc("POST /search/{table}", func(w http.ResponseWriter, r *http.Request) {
+		var request struct{ Trapdoors [][]byte }
+		utils.Try(json.NewDecoder(r.Body).Decode(&request))
+		records := []utils.Record{}
+		mutex.Lock()
+		for _, record := range stored {
+			if record.Table == r.PathValue("table") && record.Keywords != nil && crypto.MatchesTrapdoors(*record.Keywords, request.Trapdoors) {
+				records = append(records, record)
+			}
+		}
+		mutex.Unlock()
+		json.NewEncoder(w).Encode(records)
+	})
+
+	server := httptest.NewServer(mux)
+	previous := cfg.DatabaseURL
+	cfg.DatabaseURL = server.URL
+	t.Cleanup(func() {
+		cfg.DatabaseURL = previous
+		server.Close()
+	})
+}
+
+func keywordTestClient(scheme *crypto.ABEscheme, attributes ...string) *env {
+	client := fieldTestClient(scheme, attributes...)
+	client.policyChecked = time.Now()
+	client.entries = make(map[uuid.UUID]Entry)
+	client.indexKeys = make(map[string][]byte)
+	client.analyticsKeys = make(map[string][]byte)
+	client.schemas = map[string]Schema{"table_one": {"cancer_type": Keyword, "cancer_stage": Keyword}}
+	return client
+}
+
+func TestKeywordSearch(t *testing.T) {
+	fakeDatabase(t)
+	scheme := crypto.Setup()
+	owner := keywordTestClient(scheme, "commerce:Admin", "health:Anonymized-Research")
+	researcher := keywordTestClient(scheme, "health:Anonymized-Research")
+	outsider := keywordTestClient(scheme, "commerce:Shipping")
+
+	if err := owner.createSearchIndex("table_one", "Anonymized-Research", "Admin"); err != nil {
+		t.Fatal(err)
+	}
+	if err := owner.createSearchIndex("table_one", "Anonymized-Research", "Admin"); err == nil {
+		t.Error("an existing index key should not be replaced")
+	}
+
+	ids := map[string]uuid.UUID{}
+	for _, cancer := range []struct{ cancerType, stage string }{{"Melanoma", "II"}, {"Melanoma", "IV"}, {"Sarcoma", "II"}} {
+		record := generator.GenerateOncologyRecord("345")
+		record.CancerType, record.CancerStage = cancer.cancerType, cancer.stage
+		id, err := owner.addEntry("table_one", record, "Admin", "Admin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[cancer.cancerType+cancer.stage] = id
+	}
+	//entries without keyword fields are not indexed
+	if _, err := owner.addEntry("table_one", generator.GenerateCardiologyRecord("345"), "Admin", "Admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		keywords map[string]string
+		want     []uuid.UUID
+	}{
+		{map[string]string{"cancer_type": "Melanoma"}, []uuid.UUID{ids["MelanomaII"], ids["MelanomaIV"]}},
+		{map[string]string{"cancer_type": "Melanoma", "cancer_stage": "II"}, []uuid.UUID{ids["MelanomaII"]}},
+		{map[string]string{"cancer_stage": "II"}, []uuid.UUID{ids["MelanomaII"], ids["SarcomaII"]}},
+		{map[string]string{"cancer_type": "Lymphoma"}, []uuid.UUID{}},
+	}
+	for _, tc := range tests {
+		records, err := researcher.searchKeywords("table_one", tc.keywords)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := []uuid.UUID{}
+		for _, record := range records {
+			got = append(got, record.ID)
+		}
+		if !sameIDs(got, tc.want) {
+			t.Errorf("%v: got %v, want %v", tc.keywords, got, tc.want)
+		}
+	}
+
+	if _, err := outsider.searchKeywords("table_one", map[string]string{"cancer_type": "Melanoma"}); err == nil {
+		t.Error("a client without a key for the index policy should not get trapdoors")
+	}
+	if _, err := researcher.searchKeywords("table_one", map[string]string{"notes": "text"}); err == nil {
+		t.Error("only keyword fields should be searchable")
+	}
+	if _, err := owner.findEntries("table_one", map[string]string{"cancer_type": "Melanoma"}, ""); err == nil {
+		t.Error("keywords should not be sent to the database as filters")
+	}
+}
+
+func sameIDs(a []uuid.UUID, b []uuid.UUID) bool {
+	sort := func(ids []uuid.UUID) []string {
+		out := []string{}
+		for _, id := range ids {
+			out = append(out, id.String())
+		}
+		slices.Sort(out)
+		return out
+	}
+	return slices.Equal(sort(a), sort(b))
+}
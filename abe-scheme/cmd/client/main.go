@@ -17,90 +17,181 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"os"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	_ "github.com/lib/pq"
+	"github.com/pzkt/abe-scripts/abe-scheme/internal/config"
 	"github.com/pzkt/abe-scripts/abe-scheme/internal/crypto"
 	"github.com/pzkt/abe-scripts/abe-scheme/internal/utils"
 	"github.com/pzkt/abe-scripts/abe-scheme/internal/utils/policyConfig"
 	"github.com/pzkt/abe-scripts/generate-pseudodata/generator"
 )
 
-type Ops int
-
-const (
-	Less Ops = iota
-	Greater
-	LessOrEqual
-	GreaterOrEqual
-)
-
 type env struct {
-	abeScheme    *crypto.ABEscheme
-	policyConfig policyConfig.Config
-	entries      map[uuid.UUID]Entry
+	abeScheme     *crypto.ABEscheme
+	policyConfig  policyConfig.Config
+	policyChecked time.Time
+	entries       map[uuid.UUID]Entry
+	keyring       *Keyring
+	schemas       map[string]Schema
+	searchKey     *crypto.SearchKey
+	indexKeys     map[string][]byte
+	analyticsKeys map[string][]byte
 }
 
+// how long a policy config is used before checking for a newer version
+const policyConfigRefresh = 10 * time.Second
+
 type Entry struct {
 	Created  time.Time
 	writeKey *ecdsa.PrivateKey
 }
 
-const databaseURL = "http://localhost:8080"
-const authorityURL = "http://localhost:8081"
-
-const authorityUUID = "497dcba3-ecbf-4587-a2dd-5eb0665e6880"
+var cfg = config.Default()
 
 func main() {
+	cfg = utils.Assure(config.Load(os.Args[1:]))
 	env := setup()
-	ABEkey := requestNewKey([]string{"Admin"})
+	env.keyring.AddABEKey(requestNewKey([]string{"Admin"}), time.Time{})
+	//the date can be filtered and sorted by the database, the patient and provider only with the search key
+	//and the cancer type only with trapdoors from the index key of the table. The database adds up heart rates and blood pressures
+	//without learning them, and only keys for the analytics key can decrypt the sums
+	tableOne := Schema{"date": Public, "patient_id": BlindIndexed, "provider_id": Deterministic, "cancer_type": Keyword,
+		"heart_rate": Aggregated, "blood_pressure": Aggregated}
+	env.setSchema("table_one", tableOne)
+	if err := env.createAnalyticsKey("table_one", "Research OR Admin", "Admin"); err != nil {
+		log.Println(err)
+	}
 	record := generator.GenerateCardiologyRecord("345")
-	addedUUID := env.addEntry("table_one", record, "Profiling OR Marketing", "Admin")
+	addedUUID := utils.Assure(env.addEntry("table_one", record, "Profiling OR Marketing", "Admin"))
 
 	fmt.Println("first plaintext")
-	ciphertext := env.getEntry("table_one", addedUUID).Data
-	fmt.Println(string(env.abeScheme.Decrypt(ciphertext, ABEkey)))
+	fmt.Println(string(utils.Assure(env.decrypt(env.getEntry("table_one", addedUUID)))))
 
 	record.PatientID = "wow schgloopy"
 
-	env.modifyEntry("table_one", record, "Profiling OR Marketing", "Admin", addedUUID)
+	utils.Try(env.modifyEntry("table_one", record, "Profiling OR Marketing", "Admin", addedUUID))
 
 	fmt.Println("second plaintext")
-	ciphertext = env.getEntry("table_one", addedUUID).Data
-	fmt.Println(string(env.abeScheme.Decrypt(ciphertext, ABEkey)))
+	fmt.Println(string(utils.Assure(env.decrypt(env.getEntry("table_one", addedUUID)))))
+
+	//a second client with an Admin key modifies the entry with its encrypted write key and rotates it
+	writer := setup()
+	writer.keyring = utils.Assure(OpenKeyring("", ""))
+	writer.keyring.AddABEKey(requestNewKey([]string{"Admin"}), time.Time{})
+	record.PatientID = "modified by an authorized writer"
+	utils.Try(writer.modifyAsAuthorizedWriter("table_one", record, "Profiling OR Marketing", "Admin", TimeBounds{}, addedUUID, true))
+	fmt.Printf("owner rejected after rotation: %v\n", env.modifyEntry("table_one", record, "Profiling OR Marketing", "Admin", addedUUID))
+
+	//the keyring picks the clearance key, the Admin key does not satisfy the policy
+	clearedUUID := utils.Assure(env.addEntry("table_one", record, "Marketing OR clearance >= 3", "Admin"))
+	env.keyring.AddABEKey(requestNewKey([]string{"Shipping"}, "clearance=4"), time.Time{})
+	fmt.Println("plaintext readable with clearance 4")
+	fmt.Println(string(utils.Assure(env.decrypt(env.getEntry("table_one", clearedUUID)))))
+
+	//readable for a day, new keys can not read it afterwards without any revocation
+	boundedUUID := utils.Assure(env.addBoundedEntry("table_one", record, "Admin", "Admin", TimeBounds{Until: time.Now().Add(24 * time.Hour)}))
+	timeKey := env.keyring.AddABEKey(requestTimeKey([]string{"Admin"}))
+	fmt.Printf("time bound plaintext with a key for epoch %d valid until %s\n", timeKey.Epoch, timeKey.Expires.Format(time.RFC3339))
+	fmt.Println(string(utils.Assure(env.decrypt(env.getEntry("table_one", boundedUUID)))))
+	utils.Try(env.keyring.Save())
+
+	//the Admin keys of the keyring read the patient, but neither the contact details nor the medical records
+	patient := NewPatientEntry(generator.GeneratePatient())
+	patient.Records = []any{record}
+	patientUUID := utils.Assure(env.addEntry("table_two", patient, "Admin", "Admin"))
+	var partial PatientEntry
+	unreadable := utils.Assure(env.decryptFields(env.getEntry("table_two", patientUUID), &partial))
+	fmt.Printf("patient %s without the fields %s\n", partial.ID, strings.Join(unreadable, ", "))
+
+	//researchers find oncology records by their cancer type without downloading and decrypting every entry
+	if err := env.createSearchIndex("table_one", "Anonymized-Research OR Admin", "Admin"); err != nil {
+		log.Println(err)
+	}
+	oncology := generator.GenerateOncologyRecord("345")
+	utils.Assure(env.addEntry("table_one", oncology, "Anonymized-Research", "Admin"))
+	researcher := setup()
+	researcher.keyring = utils.Assure(OpenKeyring("", ""))
+	researcher.keyring.AddABEKey(requestNewKey([]string{"Anonymized-Research"}), time.Time{})
+	researcher.setSchema("table_one", tableOne)
+	matches := utils.Assure(researcher.searchKeywords("table_one", map[string]string{"cancer_type": oncology.CancerType}))
+	fmt.Printf("%d %s records found by keyword\n", len(matches), oncology.CancerType)
+
+	heartRate := utils.Assure(env.aggregate("table_one", "heart_rate", nil))
+	fmt.Printf("mean heart rate of %d entries: %.1f\n", heartRate.Count, heartRate.Mean)
+
+	if env.searchKey != nil {
+		found := utils.Assure(env.findEntries("table_one", map[string]string{"patient_id": record.PatientID}, "date"))
+		fmt.Printf("%d entries of patient %s\n", len(found), record.PatientID)
+	}
+
+	readable, lacking := env.readableEntries("table_one", requestNewKey([]string{"Marketing"}))
+	fmt.Printf("%d readable entries\n", len(readable))
+	for id, missing := range lacking {
+		fmt.Printf("entry %s needs %s\n", id, strings.Join(missing, " AND "))
+	}
 }
 
 func setup() *env {
+	//keys are only kept in memory without a keyring file, which needs no passphrase
+	passphrase := ""
+	if cfg.Keyring != "" {
+		passphrase = utils.Assure(keyringPassphrase())
+	}
 	newEnv := env{
-		abeScheme: crypto.Setup(),
-		entries:   make(map[uuid.UUID]Entry),
+		abeScheme:     crypto.Setup(),
+		entries:       make(map[uuid.UUID]Entry),
+		keyring:       utils.Assure(OpenKeyring(cfg.Keyring, passphrase)),
+		schemas:       make(map[string]Schema),
+		searchKey:     searchKeyFromConfig(),
+		indexKeys:     make(map[string][]byte),
+		analyticsKeys: make(map[string][]byte),
 	}
 	newEnv.updatePolicyConfig()
 	return &newEnv
 }
 
-// update the local policy
-func (e *env) updatePolicyConfig() {
-	data := e.getEntry("relations", utils.Assure(uuid.Parse(authorityUUID))).Data
-	utils.FromBytes(data, &e.policyConfig)
+// update the local policy, returns true if a new version was published since the last update
+func (e *env) updatePolicyConfig() bool {
+	data := e.getEntry("relations", utils.Assure(uuid.Parse(cfg.AuthorityUUID))).Data
+	var latest policyConfig.Config
+	utils.FromBytes(data, &latest)
+	e.policyChecked = time.Now()
 
-	//we need to reconnect the parents because serialization forces us to remove cyclical references
-	for _, tree := range e.policyConfig.PurposeTrees {
-		tree.ReconnectParents(nil)
+	if latest.Version == e.policyConfig.Version && e.policyConfig.PurposeTrees != nil {
+		return false
 	}
+	e.policyConfig = latest
+	e.policyConfig.BuildIndex()
 
 	e.abeScheme.PublicKey = e.policyConfig.Scheme.PublicKey
+	return true
 }
 
-func requestNewKey(attributes []string) []byte {
-	req := utils.Assure(http.NewRequest("GET", authorityURL+"/get_key", nil))
+// numeric values are given as name=value, e.g. clearance=3 or expiry=2025-06-30
+func requestNewKey(attributes []string, values ...string) []byte {
+	key, _ := requestKey("/get_key", attributes, values)
+	return key
+}
+
+// a key for the current time epoch, it can read time bound entries until the returned time
+func requestTimeKey(attributes []string, values ...string) ([]byte, time.Time) {
+	return requestKey("/get_time_key", attributes, values)
+}
+
+func requestKey(path string, attributes []string, values []string) ([]byte, time.Time) {
+	req := utils.Assure(http.NewRequest("GET", cfg.AuthorityURL+path, nil))
 
 	q := req.URL.Query()
 	for _, attr := range attributes {
 		q.Add("attribute", attr)
 	}
+	for _, value := range values {
+		q.Add("value", value)
+	}
 	req.URL.RawQuery = q.Encode()
 
 	resp := utils.Assure(http.DefaultClient.Do(req))
@@ -114,22 +205,114 @@ func requestNewKey(attributes []string) []byte {
 
 	key := []byte{}
 	utils.Try(json.Unmarshal(body, &key))
-	return key
+
+	var validUntil time.Time
+	if header := resp.Header.Get("X-Valid-Until"); header != "" {
+		validUntil = utils.Assure(time.Parse(time.RFC3339, header))
+	}
+	return key, validUntil
+}
+
+func (e *env) addEntry(table string, entry any, readPurposes string, writePurposes string) (uuid.UUID, error) {
+	return e.addBoundedEntry(table, entry, readPurposes, writePurposes, TimeBounds{})
 }
 
-func (e *env) addEntry(table string, entry any, readPurposes string, writePurposes string) uuid.UUID {
+// an entry that can only be read with time bound keys within the bounds, e.g. readable until the end of the year
+func (e *env) addBoundedEntry(table string, entry any, readPurposes string, writePurposes string, bounds TimeBounds) (uuid.UUID, error) {
 	newUUID := uuid.New()
-	e.modifyEntry(table, entry, readPurposes, writePurposes, newUUID)
-	return newUUID
+	return newUUID, e.modifyBoundedEntry(table, entry, readPurposes, writePurposes, bounds, newUUID)
+}
+
+func (e *env) modifyEntry(table string, entry any, readPurposes string, writePurposes string, newUUID uuid.UUID) error {
+	return e.modifyBoundedEntry(table, entry, readPurposes, writePurposes, TimeBounds{}, newUUID)
 }
 
-func (e *env) modifyEntry(table string, entry any, readPurposes string, writePurposes string, newUUID uuid.UUID) {
-	fullReadPurposes := toAttr(readPurposes, e.policyConfig)
-	fullWritePurposes := toAttr(writePurposes, e.policyConfig)
+// existing entries are signed with their write key from the keyring, which is kept for the new version
+func (e *env) modifyBoundedEntry(table string, entry any, readPurposes string, writePurposes string, bounds TimeBounds, newUUID uuid.UUID) error {
+	writeKey, found := e.keyring.WriteKey(newUUID)
+	if !found {
+		writeKey = crypto.GenerateSignatureKey()
+	}
+	return e.putEntry(table, entry, readPurposes, writePurposes, bounds, newUUID, writeKey, writeKey)
+}
+
+// modify an entry of another data owner by decrypting its write key with an ABE key from the keyring that satisfies the write policy
+// with rotate the entry gets a new write key, so the previous holders of the write key can no longer modify it
+func (e *env) modifyAsAuthorizedWriter(table string, entry any, readPurposes string, writePurposes string, bounds TimeBounds, recordID uuid.UUID, rotate bool) error {
+	stored, err := e.getWriteKey(table, recordID.String())
+	if err != nil {
+		return err
+	}
+	abeKey, err := e.keyring.KeyFor(stored.Policy.WritePolicy, time.Now())
+	if err != nil {
+		return fmt.Errorf("no write access to record %s: %w", recordID, err)
+	}
+
+	plainWriteKey, err := e.abeScheme.Decrypt(stored.PrivateWriteKey, abeKey)
+	if err != nil {
+		return fmt.Errorf("write key of record %s: %w", recordID, err)
+	}
+	writeKey, err := x509.ParseECPrivateKey(plainWriteKey)
+	if err != nil {
+		return fmt.Errorf("write key of record %s: %w", recordID, err)
+	}
 
-	writeKey := crypto.GenerateSignatureKey()
+	var publicKey ecdsa.PublicKey
+	utils.FromBytes(stored.PublicWriteKey, &publicKey)
+	if writeKey.X.Cmp(publicKey.X) != 0 || writeKey.Y.Cmp(publicKey.Y) != 0 {
+		return fmt.Errorf("write key of record %s does not match its public key", recordID)
+	}
 
-	dataCipher := e.abeScheme.Encrypt(utils.ToBytes(entry), fullReadPurposes)
+	newWriteKey := writeKey
+	if rotate {
+		newWriteKey = crypto.GenerateSignatureKey()
+	}
+	return e.putEntry(table, entry, readPurposes, writePurposes, bounds, recordID, writeKey, newWriteKey)
+}
+
+// encrypt and upload an entry. The database only accepts changes to an existing entry that are signed with its current write key,
+// writeKey is stored with the entry and differs from signingKey when the write key is rotated
+// invalid read or write policies are rejected before anything is encrypted
+func (e *env) putEntry(table string, entry any, readPurposes string, writePurposes string, bounds TimeBounds, newUUID uuid.UUID, signingKey *ecdsa.PrivateKey, writeKey *ecdsa.PrivateKey) error {
+	if time.Since(e.policyChecked) > policyConfigRefresh && e.updatePolicyConfig() {
+		log.Printf("using policy config version %d\n", e.policyConfig.Version)
+	}
+
+	fullReadPurposes, err := toBoundedAttr(readPurposes, e.policyConfig, bounds)
+	if err != nil {
+		return fmt.Errorf("invalid read policy: %w", err)
+	}
+	fullWritePurposes, err := toAttr(writePurposes, e.policyConfig)
+	if err != nil {
+		return fmt.Errorf("invalid write policy: %w", err)
+	}
+
+	metadata, search, err := e.entryFields(table, entry)
+	if err != nil {
+		return err
+	}
+	keywords, err := e.entryKeywords(table, entry)
+	if err != nil {
+		return err
+	}
+	aggregates, err := e.entryAggregates(table, entry)
+	if err != nil {
+		return err
+	}
+
+	//entries with fields that have policies of their own are encrypted field by field
+	var dataCipher []byte
+	var fieldPolicies []utils.FieldPolicy
+	if self, ok := entry.(selfEncrypting); ok {
+		dataCipher, err = self.encrypt(e.abeScheme, fullReadPurposes)
+	} else if fields := taggedFields(entry); fields != nil {
+		dataCipher, fieldPolicies, err = e.encryptFields(entry, fields, fullReadPurposes, bounds)
+	} else {
+		dataCipher, err = e.abeScheme.Encrypt(utils.ToBytes(entry), fullReadPurposes)
+	}
+	if err != nil {
+		return err
+	}
 
 	//custom marshal functions for elliptic curve keys
 	marshaledWriteKey := utils.Assure(x509.MarshalECPrivateKey(writeKey))
@@ -139,17 +322,12 @@ func (e *env) modifyEntry(table string, entry any, readPurposes string, writePur
 	publicKey.Curve = nil
 	marshaledPublicWriteKey := utils.ToBytes(publicKey)
 
-	writeKeyCipher := e.abeScheme.Encrypt(marshaledWriteKey, fullWritePurposes)
-
-	createdTime := time.Now()
-
-	//prevent any part of the record to be tampered with by using all parts to generate the signature
-	var checkSum bytes.Buffer
-	for _, s := range [][]byte{utils.ToBytes(table), newUUID[:], writeKeyCipher, marshaledPublicWriteKey, dataCipher, utils.ToBytes(createdTime)} {
-		checkSum.Write(s)
+	writeKeyCipher, err := e.abeScheme.Encrypt(marshaledWriteKey, fullWritePurposes)
+	if err != nil {
+		return err
 	}
 
-	signature := crypto.Sign(writeKey, checkSum.Bytes())
+	createdTime := time.Now()
 
 	newRecord := utils.Record{
 		Table:           table,
@@ -157,15 +335,31 @@ func (e *env) modifyEntry(table string, entry any, readPurposes string, writePur
 		PrivateWriteKey: writeKeyCipher,
 		PublicWriteKey:  marshaledPublicWriteKey,
 		Data:            dataCipher,
-		Created:         createdTime,
-		Signature:       signature,
+		Policy: utils.PolicyDescriptor{
+			ReadPurposes:  readPurposes,
+			ReadPolicy:    fullReadPurposes,
+			WritePurposes: writePurposes,
+			WritePolicy:   fullWritePurposes,
+			ConfigVersion: e.policyConfig.Version,
+			ReadAfter:     bounds.After,
+			ReadUntil:     bounds.Until,
+			Fields:        fieldPolicies,
+		},
+		Created:    createdTime,
+		Metadata:   metadata,
+		Search:     search,
+		Keywords:   keywords,
+		Aggregates: aggregates,
 	}
 
+	//prevent any part of the record to be tampered with by using all parts to generate the signature
+	newRecord.Signature = crypto.Sign(signingKey, newRecord.Checksum())
+
 	jsonData := utils.Assure(json.Marshal(newRecord))
 
 	//utils.UpdateCSV("new_entries.csv", newUUID.String(), "package size", fmt.Sprint(len(jsonData)))
 
-	resp := utils.Assure(http.Post(databaseURL+"/entries", "application/json", bytes.NewBuffer(jsonData)))
+	resp := utils.Assure(http.Post(cfg.DatabaseURL+"/entries", "application/json", bytes.NewBuffer(jsonData)))
 	defer resp.Body.Close()
 
 	var newEntry = Entry{
@@ -176,14 +370,35 @@ func (e *env) modifyEntry(table string, entry any, readPurposes string, writePur
 	body := utils.Assure(io.ReadAll(resp.Body))
 
 	if resp.StatusCode != http.StatusOK {
-		log.Fatalf("entry add failed: %s", body)
+		return fmt.Errorf("entry add failed: %s", body)
 	}
 
 	e.entries[newUUID] = newEntry
+	if err := e.keyring.AddWriteKey(table, newUUID, writeKey); err != nil {
+		return err
+	}
+	return e.keyring.Save()
+}
+
+// decrypt the data of a record with a key from the keyring that satisfies its read policy
+// records with field level encryption are decrypted with decryptFields
+func (e *env) decrypt(record utils.Record) ([]byte, error) {
+	if len(record.Policy.Fields) > 0 {
+		return nil, fmt.Errorf("record %s is encrypted field by field", record.ID)
+	}
+	key, err := e.keyring.KeyFor(record.Policy.ReadPolicy, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("record %s: %w", record.ID, err)
+	}
+	data, err := e.abeScheme.Decrypt(record.Data, key)
+	if err != nil {
+		return nil, fmt.Errorf("record %s: %w", record.ID, err)
+	}
+	return data, nil
 }
 
 func (e *env) getEntry(table string, recordID uuid.UUID) utils.Record {
-	resp := utils.Assure(http.Get(fmt.Sprintf("%s/entries/%s/%s", databaseURL, table, recordID)))
+	resp := utils.Assure(http.Get(fmt.Sprintf("%s/entries/%s/%s", cfg.DatabaseURL, table, recordID)))
 	defer resp.Body.Close()
 
 	body := utils.Assure(io.ReadAll(resp.Body))
@@ -197,62 +412,59 @@ func (e *env) getEntry(table string, recordID uuid.UUID) utils.Record {
 	return record
 }
 
-func (e *env) getWriteKey(table string, recordID string) utils.Record {
-	resp := utils.Assure(http.Get(fmt.Sprintf("%s/write_key/%s/%s", databaseURL, table, recordID)))
+// all records of a table without their ciphertexts, only the public policy descriptors
+func (e *env) listEntries(table string) []utils.Record {
+	resp := utils.Assure(http.Get(fmt.Sprintf("%s/entries/%s", cfg.DatabaseURL, table)))
 	defer resp.Body.Close()
 
 	body := utils.Assure(io.ReadAll(resp.Body))
 
 	if resp.StatusCode != http.StatusOK {
-		log.Fatalf("get write key failed: %s", body)
+		log.Fatalf("list entries failed: %s", body)
 	}
 
-	var record utils.Record
-	utils.Try(json.Unmarshal(body, &record))
-	return record
+	records := []utils.Record{}
+	utils.Try(json.Unmarshal(body, &records))
+	return records
 }
 
-func generateBitAttributes(value uint, valueSize int) []string {
-	out := []string{}
-	for i := valueSize - 1; i >= 0; i-- {
-		// Shift and mask to get each bit
-		bit := (value >> i) & 1
-		out = append(out, strings.Repeat("*", valueSize-i-1)+fmt.Sprintf("%d", bit)+strings.Repeat("*", i))
+// the records of a table that the key can decrypt, for all others the attributes the key lacks are returned
+func (e *env) readableEntries(table string, key []byte) ([]utils.Record, map[uuid.UUID][]string) {
+	attributes := crypto.KeyAttributes(key)
+	readable := []utils.Record{}
+	lacking := map[uuid.UUID][]string{}
+
+	for _, record := range e.listEntries(table) {
+		_, missing, err := SatisfyingSet(record.Policy.ReadPolicy, attributes)
+		if err != nil {
+			log.Printf("skipping record %s with unreadable policy: %v", record.ID, err)
+			continue
+		}
+		if len(missing) == 0 {
+			readable = append(readable, record)
+		} else {
+			lacking[record.ID] = missing
+		}
 	}
-	return out
+	return readable, lacking
 }
 
-// generate the policy for a given comparison operator and given value
-func generateComparison(value int, valueSize int, op Ops) (string, error) {
-	switch op {
-	case GreaterOrEqual:
-		return generateComparison(value-1, valueSize, Greater)
-	case LessOrEqual:
-		return generateComparison(value+1, valueSize, Less)
-	}
-
-	gates := [2]string{" AND ", " OR "}
-	out := ""
-
-	for i := valueSize - 1; i > 0; i-- {
-		bit := (value >> i) & 1
-		switch bit {
-		case 0:
-			mask := (1 << (i)) - 1
-			if op == Greater && ^(mask&value)&mask == 0 {
-				out += strings.Repeat("*", valueSize-i-1) + fmt.Sprintf("%d", op) + strings.Repeat("*", i)
-				return out, nil
-			}
-			out += strings.Repeat("*", valueSize-i-1) + fmt.Sprintf("%d", op) + strings.Repeat("*", i) + gates[op]
-		case 1:
-			mask := (1 << (i)) - 1
-			if op == Less && mask&value == 0 {
-				out += strings.Repeat("*", valueSize-i-1) + fmt.Sprintf("%d", op) + strings.Repeat("*", i)
-				return out, nil
-			}
-			out += strings.Repeat("*", valueSize-i-1) + fmt.Sprintf("%d", op) + strings.Repeat("*", i) + gates[1-op]
-		}
+// the encrypted write key of an entry, with its public key and policy descriptor
+func (e *env) getWriteKey(table string, recordID string) (utils.Record, error) {
+	var record utils.Record
+	resp, err := http.Get(fmt.Sprintf("%s/write_key/%s/%s", cfg.DatabaseURL, table, recordID))
+	if err != nil {
+		return record, err
 	}
-	out += strings.Repeat("*", valueSize-1) + fmt.Sprintf("%d", op)
-	return out, nil
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return record, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return record, fmt.Errorf("get write key failed: %s", body)
+	}
+
+	return record, json.Unmarshal(body, &record)
 }
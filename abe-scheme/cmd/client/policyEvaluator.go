@@ -0,0 +1,112 @@
+/*
+
+Evaluation of attribute policies against the attributes of a key, without decrypting anything
+Policies are parsed by the ABE scheme itself (crypto.ParsePolicy), so the result matches what decryption would do
+
+*/
+
+package main
+
+import (
+	"slices"
+
+	"github.com/pzkt/abe-scripts/abe-scheme/internal/crypto"
+)
+
+// check if a key with the given attributes can decrypt a ciphertext encrypted under the attribute policy
+func CanDecrypt(policy string, attributes []string) (bool, error) {
+	_, missing, err := SatisfyingSet(policy, attributes)
+	return err == nil && len(missing) == 0, err
+}
+
+// a minimal set of attributes that satisfies the policy, preferring attributes that are already held
+// missing lists the attributes of that set that are not held, it is empty iff the attributes satisfy the policy
+func SatisfyingSet(policy string, attributes []string) (set []string, missing []string, err error) {
+	n, err := crypto.ParsePolicy(policy)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	held := map[string]bool{}
+	for _, a := range attributes {
+		held[a] = true
+	}
+
+	set = cheapestSet(n, held)
+
+	//remove attributes that are not needed, e.g. both sides of an OR that were chosen by different ANDs
+	//attributes that are not held are tried first
+	candidates := []string{}
+	for _, wanted := range []bool{false, true} {
+		for _, a := range set {
+			if held[a] == wanted {
+				candidates = append(candidates, a)
+			}
+		}
+	}
+	for _, a := range candidates {
+		smaller := slices.DeleteFunc(slices.Clone(set), func(s string) bool { return s == a })
+		if satisfies(n, smaller) {
+			set = smaller
+		}
+	}
+
+	for _, a := range set {
+		if !held[a] {
+			missing = append(missing, a)
+		}
+	}
+	return set, missing, nil
+}
+
+// the satisfying set with the fewest attributes that are not held
+// AND and OR gates are thresholds as well, so every gate takes its k cheapest children
+func cheapestSet(n *crypto.PolicyGate, held map[string]bool) []string {
+	if n.Children == nil {
+		return []string{n.Attribute}
+	}
+	sets := [][]string{}
+	for _, c := range n.Children {
+		sets = append(sets, cheapestSet(c, held))
+	}
+	slices.SortFunc(sets, func(a, b []string) int { return compareCost(a, b, held) })
+	set := []string{}
+	for _, s := range sets[:n.Threshold] {
+		set = append(set, s...)
+	}
+	slices.Sort(set)
+	return slices.Compact(set)
+}
+
+// fewer missing attributes first, then smaller sets, then alphabetical to stay deterministic
+func compareCost(a []string, b []string, held map[string]bool) int {
+	missing := func(set []string) int {
+		count := 0
+		for _, s := range set {
+			if !held[s] {
+				count++
+			}
+		}
+		return count
+	}
+	if c := missing(a) - missing(b); c != 0 {
+		return c
+	}
+	if c := len(a) - len(b); c != 0 {
+		return c
+	}
+	return slices.Compare(a, b)
+}
+
+func satisfies(n *crypto.PolicyGate, attributes []string) bool {
+	if n.Children == nil {
+		return slices.Contains(attributes, n.Attribute)
+	}
+	count := 0
+	for _, c := range n.Children {
+		if satisfies(c, attributes) {
+			count++
+		}
+	}
+	return count >= n.Threshold
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/fentec-project/gofe/abe"
+	"github.com/pzkt/abe-scripts/abe-scheme/internal/crypto"
+)
+
+func TestCanDecryptMatchesScheme(t *testing.T) {
+	fame := abe.NewFAME()
+	pubKey, secKey, err := fame.GenerateMasterKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := rand.New(rand.NewSource(2))
+	for tested := 0; tested < 25; {
+		policy := simplify(randomPolicy(r, 4)).String()
+		attributes := []string{}
+		for _, a := range truthTableAttributes {
+			if r.Intn(2) == 0 {
+				attributes = append(attributes, a)
+			}
+		}
+		if len(attributes) == 0 {
+			attributes = append(attributes, "z")
+		}
+
+		msp, err := crypto.PolicyToMSP(policy)
+		if err != nil {
+			t.Fatalf("%s: %v", policy, err)
+		}
+		cipher, err := fame.Encrypt("plaintext", msp, pubKey)
+		if err != nil {
+			// FAME rejects policies that use an attribute more than once
+			continue
+		}
+		tested++
+		key, err := fame.GenerateAttribKeys(attributes, secKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, decryptErr := fame.Decrypt(cipher, key, pubKey)
+
+		ok, err := CanDecrypt(policy, attributes)
+		if err != nil {
+			t.Fatalf("%s: %v", policy, err)
+		}
+		if ok != (decryptErr == nil) {
+			t.Errorf("%s with %v: CanDecrypt %v, decryption error %v", policy, attributes, ok, decryptErr)
+		}
+	}
+}
+
+func TestRightNestedPolicies(t *testing.T) {
+	// the scheme reads "a AND b OR c" as "a AND (b OR c)"
+	if ok, _ := CanDecrypt("a AND b OR c", []string{"a", "c"}); !ok {
+		t.Error("a and c should satisfy a AND (b OR c)")
+	}
+	if ok, _ := CanDecrypt("a AND b OR c", []string{"c"}); ok {
+		t.Error("c alone should not satisfy a AND (b OR c)")
+	}
+}
+
+func TestSatisfyingSet(t *testing.T) {
+	for _, tc := range []struct {
+		policy     string
+		attributes []string
+		set        []string
+		missing    []string
+	}{
+		{"(a AND b) OR c", []string{"a", "b", "c"}, []string{"c"}, nil},
+		{"(a AND b) OR c", []string{"a"}, []string{"c"}, []string{"c"}},
+		{"(a AND b AND d) OR (c AND e)", []string{"a", "b"}, []string{"a", "b", "d"}, []string{"d"}},
+		{"(a OR b) AND (b OR c)", nil, []string{"b"}, []string{"b"}},
+		{"(a OR b) AND (b OR c)", []string{"a", "c"}, []string{"a", "c"}, nil},
+		{"(a AND (b OR c)) OR (d AND e)", []string{"c", "e"}, []string{"a", "c"}, []string{"a"}},
+	} {
+		set, missing, err := SatisfyingSet(tc.policy, tc.attributes)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.policy, err)
+		}
+		if !slices.Equal(set, tc.set) || !slices.Equal(missing, tc.missing) {
+			t.Errorf("%s with %v: got set %v missing %v, want %v missing %v", tc.policy, tc.attributes, set, missing, tc.set, tc.missing)
+		}
+	}
+}
+
+func TestExplainMissingPurpose(t *testing.T) {
+	policy, err := toAttr("Radiology AND Masked-Research", testConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, missing, err := SatisfyingSet(policy, []string{"health:Radiology"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(missing) != 1 {
+		t.Fatalf("a Radiology key should lack a single purpose, got %v", missing)
+	}
+	if ok, _ := CanDecrypt(policy, []string{"health:Radiology", missing[0]}); !ok {
+		t.Errorf("adding the missing purpose %s should satisfy %s", missing[0], policy)
+	}
+}
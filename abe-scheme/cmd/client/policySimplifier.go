@@ -0,0 +1,302 @@
+/*
+
+Boolean simplification of expanded policies
+The result is an equivalent n-ary formula without duplicate or absorbed terms and with common attributes
+factored out. Children are sorted, so equivalent input always produces the same policy string
+
+*/
+
+package main
+
+import (
+	"slices"
+	"strings"
+)
+
+// turn an expanded AST (AND, OR and identifiers) into a minimal, canonical formula
+func simplify(n *Node) *Node {
+	//factoring depends on the shape of the tree, so bring it into a flat, sorted form first
+	n = fixedPoint(normalize(n), false)
+	return fixedPoint(n, true)
+}
+
+func fixedPoint(n *Node, factoring bool) *Node {
+	for {
+		before := n.String()
+		n = simplifyNode(n, factoring)
+		if n.String() == before {
+			return n
+		}
+	}
+}
+
+// identifiers with several values are an OR over single attributes
+func normalize(n *Node) *Node {
+	if n.Type == NodeIdent {
+		if len(n.Values) == 1 {
+			return n
+		}
+		or := &Node{Type: NodeOR}
+		for _, v := range n.Values {
+			or.Children = append(or.Children, &Node{Type: NodeIdent, Values: []string{v}})
+		}
+		return or
+	}
+
+	out := &Node{Type: n.Type, Threshold: n.Threshold}
+	for _, c := range n.Children {
+		out.Children = append(out.Children, normalize(c))
+	}
+	return out
+}
+
+// one simplification pass, simplify repeats it until nothing changes
+func simplifyNode(n *Node, factoring bool) *Node {
+	if n.Type == NodeIdent {
+		return n
+	}
+	if n.Type == NodeThreshold {
+		return simplifyThreshold(n, factoring)
+	}
+
+	//flatten nested gates of the same type
+	children := []*Node{}
+	for _, c := range n.Children {
+		c = simplifyNode(c, factoring)
+		if c.Type == n.Type {
+			children = append(children, c.Children...)
+		} else {
+			children = append(children, c)
+		}
+	}
+
+	children = absorb(n.Type, deduplicate(children))
+	if len(children) == 1 {
+		return children[0]
+	}
+
+	out := &Node{Type: n.Type, Children: children}
+	if factoring {
+		out = factor(out)
+	}
+	slices.SortFunc(out.Children, func(a, b *Node) int {
+		return strings.Compare(a.String(), b.String())
+	})
+	return out
+}
+
+// the children of a threshold are only simplified and sorted, 2 of (a, a, b) is not 2 of (a, b)
+func simplifyThreshold(n *Node, factoring bool) *Node {
+	out := &Node{Type: NodeThreshold, Threshold: n.Threshold}
+	for _, c := range n.Children {
+		out.Children = append(out.Children, simplifyNode(c, factoring))
+	}
+	slices.SortFunc(out.Children, func(a, b *Node) int {
+		return strings.Compare(a.String(), b.String())
+	})
+	if factoring {
+		return factorThreshold(out)
+	}
+	return out
+}
+
+// a term of at least k of the children satisfies a k of n threshold on its own, so k of (a OR b, a OR c, d) = a OR k of (b, c, d)
+// purposes below a common purpose all contain its attributes, which the MSP could otherwise only hold once
+func factorThreshold(n *Node) *Node {
+	counts := map[string]int{}
+	for _, c := range n.Children {
+		for t := range terms(c, NodeAND) {
+			counts[t]++
+		}
+	}
+
+	factored := &Node{Type: NodeOR}
+	remainders := &Node{Type: NodeThreshold, Threshold: n.Threshold}
+	shared := map[string]bool{}
+	for _, c := range n.Children {
+		disjuncts := []*Node{c}
+		if c.Type == NodeOR {
+			disjuncts = c.Children
+		}
+		remainder := &Node{Type: NodeOR}
+		for _, t := range disjuncts {
+			if counts[t.String()] < n.Threshold {
+				remainder.Children = append(remainder.Children, t)
+			} else if !shared[t.String()] {
+				shared[t.String()] = true
+				factored.Children = append(factored.Children, t)
+			}
+		}
+		//a child that is only shared terms can not count once they are false
+		switch len(remainder.Children) {
+		case 0:
+			continue
+		case 1:
+			remainder = remainder.Children[0]
+		}
+		remainders.Children = append(remainders.Children, remainder)
+	}
+	if len(shared) > 0 {
+		if len(remainders.Children) >= n.Threshold {
+			factored.Children = append(factored.Children, remainders)
+		}
+		if len(factored.Children) == 1 {
+			return factored.Children[0]
+		}
+		return factored
+	}
+	return factorCommonConjuncts(n)
+}
+
+// k of (a AND b, a AND c) = a AND k of (b, c), only if every child has the term
+func factorCommonConjuncts(n *Node) *Node {
+	common := map[string]*Node{}
+	for i, c := range n.Children {
+		if c.Type != NodeAND {
+			return n
+		}
+		childTerms := terms(c, NodeOR)
+		if i == 0 {
+			common = childTerms
+			continue
+		}
+		for k := range common {
+			if _, ok := childTerms[k]; !ok {
+				delete(common, k)
+			}
+		}
+	}
+	if len(common) == 0 {
+		return n
+	}
+
+	remainders := &Node{Type: NodeThreshold, Threshold: n.Threshold}
+	for _, c := range n.Children {
+		remainder := &Node{Type: NodeAND}
+		for _, t := range c.Children {
+			if _, ok := common[t.String()]; !ok {
+				remainder.Children = append(remainder.Children, t)
+			}
+		}
+		switch len(remainder.Children) {
+		case 0:
+			//a child that is only the common terms, nothing is left to count
+			return n
+		case 1:
+			remainder = remainder.Children[0]
+		}
+		remainders.Children = append(remainders.Children, remainder)
+	}
+
+	factored := &Node{Type: NodeAND, Children: []*Node{remainders}}
+	for _, t := range common {
+		factored.Children = append(factored.Children, t)
+	}
+	return factored
+}
+
+func deduplicate(nodes []*Node) []*Node {
+	seen := map[string]bool{}
+	out := []*Node{}
+	for _, n := range nodes {
+		if !seen[n.String()] {
+			seen[n.String()] = true
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// the terms of a child of a gate, e.g. the conjuncts of an AND below an OR
+func terms(n *Node, gate NodeType) map[string]*Node {
+	inner := NodeAND + NodeOR - gate
+	if n.Type != inner {
+		return map[string]*Node{n.String(): n}
+	}
+	out := map[string]*Node{}
+	for _, c := range n.Children {
+		out[c.String()] = c
+	}
+	return out
+}
+
+func isSubset(a map[string]*Node, b map[string]*Node) bool {
+	for k := range a {
+		if _, ok := b[k]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// a OR (a AND b) = a and a AND (a OR b) = a
+func absorb(gate NodeType, children []*Node) []*Node {
+	out := []*Node{}
+	for i, c := range children {
+		absorbed := false
+		for j, d := range children {
+			if i != j && isSubset(terms(d, gate), terms(c, gate)) {
+				absorbed = true
+				break
+			}
+		}
+		if !absorbed {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// (a AND b) OR (a AND c) = a AND (b OR c) and (a OR b) AND (a OR c) = a OR (b AND c)
+// only the most frequent common term is factored out per pass, each pass removes at least one leaf
+func factor(n *Node) *Node {
+	inner := NodeAND + NodeOR - n.Type
+
+	counts := map[string]int{}
+	for _, c := range n.Children {
+		for k := range terms(c, n.Type) {
+			counts[k]++
+		}
+	}
+
+	common := ""
+	for k, count := range counts {
+		if count > 1 && (count > counts[common] || count == counts[common] && k < common) {
+			common = k
+		}
+	}
+	if common == "" {
+		return n
+	}
+
+	var commonNode *Node
+	rest := []*Node{}
+	remainders := &Node{Type: n.Type}
+	for _, c := range n.Children {
+		childTerms := terms(c, n.Type)
+		node, ok := childTerms[common]
+		if !ok {
+			rest = append(rest, c)
+			continue
+		}
+		commonNode = node
+
+		//absorption already removed children that consist of the common term only
+		remainder := &Node{Type: inner}
+		for _, t := range c.Children {
+			if t.String() != common {
+				remainder.Children = append(remainder.Children, t)
+			}
+		}
+		if len(remainder.Children) == 1 {
+			remainder = remainder.Children[0]
+		}
+		remainders.Children = append(remainders.Children, remainder)
+	}
+
+	factored := &Node{Type: inner, Children: []*Node{commonNode, remainders}}
+	if len(rest) == 0 {
+		return factored
+	}
+	return &Node{Type: n.Type, Children: append(rest, factored)}
+}
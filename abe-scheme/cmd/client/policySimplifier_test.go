@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/fentec-project/gofe/abe"
+	"github.com/pzkt/abe-scripts/abe-scheme/internal/crypto"
+	"github.com/pzkt/abe-scripts/abe-scheme/internal/utils"
+)
+
+var truthTableAttributes = []string{"a", "b", "c", "d", "e"}
+
+// random AND/OR tree with some 2 of 3 thresholds over the truth table attributes, identifiers may carry several values
+func randomPolicy(r *rand.Rand, depth int) *Node {
+	if depth == 0 || r.Intn(4) == 0 {
+		values := []string{}
+		for i := 0; i < 1+r.Intn(2); i++ {
+			values = append(values, truthTableAttributes[r.Intn(len(truthTableAttributes))])
+		}
+		return &Node{Type: NodeIdent, Values: values}
+	}
+	if r.Intn(6) == 0 {
+		return &Node{
+			Type:      NodeThreshold,
+			Threshold: 2,
+			Children:  []*Node{randomPolicy(r, depth-1), randomPolicy(r, depth-1), randomPolicy(r, depth-1)},
+		}
+	}
+	return &Node{
+		Type:     []NodeType{NodeAND, NodeOR}[r.Intn(2)],
+		Children: []*Node{randomPolicy(r, depth-1), randomPolicy(r, depth-1)},
+	}
+}
+
+func leaves(n *Node) int {
+	if n.Type == NodeIdent {
+		return len(n.Values)
+	}
+	count := 0
+	for _, c := range n.Children {
+		count += leaves(c)
+	}
+	return count
+}
+
+// compare both formulas on every assignment of the truth table attributes
+func equivalent(a *Node, b *Node) (bool, string) {
+	for mask := 0; mask < 1<<len(truthTableAttributes); mask++ {
+		attributes := map[string]bool{}
+		for i, name := range truthTableAttributes {
+			attributes[name] = mask>>i&1 == 1
+		}
+		if evalNode(a, attributes) != evalNode(b, attributes) {
+			return false, fmt.Sprintf("%05b", mask)
+		}
+	}
+	return true, ""
+}
+
+func TestSimplifyTruthTable(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		policy := randomPolicy(r, 5)
+		simplified := simplify(policy.clone())
+
+		if ok, mask := equivalent(policy, simplified); !ok {
+			t.Fatalf("%v simplified to %v differs for assignment %s", policy, simplified, mask)
+		}
+		if leaves(simplified) > leaves(policy) {
+			t.Errorf("%v grew to %v", policy, simplified)
+		}
+		if again := simplify(simplified.clone()); again.String() != simplified.String() {
+			t.Errorf("not idempotent: %v became %v", simplified, again)
+		}
+	}
+}
+
+func TestSimplifyRules(t *testing.T) {
+	for _, tc := range []struct {
+		policy string
+		want   string
+	}{
+		{"a OR (a AND b)", "a"},
+		{"a AND (a OR b)", "a"},
+		{"(a AND b) OR (a AND c)", "((b OR c) AND a)"},
+		{"(a OR b) AND (a OR c)", "((b AND c) OR a)"},
+		{"c AND (b AND a)", "(a AND b AND c)"},
+		{"(b OR a) OR (c OR a)", "(a OR b OR c)"},
+		{"Radiology AND Masked-Research", "(((health:Health-Record OR health:Radiology) AND (health:Masked-Research OR health:Research)) OR health:General-Purpose)"},
+	} {
+		got, err := toAttr(tc.policy, testConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Errorf("%q: got %s, want %s", tc.policy, got, tc.want)
+		}
+	}
+}
+
+func TestSimplifyDeterministic(t *testing.T) {
+	first, err := toAttr("2 of (Radiology, Optometry, Need-To-Know) OR Profiling", testConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		again, _ := toAttr("Profiling OR 2 of (Need-To-Know, Radiology, Optometry)", testConfig)
+		if again != first {
+			t.Fatalf("policy string changed between runs:\n%s\n%s", first, again)
+		}
+	}
+	if strings.Count(first, "health:General-Purpose") != 1 {
+		t.Errorf("common attribute not factored out: %s", first)
+	}
+}
+
+// the simplified policies convert to an MSP and decrypt with exactly the keys that satisfy the policy before simplification
+func TestSimplifiedPoliciesDecrypt(t *testing.T) {
+	scheme := crypto.Setup()
+	r := rand.New(rand.NewSource(3))
+	for _, purposes := range []string{
+		"2 of (Radiology, Optometry, Need-To-Know) OR Profiling",
+		"Profiling OR 2 of (Need-To-Know, Radiology, Optometry)",
+		"Radiology AND Masked-Research",
+		"(Shipping AND Marketing) OR (Shipping AND Profiling)",
+	} {
+		ast, err := NewParser(purposes, testConfig).Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		expanded := expand(ast)
+		policy, err := toAttr(purposes, testConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		msp, err := crypto.PolicyToMSP(policy)
+		if err != nil {
+			t.Fatalf("%s: %v", policy, err)
+		}
+		if !strings.Contains(policy, " of (") {
+			if _, err := abe.BooleanToMSP(policy, false); err != nil {
+				t.Fatalf("%s: %v", policy, err)
+			}
+		}
+		attributes := slices.Clone(msp.RowToAttrib)
+		slices.Sort(attributes)
+		if len(slices.Compact(attributes)) != len(msp.RowToAttrib) {
+			t.Fatalf("%s: attributes on several rows: %v", policy, msp.RowToAttrib)
+		}
+
+		cipher, err := scheme.Encrypt([]byte("plaintext"), policy)
+		if err != nil {
+			t.Fatalf("%s: %v", policy, err)
+		}
+		var fameCipher abe.FAMECipher
+		utils.FromBytes(cipher, &fameCipher)
+
+		for i := 0; i < 16; i++ {
+			held, set := []string{"unrelated"}, map[string]bool{}
+			for _, a := range attributes {
+				if r.Intn(3) == 0 {
+					held, set[a] = append(held, a), true
+				}
+			}
+			key := utils.Assure(scheme.Scheme.GenerateAttribKeys(held, scheme.SecretKey))
+			_, err := scheme.Scheme.Decrypt(&fameCipher, key, scheme.PublicKey)
+			if want := evalNode(expanded, set); (err == nil) != want {
+				t.Errorf("%s with %v: decryption error %v, %q is satisfied: %v", policy, held, err, purposes, want)
+			}
+		}
+	}
+}
@@ -1,6 +1,8 @@
 /*
 
-A simple lexer and parser for turning purpose policies, out of AND and OR gates to attribute policies
+A simple lexer and parser for turning purpose policies, out of AND, OR and NOT gates,
+k-of-n thresholds ("2 of (A, B, C)") and numeric comparisons ("age >= 18", "18 <= age < 65", "expiry > 2025-01-01") to attribute policies
+Purposes can be qualified by the name of their tree ("health:Research" or "health:General-Purpose/Research")
 
 */
 
@@ -8,10 +10,14 @@ package main
 
 import (
 	"fmt"
+	"log"
+	"slices"
+	"strconv"
 	"strings"
 	"unicode"
 	"unicode/utf8"
 
+	"github.com/pzkt/abe-scripts/abe-scheme/internal/utils"
 	"github.com/pzkt/abe-scripts/abe-scheme/internal/utils/policyConfig"
 )
 
@@ -23,8 +29,15 @@ const (
 	TokenError
 	TokenAND
 	TokenOR
+	TokenNOT
+	TokenEXCEPT
+	TokenOF
 	TokenLParen
 	TokenRParen
+	TokenComma
+	TokenCompare
+	TokenNumber
+	TokenDate
 	TokenIdent
 )
 
@@ -35,6 +48,34 @@ type Token struct {
 	Pos   int
 }
 
+// readable token descriptions for error messages
+var tokenNames = map[TokenType]string{
+	TokenEOF:     "end of policy",
+	TokenAND:     "AND",
+	TokenOR:      "OR",
+	TokenNOT:     "NOT",
+	TokenEXCEPT:  "EXCEPT",
+	TokenOF:      "'of'",
+	TokenLParen:  "'('",
+	TokenRParen:  "')'",
+	TokenComma:   "','",
+	TokenCompare: "comparison",
+	TokenNumber:  "number",
+	TokenDate:    "date",
+	TokenIdent:   "purpose",
+}
+
+func (t Token) String() string {
+	switch t.Type {
+	case TokenEOF:
+		return tokenNames[TokenEOF]
+	case TokenError:
+		return t.Value
+	default:
+		return fmt.Sprintf("%q", t.Value)
+	}
+}
+
 // AST Node types
 type NodeType int
 
@@ -42,28 +83,57 @@ const (
 	NodeAND NodeType = iota
 	NodeOR
 	NodeIdent
+	NodeThreshold
+	NodeCompare
 )
 
 // AST Node
 type Node struct {
-	Type     NodeType
-	Values   []string // Only for identifiers
-	Children []*Node  // For operators
+	Type      NodeType
+	Values    []string  // Only for identifiers, the attributes the purpose resolves to
+	Purpose   string    // Only for identifiers, the purpose as written
+	Except    []string  // Only for identifiers, prohibited purposes
+	Children  []*Node   // For operators
+	Threshold int       // Only for thresholds, the k in "k of (...)"
+	Attribute string    // Only for comparisons
+	Op        utils.Ops // Only for comparisons
+	Value     int       // Only for comparisons, dates are days since 1970-01-01
+	Pos       int       // byte offset of the node in the policy
 }
 
 func (n *Node) String() string {
 	switch n.Type {
-	case NodeAND:
-		return fmt.Sprintf("(%v AND %v)", n.Children[0], n.Children[1])
-	case NodeOR:
-		return fmt.Sprintf("(%v OR %v)", n.Children[0], n.Children[1])
+	case NodeAND, NodeOR:
+		children := make([]string, len(n.Children))
+		for i, c := range n.Children {
+			children[i] = c.String()
+		}
+		return "(" + strings.Join(children, map[NodeType]string{NodeAND: " AND ", NodeOR: " OR "}[n.Type]) + ")"
 	case NodeIdent:
 		return strings.Join(n.Values, " | ")
+	case NodeThreshold:
+		children := make([]string, len(n.Children))
+		for i, c := range n.Children {
+			children[i] = c.String()
+		}
+		return fmt.Sprintf("%d of (%s)", n.Threshold, strings.Join(children, ", "))
+	case NodeCompare:
+		return fmt.Sprintf("%s %s %d", n.Attribute, n.Op, n.Value)
 	default:
 		return "UNKNOWN"
 	}
 }
 
+func (n *Node) clone() *Node {
+	c := *n
+	c.Values = append([]string(nil), n.Values...)
+	c.Children = make([]*Node, len(n.Children))
+	for i, child := range n.Children {
+		c.Children[i] = child.clone()
+	}
+	return &c
+}
+
 // Lexer implementation
 type Lexer struct {
 	input  string
@@ -118,6 +188,7 @@ func (l *Lexer) errorf(format string, args ...interface{}) {
 		Value: fmt.Sprintf(format, args...),
 		Pos:   l.start,
 	}
+	l.start = l.pos
 }
 
 func (l *Lexer) run() {
@@ -156,12 +227,22 @@ func lexToken(l *Lexer) stateFn {
 		l.emit(TokenLParen)
 	case r == ')':
 		l.emit(TokenRParen)
+	case r == ',':
+		l.emit(TokenComma)
+	case r == '<' || r == '>':
+		if l.next() != '=' {
+			l.backup()
+		}
+		l.emit(TokenCompare)
+	case unicode.IsDigit(r):
+		l.backup()
+		return lexNumber
 	case unicode.IsLetter(r):
 		l.backup()
 		return lexIdent
 	default:
+		//report the character and keep lexing so every problem in the policy is found
 		l.errorf("unexpected character: %#U", r)
-		return nil
 	}
 
 	return lexWhitespace
@@ -170,7 +251,8 @@ func lexToken(l *Lexer) stateFn {
 func lexIdent(l *Lexer) stateFn {
 	for {
 		r := l.next()
-		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '-' {
+		// ':' and '/' are only part of qualified purposes
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("_-:/", r) {
 			l.backup()
 			break
 		}
@@ -182,6 +264,12 @@ func lexIdent(l *Lexer) stateFn {
 		l.emit(TokenAND)
 	case "OR":
 		l.emit(TokenOR)
+	case "NOT":
+		l.emit(TokenNOT)
+	case "EXCEPT":
+		l.emit(TokenEXCEPT)
+	case "OF":
+		l.emit(TokenOF)
 	default:
 		l.emit(TokenIdent)
 	}
@@ -189,16 +277,62 @@ func lexIdent(l *Lexer) stateFn {
 	return lexWhitespace
 }
 
+// numbers and dates, e.g. 2025-01-31
+func lexNumber(l *Lexer) stateFn {
+	for r := l.next(); unicode.IsDigit(r) || r == '-'; r = l.next() {
+	}
+	l.backup()
+	if strings.Contains(l.input[l.start:l.pos], "-") {
+		l.emit(TokenDate)
+	} else {
+		l.emit(TokenNumber)
+	}
+	return lexWhitespace
+}
+
+// a single problem found in a policy
+type PolicyProblem struct {
+	Pos      int      // byte offset into the policy
+	Message  string   // what went wrong
+	Expected []string // tokens that would have been accepted at Pos
+}
+
+// all problems found in a policy
+type PolicyError struct {
+	Policy   string
+	Problems []PolicyProblem
+}
+
+// every problem is printed with a caret under the offending input
+func (e *PolicyError) Error() string {
+	var b strings.Builder
+	for i, problem := range e.Problems {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		fmt.Fprintf(&b, "policy error at offset %d: %s", problem.Pos, problem.Message)
+		if len(problem.Expected) > 0 {
+			fmt.Fprintf(&b, " (expected %s)", strings.Join(problem.Expected, " or "))
+		}
+		column := utf8.RuneCountInString(e.Policy[:min(problem.Pos, len(e.Policy))])
+		fmt.Fprintf(&b, "\n\t%s\n\t%s^", e.Policy, strings.Repeat(" ", column))
+	}
+	return b.String()
+}
+
 type Parser struct {
-	pc     policyConfig.Config
-	lexer  *Lexer
-	token  Token
-	peek   Token
-	errors []string
+	pc       policyConfig.Config
+	input    string
+	lexer    *Lexer
+	token    Token
+	peek     Token
+	errors   []PolicyProblem
+	warnings []PolicyProblem
 }
 
 func NewParser(input string, pc policyConfig.Config) *Parser {
 	p := &Parser{
+		input: input,
 		lexer: NewLexer(input),
 	}
 	p.nextToken()
@@ -207,25 +341,52 @@ func NewParser(input string, pc policyConfig.Config) *Parser {
 	return p
 }
 
+// advance to the next token, lexer errors are recorded and skipped
 func (p *Parser) nextToken() {
 	p.token = p.peek
 	p.peek = p.lexer.NextToken()
+	for p.peek.Type == TokenError {
+		p.errors = append(p.errors, PolicyProblem{Pos: p.peek.Pos, Message: p.peek.Value})
+		p.peek = p.lexer.NextToken()
+	}
+}
+
+func (p *Parser) error(pos int, msg string, expected ...TokenType) {
+	problem := PolicyProblem{Pos: pos, Message: msg}
+	for _, t := range expected {
+		problem.Expected = append(problem.Expected, tokenNames[t])
+	}
+	p.errors = append(p.errors, problem)
+}
+
+// problems that do not make the policy invalid, e.g. ambiguous purposes
+func (p *Parser) Warnings() []PolicyProblem {
+	return p.warnings
 }
 
-func (p *Parser) error(msg string) {
-	p.errors = append(p.errors, msg)
+// report that the current token is not one of the expected ones
+func (p *Parser) unexpected(expected ...TokenType) {
+	p.error(p.token.Pos, fmt.Sprintf("unexpected %v", p.token), expected...)
 }
 
-func (p *Parser) Parse() *Node {
+func (p *Parser) Parse() (*Node, error) {
 	node := p.parseExpression()
 	if p.token.Type != TokenEOF {
-		p.error("expected EOF")
+		p.unexpected(TokenAND, TokenOR, TokenEOF)
+	}
+	if node != nil {
+		p.checkComparisons(node)
 	}
+
+	//drain the lexer so its goroutine can finish
+	for p.token.Type != TokenEOF {
+		p.nextToken()
+	}
+
 	if len(p.errors) > 0 {
-		fmt.Printf("Parser errors:\n%s\n", strings.Join(p.errors, "\n"))
-		return nil
+		return nil, &PolicyError{Policy: p.input, Problems: p.errors}
 	}
-	return node
+	return node, nil
 }
 
 func (p *Parser) parseExpression() *Node {
@@ -248,11 +409,11 @@ func (p *Parser) parseOR() *Node {
 }
 
 func (p *Parser) parseAND() *Node {
-	node := p.parsePrimary()
+	node := p.parseExcept()
 
 	for p.token.Type == TokenAND {
 		p.nextToken()
-		right := p.parsePrimary()
+		right := p.parseExcept()
 		node = &Node{
 			Type:     NodeAND,
 			Children: []*Node{node, right},
@@ -265,174 +426,395 @@ func (p *Parser) parseAND() *Node {
 func (p *Parser) parsePrimary() *Node {
 	switch p.token.Type {
 	case TokenIdent:
+		if p.peek.Type == TokenCompare {
+			return p.parseComparison()
+		}
 		node := &Node{
-			Type:   NodeIdent,
-			Values: p.pc.ResolvePurpose(p.token.Value),
+			Type:    NodeIdent,
+			Values:  p.pc.ResolvePurpose(p.token.Value),
+			Purpose: p.token.Value,
+			Pos:     p.token.Pos,
 		}
+		p.checkPurpose(node)
 		p.nextToken()
 		return node
+	case TokenNumber:
+		if p.peek.Type == TokenCompare {
+			return p.parseRange()
+		}
+		return p.parseThreshold()
+	case TokenDate:
+		return p.parseRange()
+	case TokenNOT:
+		p.nextToken()
+		operand := p.parsePrimary()
+		if operand == nil {
+			return nil
+		}
+		return p.negate(operand)
 	case TokenLParen:
 		p.nextToken()
 		expr := p.parseExpression()
 		if p.token.Type != TokenRParen {
-			p.error("expected ')'")
-			return nil
+			p.unexpected(TokenAND, TokenOR, TokenRParen)
+			return expr
 		}
 		p.nextToken()
 		return expr
 	default:
-		p.error(fmt.Sprintf("unexpected token: %v", p.token))
+		p.unexpected(TokenIdent, TokenNumber, TokenNOT, TokenLParen)
+		p.skipUnexpected()
 		return nil
 	}
 }
 
-func reduce(n *Node) *Node {
+// qualified purposes have to exist, bare purposes that appear in several trees are ambiguous
+func (p *Parser) checkPurpose(n *Node) {
+	if _, err := p.pc.Lookup(n.Purpose); err != nil {
+		p.error(n.Pos, err.Error())
+		return
+	}
+	if trees := p.pc.Ambiguous(n.Purpose); trees != nil {
+		p.warnings = append(p.warnings, PolicyProblem{
+			Pos:     n.Pos,
+			Message: fmt.Sprintf("%s appears in the trees %s and refers to all of them, qualify it e.g. as %s:%s", n.Purpose, strings.Join(trees, ", "), trees[0], n.Purpose),
+		})
+	}
+}
 
-	if len(n.Children) == 2 && n.Children[0].Type == NodeIdent && n.Children[1].Type == NodeIdent {
-		switch n.Type {
-		case NodeOR:
-			uniqueMap := make(map[string]struct{})
-			for _, item := range n.Children[0].Values {
-				uniqueMap[item] = struct{}{}
-			}
-			for _, item := range n.Children[1].Values {
-				uniqueMap[item] = struct{}{}
+// prohibited purposes, e.g. "Marketing EXCEPT Third-Party" or "(Admin OR Marketing) EXCEPT (Profiling, Phone)"
+// the prohibition applies to every purpose of the operand, comparisons are not affected
+func (p *Parser) parseExcept() *Node {
+	node := p.parsePrimary()
+	if p.token.Type != TokenEXCEPT {
+		return node
+	}
+	p.nextToken()
+
+	prohibited := []string{}
+	if p.token.Type == TokenLParen {
+		p.nextToken()
+		for {
+			if p.token.Type != TokenIdent {
+				p.unexpected(TokenIdent)
+				p.skipUnexpected()
+				return node
 			}
-			mergedArray := make([]string, 0, len(uniqueMap))
-			for key := range uniqueMap {
-				mergedArray = append(mergedArray, key)
+			prohibited = append(prohibited, p.token.Value)
+			p.nextToken()
+			if p.token.Type != TokenComma {
+				break
 			}
+			p.nextToken()
+		}
+		if p.token.Type != TokenRParen {
+			p.unexpected(TokenComma, TokenRParen)
+			return node
+		}
+		p.nextToken()
+	} else if p.token.Type == TokenIdent {
+		prohibited = append(prohibited, p.token.Value)
+		p.nextToken()
+	} else {
+		p.unexpected(TokenIdent, TokenLParen)
+		p.skipUnexpected()
+		return node
+	}
 
-			n.Type = NodeIdent
-			n.Children = nil
-			n.Values = mergedArray
-		case NodeAND:
-			elementMap := make(map[string]struct{})
-			for _, item := range n.Children[0].Values {
-				elementMap[item] = struct{}{}
-			}
+	if node != nil {
+		p.prohibit(node, prohibited)
+	}
+	return node
+}
 
-			// Create slices for the results
-			matchingElements := []string{}
-			firstArr := []string{}
-			secondArr := []string{}
-
-			for _, item := range n.Children[1].Values {
-				if _, exists := elementMap[item]; exists {
-					matchingElements = append(matchingElements, item)
-				} else {
-					secondArr = append(secondArr, item)
-				}
-			}
+func (p *Parser) prohibit(n *Node, prohibited []string) {
+	for _, c := range n.Children {
+		p.prohibit(c, prohibited)
+	}
+	if n.Type != NodeIdent {
+		return
+	}
 
-			for _, item := range n.Children[0].Values {
-				if _, exists := elementMap[item]; !exists || !contains(matchingElements, item) {
-					firstArr = append(firstArr, item)
-				}
-			}
+	n.Except = append(n.Except, prohibited...)
+	n.Values = p.pc.ResolveIntendedPurposes([]string{n.Purpose}, n.Except)
+	if len(n.Values) == 0 {
+		p.error(n.Pos, fmt.Sprintf("%s EXCEPT %s leaves no purpose that may access the data (%s inheritance)",
+			n.Purpose, strings.Join(n.Except, ", "), p.pc.Inheritance))
+	}
+}
 
-			if len(matchingElements) == 0 {
-				return n
-			}
+// skip the offending token unless an enclosing rule can continue from it, this avoids follow-up errors
+func (p *Parser) skipUnexpected() {
+	switch p.token.Type {
+	case TokenEOF, TokenRParen, TokenComma, TokenAND, TokenOR:
+	default:
+		p.nextToken()
+	}
+}
 
-			if len(firstArr) == 0 || len(secondArr) == 0 {
-				n.Type = NodeIdent
-				n.Children = nil
-				if len(firstArr) == 0 {
-					n.Values = append(matchingElements, secondArr...)
-				} else {
-					n.Values = append(matchingElements, firstArr...)
-				}
-				return n
-			}
+// attribute comparison, e.g. "age >= 18" or "expiry < 2030-01-01"
+func (p *Parser) parseComparison() *Node {
+	node := &Node{
+		Type:      NodeCompare,
+		Attribute: p.token.Value,
+		Pos:       p.token.Pos,
+	}
+	if node.Attribute == utils.TimeAttribute {
+		p.error(node.Pos, fmt.Sprintf("%s is reserved for the epoch of time bound keys, use time bounds instead", node.Attribute))
+	}
+	p.nextToken()
+	node.Op = p.compareOp()
+	p.nextToken()
 
-			leftNode := Node{Type: NodeIdent, Values: matchingElements}
+	value, ok := p.numericValue()
+	if !ok {
+		return nil
+	}
+	node.Value = value
+	return node
+}
 
-			rlNode := Node{Type: NodeIdent, Values: firstArr}
-			rrNode := Node{Type: NodeIdent, Values: secondArr}
-			rightNode := Node{Type: NodeAND, Children: []*Node{&rlNode, &rrNode}}
+// range of an attribute, e.g. "18 <= age < 65", the same as "age >= 18 AND age < 65"
+func (p *Parser) parseRange() *Node {
+	start := p.token.Pos
+	low, ok := p.numericValue()
+	if !ok {
+		return nil
+	}
+	if p.token.Type != TokenCompare {
+		p.unexpected(TokenCompare)
+		return nil
+	}
+	op := p.compareOp()
+	p.nextToken()
 
-			n.Type = NodeOR
-			n.Children = []*Node{&leftNode, &rightNode}
-			n.Values = nil
-		}
-	} else {
-		if len(n.Children) > 0 && n.Children[0].Type != NodeIdent {
-			n.Children[0] = reduce(n.Children[0])
-		}
-		if len(n.Children) > 1 && n.Children[1].Type != NodeIdent {
-			n.Children[1] = reduce(n.Children[1])
-		}
-		//n = reduce(n)
+	if p.token.Type != TokenIdent || p.peek.Type != TokenCompare {
+		p.unexpected(TokenIdent)
+		p.skipUnexpected()
+		return nil
 	}
-	return n
+	lower := &Node{Type: NodeCompare, Attribute: p.token.Value, Op: op.Mirror(), Value: low, Pos: start}
+	upper := p.parseComparison()
+	if upper == nil {
+		return nil
+	}
+	return &Node{Type: NodeAND, Children: []*Node{lower, upper}, Pos: start}
 }
 
-func contains(slice []string, item string) bool {
-	for _, v := range slice {
-		if v == item {
-			return true
+func (p *Parser) compareOp() utils.Ops {
+	for _, op := range []utils.Ops{utils.Less, utils.Greater, utils.LessOrEqual, utils.GreaterOrEqual} {
+		if op.String() == p.token.Value {
+			return op
 		}
 	}
-	return false
+	return utils.Less
 }
 
-func resolveAllPurposes(n *Node) *Node {
-	switch n.Type {
-	case NodeOR, NodeAND:
-		n.Children[0] = resolveAllPurposes(n.Children[0])
-		n.Children[1] = resolveAllPurposes(n.Children[1])
-	case NodeIdent:
-		return resolvePurposeArray(n.Values)
+// the number or date at the current token
+func (p *Parser) numericValue() (int, bool) {
+	if p.token.Type != TokenNumber && p.token.Type != TokenDate {
+		p.unexpected(TokenNumber, TokenDate)
+		p.skipUnexpected()
+		return 0, false
 	}
-	return n
+	value, err := utils.ParseNumericValue(p.token.Value)
+	if err != nil {
+		p.error(p.token.Pos, err.Error())
+		p.nextToken()
+		return 0, false
+	}
+	p.nextToken()
+	return value, true
 }
 
-func resolvePurposeArray(purposes []string) *Node {
-	if len(purposes) == 0 {
+// comparisons that no value satisfies, e.g. "age < 0", can not be turned into a policy
+// this is only known after NOT has been pushed down
+func (p *Parser) checkComparisons(n *Node) {
+	for _, c := range n.Children {
+		if c != nil {
+			p.checkComparisons(c)
+		}
+	}
+	if n.Type != NodeCompare {
+		return
+	}
+	if _, err := utils.Comparison(n.Attribute, n.Value, utils.ComparisonValueSize, n.Op); err != nil {
+		p.error(n.Pos, err.Error())
+	}
+}
+
+// threshold gate, e.g. "2 of (Radiology, Optometry, Need-To-Know)"
+func (p *Parser) parseThreshold() *Node {
+	start := p.token
+	k, err := strconv.Atoi(p.token.Value)
+	if err != nil {
+		p.error(p.token.Pos, fmt.Sprintf("invalid threshold %s", p.token.Value))
+	}
+	p.nextToken()
+
+	if p.token.Type != TokenOF {
+		p.unexpected(TokenOF)
 		return nil
 	}
+	p.nextToken()
 
-	//remove duplicates
-	seen := make(map[string]bool)
-	result := []string{}
+	if p.token.Type != TokenLParen {
+		p.unexpected(TokenLParen)
+		return nil
+	}
+	p.nextToken()
 
-	for _, str := range purposes {
-		if !seen[str] {
-			seen[str] = true
-			result = append(result, str)
+	node := &Node{Type: NodeThreshold, Threshold: k, Pos: start.Pos}
+	valid := err == nil
+	for {
+		child := p.parseExpression()
+		valid = valid && child != nil
+		node.Children = append(node.Children, child)
+		if p.token.Type != TokenComma {
+			break
 		}
+		p.nextToken()
 	}
 
-	//loop over purposes
-	var root *Node
-	for i, ident := range result {
-		current := &Node{
-			Type:   NodeIdent,
-			Values: []string{ident},
+	if p.token.Type != TokenRParen {
+		p.unexpected(TokenComma, TokenRParen)
+		return nil
+	}
+	p.nextToken()
+
+	if !valid {
+		return nil
+	}
+	if k < 1 || k > len(node.Children) {
+		p.error(start.Pos, fmt.Sprintf("threshold must be between 1 and %d, got %d", len(node.Children), k))
+		return nil
+	}
+	return node
+}
+
+// push a NOT down to the leaves. ABE policies are monotone, so only comparisons can be negated
+func (p *Parser) negate(n *Node) *Node {
+	switch n.Type {
+	case NodeAND, NodeOR:
+		n.Type = NodeOR + NodeAND - n.Type
+		for i, c := range n.Children {
+			n.Children[i] = p.negate(c)
+		}
+	case NodeThreshold:
+		// fewer than k satisfied children means at least n-k+1 unsatisfied ones
+		n.Threshold = len(n.Children) - n.Threshold + 1
+		for i, c := range n.Children {
+			n.Children[i] = p.negate(c)
 		}
+	case NodeCompare:
+		n.Op = n.Op.Negate()
+	case NodeIdent:
+		p.error(n.Pos, fmt.Sprintf("cannot negate %s: only comparisons can be negated", strings.Join(n.Values, " | ")))
+	}
+	return n
+}
 
-		if i == 0 {
-			root = current
-		} else {
-			root = &Node{
-				Type:     NodeOR,
-				Children: []*Node{root, current},
-			}
+// turn comparisons into AND and OR gates and thresholds that can be converted to an MSP
+// 1 of n and n of n thresholds become OR and AND gates, the others stay thresholds, see crypto.PolicyToMSP
+func expand(n *Node) *Node {
+	for i, c := range n.Children {
+		n.Children[i] = expand(c)
+	}
+
+	switch n.Type {
+	case NodeThreshold:
+		switch n.Threshold {
+		case 1:
+			return &Node{Type: NodeOR, Children: n.Children, Pos: n.Pos}
+		case len(n.Children):
+			return &Node{Type: NodeAND, Children: n.Children, Pos: n.Pos}
 		}
+	case NodeCompare:
+		policy, _ := utils.Comparison(n.Attribute, n.Value, utils.ComparisonValueSize, n.Op)
+		return comparisonToNode(strings.Fields(policy))
 	}
+	return n
+}
 
-	return root
+func join(t NodeType, left *Node, right *Node) *Node {
+	if left == nil {
+		return right
+	}
+	return &Node{Type: t, Children: []*Node{left, right}}
+}
+
+// utils.Comparison chains its gates without brackets, every gate binds everything to its right
+func comparisonToNode(fields []string) *Node {
+	leaf := &Node{Type: NodeIdent, Values: []string{fields[0]}}
+	if len(fields) < 3 {
+		return leaf
+	}
+	gate := NodeOR
+	if fields[1] == "AND" {
+		gate = NodeAND
+	}
+	return &Node{Type: gate, Children: []*Node{leaf, comparisonToNode(fields[2:])}}
 }
 
 // use the purpose hierarchy to turn a purpose policy into attribute policies
-func toAttr(purposes string, policyConfig policyConfig.Config) string {
+// invalid policies are reported as a *PolicyError
+func toAttr(purposes string, policyConfig policyConfig.Config) (string, error) {
+	return toBoundedAttr(purposes, policyConfig, TimeBounds{})
+}
+
+// the attribute policy of a purpose policy that is only satisfied by time bound keys within the bounds
+func toBoundedAttr(purposes string, policyConfig policyConfig.Config, bounds TimeBounds) (string, error) {
 	parser := NewParser(purposes, policyConfig)
-	ast := parser.Parse()
-	//reduce until no changes
-	for ast.String() != reduce(ast).String() {
-		ast = reduce(ast)
+	ast, err := parser.Parse()
+	if err != nil {
+		return "", err
+	}
+	for _, w := range parser.Warnings() {
+		log.Printf("policy warning at offset %d: %s\n", w.Pos, w.Message)
+	}
+	ast = expand(ast)
+
+	timeBound, err := bounds.node(policyConfig.TimeEpoch)
+	if err != nil {
+		return "", err
+	}
+	if timeBound != nil {
+		ast = join(NodeAND, ast, timeBound)
+	}
+
+	//a smaller formula results in a smaller MSP and therefore a smaller ciphertext
+	ast = simplify(ast)
+
+	//FAME allows every attribute on a single row of the MSP only
+	if repeated := repeatedAttributes(ast); len(repeated) > 0 {
+		return "", &PolicyError{Policy: purposes, Problems: []PolicyProblem{{
+			Message: fmt.Sprintf("the policy needs %s more than once, which the ABE scheme does not support. "+
+				"This happens in a k of (...) threshold when fewer than k of its purposes share an ancestor", strings.Join(repeated, ", ")),
+		}}}
+	}
+	return ast.String(), nil
+}
+
+// attributes that appear more than once in an expanded policy, sorted
+func repeatedAttributes(n *Node) []string {
+	counts := map[string]int{}
+	var count func(n *Node)
+	count = func(n *Node) {
+		for _, v := range n.Values {
+			counts[v]++
+		}
+		for _, c := range n.Children {
+			count(c)
+		}
+	}
+	count(n)
+
+	repeated := []string{}
+	for a, c := range counts {
+		if c > 1 {
+			repeated = append(repeated, a)
+		}
 	}
-	//return the resolved version of Ident Nodes
-	return resolveAllPurposes(ast).String()
+	slices.Sort(repeated)
+	return repeated
 }
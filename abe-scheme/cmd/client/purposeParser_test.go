@@ -0,0 +1,391 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/fentec-project/gofe/abe"
+	"github.com/pzkt/abe-scripts/abe-scheme/internal/crypto"
+	"github.com/pzkt/abe-scripts/abe-scheme/internal/utils"
+	"github.com/pzkt/abe-scripts/abe-scheme/internal/utils/policyConfig"
+)
+
+var testConfig = indexed(policyConfig.Config{PurposeTrees: utils.ExamplePurposeTrees()})
+
+func indexed(c policyConfig.Config) policyConfig.Config {
+	c.BuildIndex()
+	return c
+}
+
+// evaluate an expanded AST (only AND, OR, thresholds and identifiers) for the given attributes
+func evalNode(n *Node, attributes map[string]bool) bool {
+	switch n.Type {
+	case NodeAND:
+		for _, c := range n.Children {
+			if !evalNode(c, attributes) {
+				return false
+			}
+		}
+		return true
+	case NodeOR:
+		for _, c := range n.Children {
+			if evalNode(c, attributes) {
+				return true
+			}
+		}
+		return false
+	case NodeThreshold:
+		count := 0
+		for _, c := range n.Children {
+			if evalNode(c, attributes) {
+				count++
+			}
+		}
+		return count >= n.Threshold
+	case NodeIdent:
+		for _, v := range n.Values {
+			if attributes[v] {
+				return true
+			}
+		}
+		return false
+	}
+	panic(fmt.Sprintf("unexpanded node: %v", n))
+}
+
+func parseExpanded(t *testing.T, policy string) *Node {
+	t.Helper()
+	ast, err := NewParser(policy, testConfig).Parse()
+	if err != nil {
+		t.Fatalf("failed to parse %q: %v", policy, err)
+	}
+	return expand(ast)
+}
+
+func attributeSet(attributes ...string) map[string]bool {
+	out := map[string]bool{}
+	for _, a := range attributes {
+		out[a] = true
+	}
+	return out
+}
+
+func TestThreshold(t *testing.T) {
+	ast := parseExpanded(t, "2 of (a, b, c)")
+	for mask := 0; mask < 8; mask++ {
+		attributes := map[string]bool{"a": mask&1 != 0, "b": mask&2 != 0, "c": mask&4 != 0}
+		count := mask&1 + mask>>1&1 + mask>>2&1
+		if got := evalNode(ast, attributes); got != (count >= 2) {
+			t.Errorf("2 of 3 with mask %03b: got %v", mask, got)
+		}
+	}
+}
+
+func TestComparison(t *testing.T) {
+	for _, tc := range []struct {
+		policy string
+		holds  func(int) bool
+	}{
+		{"age >= 18", func(v int) bool { return v >= 18 }},
+		{"age < 65", func(v int) bool { return v < 65 }},
+		{"NOT age < 65", func(v int) bool { return v >= 65 }},
+		{"age > 17 AND NOT age > 64", func(v int) bool { return v > 17 && v <= 64 }},
+		{"18 <= age < 65", func(v int) bool { return v >= 18 && v < 65 }},
+		{"65 > age > 17", func(v int) bool { return v > 17 && v < 65 }},
+		{"NOT 18 <= age < 65", func(v int) bool { return v < 18 || v >= 65 }},
+		{"age <= 65535", func(v int) bool { return true }},
+		{"NOT age < 0", func(v int) bool { return true }},
+		{"age > 65534", func(v int) bool { return v == 65535 }},
+		{"age < 1", func(v int) bool { return v == 0 }},
+		{"age >= 1970-01-03 AND age < 1970-03-01", func(v int) bool { return v >= 2 && v < 59 }},
+	} {
+		ast := parseExpanded(t, tc.policy)
+		for _, v := range []int{0, 1, 2, 17, 18, 19, 58, 59, 64, 65, 66, 99, 32767, 32768, 65534, 65535} {
+			attributes := map[string]bool{}
+			for _, bit := range utils.BitAttributes("age", uint(v), utils.ComparisonValueSize) {
+				attributes[bit] = true
+			}
+			if got := evalNode(ast, attributes); got != tc.holds(v) {
+				t.Errorf("%q with age %d: got %v", tc.policy, v, got)
+			}
+		}
+	}
+}
+
+func TestNegatedThreshold(t *testing.T) {
+	ast := parseExpanded(t, "NOT 2 of (a < 1, b < 1, c < 1)")
+	for mask := 0; mask < 8; mask++ {
+		// an attribute with value 1 does not satisfy "x < 1"
+		attributes := map[string]bool{}
+		below := 0
+		for i, name := range []string{"a", "b", "c"} {
+			value := uint(mask >> i & 1)
+			below += 1 - int(value)
+			for _, bit := range utils.BitAttributes(name, value, utils.ComparisonValueSize) {
+				attributes[bit] = true
+			}
+		}
+		if got := evalNode(ast, attributes); got != (below < 2) {
+			t.Errorf("mask %03b: got %v", mask, got)
+		}
+	}
+}
+
+func TestInvalidPolicies(t *testing.T) {
+	for _, policy := range []string{
+		"NOT Admin",
+		"4 of (a, b, c)",
+		"age >= 70000",
+		"age < 0",
+		"age > 65535",
+		"NOT age <= 65535",
+		"18 <= age",
+		"18 <= Admin",
+		"expiry < 2024-13-01",
+		"expiry < 1969-12-31",
+		"2 of (a, b",
+	} {
+		if ast, err := NewParser(policy, testConfig).Parse(); err == nil {
+			t.Errorf("%q should be rejected, got %v", policy, ast)
+		}
+	}
+}
+
+func TestPolicyErrorPositions(t *testing.T) {
+	for _, tc := range []struct {
+		policy    string
+		positions []int
+		expected  []string
+	}{
+		{"Admin AND", []int{9}, []string{"purpose"}},
+		{"Admin & Shipping", []int{6, 8}, nil},
+		{"(Admin OR Shipping", []int{18}, []string{"')'"}},
+		{"Admin OR OR Shipping AND", []int{9, 24}, []string{"purpose", "purpose"}},
+		{"2 of (Admin, NOT Shipping)", []int{17}, nil},
+		{"age >= x", []int{7}, []string{"number"}},
+	} {
+		_, err := NewParser(tc.policy, testConfig).Parse()
+		var policyErr *PolicyError
+		if !errors.As(err, &policyErr) {
+			t.Errorf("%q: expected a PolicyError, got %v", tc.policy, err)
+			continue
+		}
+		if len(policyErr.Problems) != len(tc.positions) {
+			t.Errorf("%q: expected %d problems, got:\n%v", tc.policy, len(tc.positions), err)
+			continue
+		}
+		for i, problem := range policyErr.Problems {
+			if problem.Pos != tc.positions[i] {
+				t.Errorf("%q: problem %d at offset %d, expected %d", tc.policy, i, problem.Pos, tc.positions[i])
+			}
+			if tc.expected != nil && !slices.Contains(problem.Expected, tc.expected[i]) {
+				t.Errorf("%q: problem %d expects %v, missing %s", tc.policy, i, problem.Expected, tc.expected[i])
+			}
+		}
+	}
+}
+
+func TestPolicyErrorRendering(t *testing.T) {
+	_, err := toAttr("Admin AND (Shipping OR)", testConfig)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	want := "policy error at offset 22: unexpected \")\" (expected purpose or number or NOT or '(')\n" +
+		"\tAdmin AND (Shipping OR)\n" +
+		"\t                      ^"
+	if err.Error() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", err, want)
+	}
+}
+
+func TestPurposeResolution(t *testing.T) {
+	ast := parseExpanded(t, "Radiology AND Masked-Research")
+	if !evalNode(ast, attributeSet("health:General-Purpose")) {
+		t.Error("General-Purpose should satisfy both purposes")
+	}
+	if evalNode(ast, attributeSet("commerce:General-Purpose")) {
+		t.Error("General-Purpose of another tree should not satisfy the policy")
+	}
+	if evalNode(ast, attributeSet("health:Radiology")) {
+		t.Error("Radiology alone should not satisfy the policy")
+	}
+	if !evalNode(ast, attributeSet("health:Health-Record", "health:Research")) {
+		t.Error("parent purposes should satisfy the policy")
+	}
+}
+
+func TestExcept(t *testing.T) {
+	downward := indexed(policyConfig.Config{PurposeTrees: utils.ExamplePurposeTrees(), Inheritance: policyConfig.Downward})
+	for _, tc := range []struct {
+		config  policyConfig.Config
+		policy  string
+		allowed []string
+		denied  []string
+	}{
+		{downward, "Marketing EXCEPT Third-Party", []string{"commerce:Direct"}, []string{"commerce:Marketing", "commerce:Third-Party", "commerce:Email", "commerce:General-Purpose"}},
+		{downward, "(Admin OR Marketing) EXCEPT (Profiling, Third-Party)", []string{"commerce:Analysis", "commerce:Direct"}, []string{"commerce:Admin", "commerce:Profiling", "commerce:Phone"}},
+		{downward, "age >= 18 AND Health-Record EXCEPT Radiology", nil, []string{"health:Radiology", "health:Optometry"}},
+		{testConfig, "Email EXCEPT Marketing", []string{"commerce:General-Purpose"}, []string{"commerce:Marketing", "commerce:Third-Party", "commerce:Email"}},
+	} {
+		ast, err := NewParser(tc.policy, tc.config).Parse()
+		if err != nil {
+			t.Fatalf("%q: %v", tc.policy, err)
+		}
+		ast = expand(ast)
+		for _, a := range tc.allowed {
+			if !evalNode(ast, attributeSet(a)) {
+				t.Errorf("%q should allow %s", tc.policy, a)
+			}
+		}
+		for _, a := range tc.denied {
+			if evalNode(ast, attributeSet(a)) {
+				t.Errorf("%q should deny %s", tc.policy, a)
+			}
+		}
+	}
+
+	for _, policy := range []string{"Marketing EXCEPT Marketing", "Marketing EXCEPT", "Marketing EXCEPT (Email,", "age >= 1 EXCEPT"} {
+		if _, err := NewParser(policy, downward).Parse(); err == nil {
+			t.Errorf("%q should be rejected", policy)
+		}
+	}
+}
+
+func TestQualifiedPurposes(t *testing.T) {
+	for _, tc := range []struct {
+		policy  string
+		allowed []string
+		denied  []string
+	}{
+		{"health:General-Purpose", []string{"health:General-Purpose"}, []string{"commerce:General-Purpose"}},
+		{"commerce:General-Purpose/Admin/Profiling", []string{"commerce:Admin", "commerce:General-Purpose"}, []string{"health:General-Purpose"}},
+		{"General-Purpose", []string{"health:General-Purpose", "commerce:General-Purpose"}, nil},
+	} {
+		ast := parseExpanded(t, tc.policy)
+		for _, a := range tc.allowed {
+			if !evalNode(ast, attributeSet(a)) {
+				t.Errorf("%q should allow %s", tc.policy, a)
+			}
+		}
+		for _, a := range tc.denied {
+			if evalNode(ast, attributeSet(a)) {
+				t.Errorf("%q should deny %s", tc.policy, a)
+			}
+		}
+	}
+
+	for _, policy := range []string{"finance:Admin", "health:Admin", "commerce:Admin/Profiling"} {
+		if _, err := NewParser(policy, testConfig).Parse(); err == nil {
+			t.Errorf("%q should be rejected", policy)
+		}
+	}
+}
+
+func TestAmbiguousPurposeWarning(t *testing.T) {
+	parser := NewParser("General-Purpose OR health:General-Purpose OR Research", testConfig)
+	if _, err := parser.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	warnings := parser.Warnings()
+	if len(warnings) != 1 || warnings[0].Pos != 0 {
+		t.Fatalf("expected a single warning for the bare General-Purpose, got %v", warnings)
+	}
+	if !strings.Contains(warnings[0].Message, "commerce:General-Purpose") {
+		t.Errorf("warning should suggest a qualified purpose: %s", warnings[0].Message)
+	}
+}
+
+// a range uses the attributes of each bit at most once, so FAME can encrypt it
+func TestRangeMatchesScheme(t *testing.T) {
+	fame := abe.NewFAME()
+	pubKey, secKey, err := fame.GenerateMasterKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	policy, err := toAttr("Admin OR 2024-01-01 <= expiry < 2025-01-01", testConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msp, err := abe.BooleanToMSP(policy, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cipher, err := fame.Encrypt("plaintext", msp, pubKey)
+	if err != nil {
+		t.Fatalf("%s: %v", policy, err)
+	}
+
+	for date, want := range map[string]bool{"2023-12-31": false, "2024-01-01": true, "2024-12-31": true, "2025-01-01": false} {
+		value := utils.Assure(utils.ParseNumericValue(date))
+		key, err := fame.GenerateAttribKeys(utils.BitAttributes("expiry", uint(value), utils.ComparisonValueSize), secKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fame.Decrypt(cipher, key, pubKey); (err == nil) != want {
+			t.Errorf("expiry %s: decryption error %v", date, err)
+		}
+	}
+}
+
+// thresholds are encrypted as they are, an OR over every combination would put each attribute on several rows
+func TestThresholdMatchesScheme(t *testing.T) {
+	scheme := crypto.Setup()
+	for _, tc := range []struct {
+		policy string
+		keys   map[string]bool
+	}{
+		{"2 of (A, B, C)", map[string]bool{"A": false, "B": false, "A,B": true, "A,C": true, "B,C": true, "A,B,C": true}},
+		{"2 of (Radiology, Optometry, Need-To-Know)", map[string]bool{
+			"health:Radiology":                      false,
+			"health:Radiology,health:Optometry":     true,
+			"health:Optometry,health:Need-To-Know":  true,
+			"health:Health-Record":                  true,
+			"health:Need-To-Know,commerce:Shipping": false,
+		}},
+		//the ancestors of Radiology and Optometry satisfy two of the purposes and are factored out of the threshold
+		{"2 of (Radiology, Optometry, Profiling)", map[string]bool{
+			"health:Radiology":                    false,
+			"commerce:Profiling":                  false,
+			"health:Health-Record":                true,
+			"health:Radiology,commerce:Profiling": true,
+			"health:Optometry,commerce:Admin":     true,
+		}},
+	} {
+		policy, err := toAttr(tc.policy, testConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(policy, "2 of (") {
+			t.Errorf("%q: threshold was expanded: %s", tc.policy, policy)
+		}
+		cipher, err := scheme.Encrypt([]byte("plaintext"), policy)
+		if err != nil {
+			t.Fatalf("%s: %v", policy, err)
+		}
+
+		var fameCipher abe.FAMECipher
+		utils.FromBytes(cipher, &fameCipher)
+		for attributes, want := range tc.keys {
+			key := utils.Assure(scheme.Scheme.GenerateAttribKeys(strings.Split(attributes, ","), scheme.SecretKey))
+			if _, err := scheme.Scheme.Decrypt(&fameCipher, key, scheme.PublicKey); (err == nil) != want {
+				t.Errorf("%s with %s: decryption error %v", policy, attributes, err)
+			}
+			if ok, _ := CanDecrypt(policy, strings.Split(attributes, ",")); ok != want {
+				t.Errorf("%s with %s: CanDecrypt %v", policy, attributes, ok)
+			}
+		}
+	}
+}
+
+// the ancestors of Radiology and Optometry satisfy only two of the purposes and would be on several rows
+func TestRepeatedAttributes(t *testing.T) {
+	_, err := toAttr("3 of (Radiology, Optometry, Profiling, Shipping)", testConfig)
+	var policyErr *PolicyError
+	if !errors.As(err, &policyErr) || !strings.Contains(err.Error(), "more than once") {
+		t.Fatalf("expected a policy error, got %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+/*
+
+time bound read policies
+The authority issues keys with the bit attributes of the current time epoch (see utils.TimeAttributes),
+so a policy restricted to a range of epochs can no longer be satisfied by new keys once the range has passed
+
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pzkt/abe-scripts/abe-scheme/internal/utils"
+)
+
+// when data may be read, a zero time does not restrict it
+// both times are rounded to the epochs of the authority: the data is readable with keys from the epoch that contains After
+// up to and including the epoch that contains Until
+type TimeBounds struct {
+	After time.Time
+	Until time.Time
+}
+
+func (b TimeBounds) IsZero() bool {
+	return b.After.IsZero() && b.Until.IsZero()
+}
+
+// comparisons over the epoch of a key, nil if the bounds do not restrict anything
+func (b TimeBounds) node(epochLength time.Duration) (*Node, error) {
+	if b.IsZero() {
+		return nil, nil
+	}
+	if epochLength <= 0 {
+		return nil, fmt.Errorf("the policy config does not define time epochs, the authority does not issue time bound keys")
+	}
+	if !b.After.IsZero() && !b.Until.IsZero() && b.Until.Before(b.After) {
+		return nil, fmt.Errorf("readable until %s is before readable after %s", b.Until, b.After)
+	}
+
+	var out *Node
+	for _, bound := range []struct {
+		t  time.Time
+		op utils.Ops
+	}{
+		{b.After, utils.GreaterOrEqual},
+		{b.Until, utils.LessOrEqual},
+	} {
+		if bound.t.IsZero() {
+			continue
+		}
+		policy, err := utils.Comparison(utils.TimeAttribute, utils.Epoch(bound.t, epochLength), utils.TimeValueSize, bound.op)
+		if err != nil {
+			return nil, err
+		}
+		out = join(NodeAND, out, comparisonToNode(strings.Fields(policy)))
+	}
+	return out, nil
+}
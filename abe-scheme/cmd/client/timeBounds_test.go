@@ -0,0 +1,114 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fentec-project/gofe/abe"
+	"github.com/pzkt/abe-scripts/abe-scheme/internal/utils"
+	"github.com/pzkt/abe-scripts/abe-scheme/internal/utils/policyConfig"
+)
+
+var timedConfig = indexed(policyConfig.Config{PurposeTrees: utils.ExamplePurposeTrees(), TimeEpoch: time.Hour})
+
+func timeKeyAttributes(t *testing.T, at time.Time, attributes ...string) []string {
+	t.Helper()
+	timeAttributes, err := utils.TimeAttributes(at, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return append(attributes, timeAttributes...)
+}
+
+func TestTimeBounds(t *testing.T) {
+	after := time.Date(2025, 3, 1, 12, 30, 0, 0, time.UTC)
+	until := time.Date(2025, 3, 2, 8, 15, 0, 0, time.UTC)
+
+	for _, tc := range []struct {
+		bounds TimeBounds
+		at     time.Time
+		want   bool
+	}{
+		{TimeBounds{Until: until}, until, true},
+		{TimeBounds{Until: until}, time.Date(2025, 3, 2, 8, 59, 59, 0, time.UTC), true},
+		{TimeBounds{Until: until}, time.Date(2025, 3, 2, 9, 0, 0, 0, time.UTC), false},
+		{TimeBounds{Until: until}, time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC), true},
+		{TimeBounds{After: after}, time.Date(2025, 3, 1, 11, 59, 59, 0, time.UTC), false},
+		{TimeBounds{After: after}, time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC), true},
+		{TimeBounds{After: after}, time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC), true},
+		{TimeBounds{After: after, Until: until}, after.Add(-time.Hour), false},
+		{TimeBounds{After: after, Until: until}, after.Add(10 * time.Hour), true},
+		{TimeBounds{After: after, Until: until}, until.Add(time.Hour), false},
+		{TimeBounds{After: after, Until: after}, after, true},
+	} {
+		policy, err := toBoundedAttr("Admin", timedConfig, tc.bounds)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := CanDecrypt(policy, timeKeyAttributes(t, tc.at, "commerce:Admin"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Errorf("%+v with a key from %s: got %v", tc.bounds, tc.at, got)
+		}
+		if got, _ := CanDecrypt(policy, []string{"commerce:Admin"}); got {
+			t.Errorf("%+v: a key without time attributes should not satisfy the policy", tc.bounds)
+		}
+	}
+}
+
+func TestUnboundedPolicyIgnoresTime(t *testing.T) {
+	bounded, err := toBoundedAttr("Admin", timedConfig, TimeBounds{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unbounded := utils.Assure(toAttr("Admin", timedConfig)); bounded != unbounded {
+		t.Errorf("got %s, want %s", bounded, unbounded)
+	}
+}
+
+func TestInvalidTimeBounds(t *testing.T) {
+	now := time.Now()
+	if _, err := toBoundedAttr("Admin", testConfig, TimeBounds{Until: now}); err == nil {
+		t.Error("time bounds need a policy config with time epochs")
+	}
+	if _, err := toBoundedAttr("Admin", timedConfig, TimeBounds{After: now, Until: now.Add(-2 * time.Hour)}); err == nil {
+		t.Error("until before after should be rejected")
+	}
+	if _, err := toAttr("Admin AND time < 5", timedConfig); err == nil {
+		t.Error("the time attribute should be reserved")
+	}
+}
+
+func TestTimeBoundsMatchScheme(t *testing.T) {
+	fame := abe.NewFAME()
+	pubKey, secKey, err := fame.GenerateMasterKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	policy, err := toBoundedAttr("Admin", timedConfig, TimeBounds{After: now, Until: now.Add(48 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msp, err := abe.BooleanToMSP(policy, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cipher, err := fame.Encrypt("plaintext", msp, pubKey)
+	if err != nil {
+		t.Fatalf("%s: %v", policy, err)
+	}
+
+	for offset, want := range map[time.Duration]bool{-time.Hour: false, 0: true, 47 * time.Hour: true, 49 * time.Hour: false} {
+		key, err := fame.GenerateAttribKeys(timeKeyAttributes(t, now.Add(offset), "commerce:Admin"), secKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fame.Decrypt(cipher, key, pubKey); (err == nil) != want {
+			t.Errorf("key from %s: decryption error %v", now.Add(offset), err)
+		}
+	}
+}
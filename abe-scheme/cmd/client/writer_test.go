@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pzkt/abe-scripts/abe-scheme/internal/crypto"
+	"github.com/pzkt/abe-scripts/abe-scheme/internal/utils"
+)
+
+type writerTestEntry struct {
+	Text string
+}
+
+// the database and the authority have to be running, see README
+func requireServers(t *testing.T) {
+	t.Helper()
+	for _, url := range []string{cfg.DatabaseURL + "/entries/relations/" + cfg.AuthorityUUID, cfg.AuthorityURL + "/purposes"} {
+		resp, err := http.Get(url)
+		if err != nil {
+			t.Skipf("server not reachable: %v", err)
+		}
+		resp.Body.Close()
+	}
+}
+
+// a client with its own keyring, so keys are never shared between clients
+func newTestClient(t *testing.T, attributes ...string) *env {
+	t.Helper()
+	client := setup()
+	client.keyring = utils.Assure(OpenKeyring("", ""))
+	client.keyring.AddABEKey(requestNewKey(attributes), time.Time{})
+	return client
+}
+
+func readEntry(t *testing.T, client *env, id uuid.UUID) string {
+	t.Helper()
+	data, err := client.decrypt(client.getEntry("table_one", id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry writerTestEntry
+	utils.FromBytes(data, &entry)
+	return entry.Text
+}
+
+func TestModifyAsAuthorizedWriter(t *testing.T) {
+	requireServers(t)
+	testAuthorizedWriters(t, newTestClient(t, "Admin"), newTestClient(t, "Admin"), newTestClient(t, "Shipping"))
+}
+
+// the same against the in memory database, which checks the signatures of updates like the database does
+func TestModifyAsAuthorizedWriterFakeDatabase(t *testing.T) {
+	fakeDatabase(t)
+	scheme := crypto.Setup()
+	testAuthorizedWriters(t, keywordTestClient(scheme, "commerce:Admin"), keywordTestClient(scheme, "commerce:Admin"), keywordTestClient(scheme, "commerce:Shipping"))
+}
+
+// owner and writer hold keys for Admin, the outsider does not
+func testAuthorizedWriters(t *testing.T, owner *env, writer *env, outsider *env) {
+	id, err := owner.addEntry("table_one", writerTestEntry{"original"}, "Admin", "Admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//a new write key of its own does not let a client modify the entry
+	if err := outsider.modifyEntry("table_one", writerTestEntry{"forged"}, "Admin", "Admin", id); err == nil {
+		t.Error("an update signed with another key should be rejected")
+	}
+	if err := outsider.modifyAsAuthorizedWriter("table_one", writerTestEntry{"forged"}, "Admin", "Admin", TimeBounds{}, id, false); err == nil {
+		t.Error("a client without a key for the write policy should not get the write key")
+	}
+
+	if err := writer.modifyAsAuthorizedWriter("table_one", writerTestEntry{"by writer"}, "Admin", "Admin", TimeBounds{}, id, false); err != nil {
+		t.Fatal(err)
+	}
+	if text := readEntry(t, owner, id); text != "by writer" {
+		t.Errorf("owner read %q", text)
+	}
+
+	//without rotation the owner keeps write access
+	if err := owner.modifyEntry("table_one", writerTestEntry{"by owner"}, "Admin", "Admin", id); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writer.modifyAsAuthorizedWriter("table_one", writerTestEntry{"rotated"}, "Admin", "Admin", TimeBounds{}, id, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := owner.modifyEntry("table_one", writerTestEntry{"stale"}, "Admin", "Admin", id); err == nil {
+		t.Error("the previous write key should be rejected after rotation")
+	}
+	if text := readEntry(t, writer, id); text != "rotated" {
+		t.Errorf("writer read %q", text)
+	}
+
+	//the rotated key is encrypted under the write policy again, so authorized writers still get it
+	if err := owner.modifyAsAuthorizedWriter("table_one", writerTestEntry{"after rotation"}, "Admin", "Admin", TimeBounds{}, id, false); err != nil {
+		t.Fatal(err)
+	}
+}
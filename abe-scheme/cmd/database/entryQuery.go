@@ -0,0 +1,116 @@
+/*
+
+filtering and sorting entries by their public fields
+GET /entries/{table}?metadata.record_type=Cardiology&search.patient_id=<token>&sort=metadata.date&order=desc&limit=10
+GET /aggregate/{table}/{field}?metadata.record_type=Cardiology
+
+*/
+
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// the query for the entries of a table that match all filters, field names and values are passed as arguments
+func entryQuery(table string, params url.Values) (string, []any, error) {
+	args := []any{}
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	conditions, err := filterConditions(params, []string{"sort", "order", "limit"}, arg)
+	if err != nil {
+		return "", nil, err
+	}
+
+	query := fmt.Sprintf(`SELECT id, policy, created, metadata, search FROM %s`, table)
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	order := "ASC"
+	switch params.Get("order") {
+	case "", "asc":
+	case "desc":
+		order = "DESC"
+	default:
+		return "", nil, fmt.Errorf("order must be asc or desc, got %q", params.Get("order"))
+	}
+
+	//only public fields can be sorted by, search fields have no meaningful order
+	switch sortBy := params.Get("sort"); {
+	case sortBy == "" || sortBy == "created":
+		query += " ORDER BY created " + order
+	case strings.HasPrefix(sortBy, "metadata."):
+		query += fmt.Sprintf(" ORDER BY metadata->>%s %s, created", arg(strings.TrimPrefix(sortBy, "metadata.")), order)
+	default:
+		return "", nil, fmt.Errorf("entries can be sorted by created or metadata.<field>, not %q", sortBy)
+	}
+
+	if limit := params.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			return "", nil, fmt.Errorf("limit must be a positive number, got %q", limit)
+		}
+		query += " LIMIT " + arg(n)
+	}
+	return query, args, nil
+}
+
+// the query for the encrypted values of a field of the entries that match all filters, see crypto.SumAggregateValues
+func aggregateQuery(table string, field string, params url.Values) (string, []any, error) {
+	args := []any{}
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	conditions, err := filterConditions(params, nil, arg)
+	if err != nil {
+		return "", nil, err
+	}
+	fieldArg := arg(field)
+	conditions = append([]string{fmt.Sprintf("aggregates ? %s", fieldArg)}, conditions...)
+
+	query := fmt.Sprintf(`SELECT aggregates->>%s FROM %s WHERE %s`, fieldArg, table, strings.Join(conditions, " AND "))
+	return query, args, nil
+}
+
+// the conditions for the metadata.<field> and search.<field> parameters, options are the other parameters that are allowed
+func filterConditions(params url.Values, options []string, arg func(any) string) ([]string, error) {
+	conditions := []string{}
+
+	//sorted, so equal requests give equal queries
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		column, field, found := strings.Cut(key, ".")
+		if !found {
+			if !slices.Contains(options, key) {
+				return nil, fmt.Errorf("unknown parameter %q", key)
+			}
+			continue
+		}
+		if column != "metadata" && column != "search" {
+			return nil, fmt.Errorf("unknown parameter %q, fields are filtered with metadata.<field> or search.<field>", key)
+		}
+		//several values of a field match any of them
+		alternatives := []string{}
+		for _, value := range params[key] {
+			alternatives = append(alternatives, fmt.Sprintf("%s->>%s = %s", column, arg(field), arg(value)))
+		}
+		conditions = append(conditions, "("+strings.Join(alternatives, " OR ")+")")
+	}
+	return conditions, nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/url"
+	"slices"
+	"testing"
+)
+
+func TestEntryQuery(t *testing.T) {
+	for _, tc := range []struct {
+		params string
+		query  string
+		args   []any
+	}{
+		{"", `SELECT id, policy, created, metadata, search FROM table_one ORDER BY created ASC`, []any{}},
+		{"metadata.record_type=Cardiology&search.patient_id=abc&search.patient_id=def&sort=metadata.date&order=desc&limit=5",
+			`SELECT id, policy, created, metadata, search FROM table_one WHERE (metadata->>$1 = $2) AND (search->>$3 = $4 OR search->>$5 = $6) ORDER BY metadata->>$7 DESC, created LIMIT $8`,
+			[]any{"record_type", "Cardiology", "patient_id", "abc", "patient_id", "def", "date", 5}},
+		//field names are arguments, so they can not change the query
+		{"metadata.x'%20OR%201%3D1--=y", `SELECT id, policy, created, metadata, search FROM table_one WHERE (metadata->>$1 = $2) ORDER BY created ASC`,
+			[]any{"x' OR 1=1--", "y"}},
+	} {
+		params, _ := url.ParseQuery(tc.params)
+		query, args, err := entryQuery("table_one", params)
+		if err != nil {
+			t.Errorf("%s: %v", tc.params, err)
+			continue
+		}
+		if query != tc.query || !slices.Equal(args, tc.args) {
+			t.Errorf("%s:\ngot  %s %v\nwant %s %v", tc.params, query, args, tc.query, tc.args)
+		}
+	}
+
+	for _, invalid := range []string{"patient_id=1", "data.x=1", "sort=search.patient_id", "order=up", "limit=0", "limit=x"} {
+		params, _ := url.ParseQuery(invalid)
+		if _, _, err := entryQuery("table_one", params); err == nil {
+			t.Errorf("%s should be rejected", invalid)
+		}
+	}
+}
+
+func TestAggregateQuery(t *testing.T) {
+	params, _ := url.ParseQuery("metadata.record_type=Cardiology")
+	query, args, err := aggregateQuery("table_one", "heart_rate", params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `SELECT aggregates->>$3 FROM table_one WHERE aggregates ? $3 AND (metadata->>$1 = $2)`
+	if query != want || !slices.Equal(args, []any{"record_type", "Cardiology", "heart_rate"}) {
+		t.Errorf("got  %s %v\nwant %s", query, args, want)
+	}
+
+	for _, invalid := range []string{"sort=created", "limit=5", "data.x=1"} {
+		params, _ := url.ParseQuery(invalid)
+		if _, _, err := aggregateQuery("table_one", "heart_rate", params); err == nil {
+			t.Errorf("%s should be rejected", invalid)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// table names end up in the queries, so every handler has to reject unknown tables before the database is used
+func TestUnknownTables(t *testing.T) {
+	server := httptest.NewServer(router())
+	defer server.Close()
+
+	table := url.PathEscape("table_one WHERE 1=1; DROP TABLE relations; --")
+	for _, request := range []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{"POST", "/entries", `{"Table": "table_one; DROP TABLE relations"}`},
+		{"GET", "/entries/" + table, ""},
+		{"GET", "/entries/" + table + "/497dcba3-ecbf-4587-a2dd-5eb0665e6880", ""},
+		{"GET", "/write_key/" + table + "/497dcba3-ecbf-4587-a2dd-5eb0665e6880", ""},
+		{"POST", "/search/" + table, `{"trapdoors": []}`},
+		{"GET", "/aggregate/" + table + "/heart_rate", ""},
+	} {
+		req, err := http.NewRequest(request.method, server.URL+request.path, strings.NewReader(request.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s %s: status %d, want %d", request.method, request.path, resp.StatusCode, http.StatusBadRequest)
+		}
+	}
+}
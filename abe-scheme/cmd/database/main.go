@@ -8,17 +8,19 @@ They must validate incoming modification requests
 package main
 
 import (
-	"bytes"
-	"crypto/ecdsa"
-	"crypto/elliptic"
 	"database/sql"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"slices"
 
 	"github.com/gorilla/mux"
 	_ "github.com/lib/pq"
+	"github.com/pzkt/abe-scripts/abe-scheme/internal/config"
 	"github.com/pzkt/abe-scripts/abe-scheme/internal/crypto"
 	"github.com/pzkt/abe-scripts/abe-scheme/internal/utils"
 )
@@ -41,116 +43,190 @@ Password: pwd
 */
 
 var db *sql.DB
+var cfg config.Config
 
 func main() {
-	dbPassword := "pwd"
-	connection := fmt.Sprintf("postgres://postgres:%s@localhost:5432/data?sslmode=disable", dbPassword)
+	cfg = utils.Assure(config.Load(os.Args[1:]))
 
-	db = utils.Assure(sql.Open("postgres", connection))
-	utils.Try(db.Ping())
+	db = utils.Connect(cfg.Postgres.DSN())
 
 	defer db.Close()
 
 	setup(db)
 
+	log.Printf("database server started on port %s\n", cfg.DatabaseAddr())
+	log.Fatal(http.ListenAndServe(cfg.DatabaseAddr(), router()))
+}
+
+func router() *mux.Router {
 	r := mux.NewRouter()
 	r.HandleFunc("/entries", addEntry).Methods("POST")
+	r.HandleFunc("/entries/{table}", listEntries).Methods("GET")
 	r.HandleFunc("/entries/{table}/{id}", getEntry).Methods("GET")
 	r.HandleFunc("/write_key/{table}/{id}", getWriteKey).Methods("GET")
+	r.HandleFunc("/search/{table}", searchEntries).Methods("POST")
+	r.HandleFunc("/aggregate/{table}/{field}", aggregateField).Methods("GET")
+	return r
+}
 
-	log.Println("database server started on port :8080")
-	log.Fatal(http.ListenAndServe(":8080", r))
+// the key-value table for table row relations, the ABE encrypted index and analytics keys of the tables and the tables holding the entries
+var tables = []string{"relations", "search_indexes", "analytics_keys", "table_one", "table_two"}
+
+// table names are part of the queries, so only the tables above are accepted
+func knownTable(w http.ResponseWriter, table string) bool {
+	if !slices.Contains(tables, table) {
+		http.Error(w, fmt.Sprintf("unknown table %q", table), http.StatusBadRequest)
+		return false
+	}
+	return true
 }
 
 func setup(db *sql.DB) {
-	//create the key-value table for table row relations
-	query := `CREATE TABLE IF NOT EXISTS relations (
-		id UUID,
-		private_write_key BYTEA,
-		public_write_key BYTEA,
-		data BYTEA,
-		created TIMESTAMP DEFAULT NOW()
-	)`
-
-	utils.Assure(db.Exec(query))
-
-	query = `CREATE TABLE IF NOT EXISTS table_one (
-		id UUID,
-		private_write_key BYTEA,
-		public_write_key BYTEA,
-		data BYTEA,
-		created TIMESTAMP DEFAULT NOW()
-	)`
-
-	utils.Assure(db.Exec(query))
-
-	query = `CREATE TABLE IF NOT EXISTS table_two (
-		id UUID,
-		private_write_key BYTEA,
-		public_write_key BYTEA,
-		data BYTEA,
-		created TIMESTAMP DEFAULT NOW()
-	)`
-
-	utils.Assure(db.Exec(query))
+	for _, table := range tables {
+		for _, statement := range schema(table) {
+			utils.Assure(db.Exec(statement))
+		}
+	}
+}
+
+// columns added after the first version of the tables
+var addedColumns = []struct {
+	name string
+	kind string
+}{
+	{"policy", "BYTEA"},
+	{"metadata", "JSONB"},
+	{"search", "JSONB"},
+	{"keywords", "BYTEA"},
+	{"aggregates", "JSONB"},
+}
+
+// the statements that create a table or bring a table of an older version up to date, all of them can be run again
+func schema(table string) []string {
+	statements := []string{fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			id UUID PRIMARY KEY,
+			private_write_key BYTEA,
+			public_write_key BYTEA,
+			data BYTEA,
+			policy BYTEA,
+			created TIMESTAMP DEFAULT NOW()
+		)`, table)}
+
+	//tables created before policy descriptors and metadata were stored
+	for _, column := range addedColumns {
+		statements = append(statements, fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s %s`, table, column.name, column.kind))
+	}
+
+	//tables created before entries were updated in place have no primary key, which ON CONFLICT (id) in addEntry needs
+	statements = append(statements, fmt.Sprintf(`DO $$
+		BEGIN
+			IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conrelid = '%s'::regclass AND contype = 'p') THEN
+				ALTER TABLE %s ADD PRIMARY KEY (id);
+			END IF;
+		END $$`, table, table))
+
+	return append(statements,
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s_metadata ON %s USING GIN (metadata)`, table, table),
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s_search ON %s USING GIN (search)`, table, table),
+	)
 }
 
 // add entry or validate if the given UUID already exists
+// the check and the write happen in one transaction, the row of an existing entry stays locked until the commit
+// so its write key can not change in between
 func addEntry(w http.ResponseWriter, r *http.Request) {
 	var record utils.Record
 	if err := json.NewDecoder(r.Body).Decode(&record); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if !knownTable(w, record.Table) {
+		return
+	}
 
-	var exists bool
-	existQuery := fmt.Sprintf(`SELECT EXISTS(SELECT 1 FROM %s WHERE id = $1)`, record.Table)
-	utils.Try(db.QueryRow(existQuery, record.ID).Scan(&exists))
-
-	if exists {
-		var oldRecord utils.Record
-		getQuery := fmt.Sprintf(`SELECT * FROM %s WHERE id = $1`, record.Table)
-		utils.Try(db.QueryRow(getQuery, record.ID).Scan(&oldRecord.ID, &oldRecord.PrivateWriteKey, &oldRecord.PublicWriteKey, &oldRecord.Data, &oldRecord.Created))
-
-		var checkSum bytes.Buffer
-		for _, s := range [][]byte{utils.ToBytes(record.Table), record.ID[:], record.PrivateWriteKey, record.PublicWriteKey, record.Data, utils.ToBytes(record.Created)} {
-			checkSum.Write(s)
-		}
+	metadata, metadataErr := json.Marshal(record.Metadata)
+	search, searchErr := json.Marshal(record.Search)
+	aggregates, aggregatesErr := json.Marshal(record.Aggregates)
+	if err := errors.Join(metadataErr, searchErr, aggregatesErr); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-		var publicKey ecdsa.PublicKey
-		utils.FromBytes(record.PublicWriteKey, &publicKey)
-		publicKey.Curve = elliptic.P256()
+	tx, err := db.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
 
-		valid := crypto.Verify(&publicKey, checkSum.Bytes(), record.Signature)
+	var oldPublicWriteKey []byte
+	getQuery := fmt.Sprintf(`SELECT public_write_key FROM %s WHERE id = $1 FOR UPDATE`, record.Table)
+	err = tx.QueryRow(getQuery, record.ID).Scan(&oldPublicWriteKey)
+	exists := err == nil
+	if err != nil && err != sql.ErrNoRows {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-		if !valid {
+	if exists {
+		//only the holder of the current write key may modify the entry, the update may carry a new public key to rotate it
+		if !crypto.VerifyUpdate(oldPublicWriteKey, record) {
 			fmt.Printf("Signature mismatch: modify request rejected!\n")
+			http.Error(w, "signature does not match the write key of the entry", http.StatusForbidden)
 			return
 		}
 		fmt.Printf("Signature verified: modifying entry in table: %s with uuid: %s\n", record.Table, record.ID)
-
 	} else {
 		fmt.Printf("creating new entry in table: %s with uuid: %s\n", record.Table, record.ID)
 	}
 
+	//a new entry is only inserted if no other request created it in the meantime, nothing was there to lock
 	query := fmt.Sprintf(
-		`INSERT INTO %s (id, private_write_key, public_write_key, data, created) 
-         VALUES ($1, $2, $3, $4, $5) 
-		 ON CONFLICT (id) DO UPDATE SET
-		 private_write_key = EXCLUDED.private_write_key,
-		 public_write_key = EXCLUDED.public_write_key,
-		 data = EXCLUDED.data,
-		 created = EXCLUDED.created`,
+		`INSERT INTO %s (id, private_write_key, public_write_key, data, policy, created, metadata, search, keywords, aggregates) 
+         VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) 
+		 ON CONFLICT (id) DO NOTHING`,
 		record.Table,
 	)
+	if exists {
+		query = fmt.Sprintf(
+			`UPDATE %s SET
+			 private_write_key = $2,
+			 public_write_key = $3,
+			 data = $4,
+			 policy = $5,
+			 created = $6,
+			 metadata = $7,
+			 search = $8,
+			 keywords = $9,
+			 aggregates = $10
+			 WHERE id = $1`,
+			record.Table,
+		)
+	}
 
-	utils.Assure(db.Exec(query,
+	result, err := tx.Exec(query,
 		record.ID,
 		record.PrivateWriteKey,
 		record.PublicWriteKey,
 		record.Data,
+		utils.ToBytes(record.Policy),
 		record.Created,
-	))
+		metadata,
+		search,
+		encodeKeywords(record.Keywords),
+		aggregates,
+	)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if written, err := result.RowsAffected(); err == nil && written == 0 {
+		http.Error(w, "the entry was created by another request, retry to modify it", http.StatusConflict)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
 }
 
 // return the data field of an entry
@@ -159,9 +235,14 @@ func getEntry(w http.ResponseWriter, r *http.Request) {
 	table := vars["table"]
 	id := vars["id"]
 
+	if !knownTable(w, table) {
+		return
+	}
+
 	var record utils.Record
-	query := fmt.Sprintf(`SELECT data, created FROM %s WHERE id = $1`, table)
-	err := db.QueryRow(query, id).Scan(&record.Data, &record.Created)
+	var policy, metadata, search []byte
+	query := fmt.Sprintf(`SELECT data, policy, created, metadata, search FROM %s WHERE id = $1`, table)
+	err := db.QueryRow(query, id).Scan(&record.Data, &policy, &record.Created, &metadata, &search)
 
 	if err == sql.ErrNoRows {
 		http.Error(w, "record not found", http.StatusNotFound)
@@ -171,20 +252,89 @@ func getEntry(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	decodePolicy(policy, &record)
+	decodeFields(metadata, search, &record)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(record)
 }
 
-// return the private write key field of an entry
+// entries written before metadata was stored have none
+func decodeFields(metadata []byte, search []byte, record *utils.Record) {
+	if len(metadata) > 0 {
+		utils.Try(json.Unmarshal(metadata, &record.Metadata))
+	}
+	if len(search) > 0 {
+		utils.Try(json.Unmarshal(search, &record.Search))
+	}
+}
+
+// entries without keywords are stored as NULL
+func encodeKeywords(keywords *utils.KeywordTags) []byte {
+	if keywords == nil {
+		return nil
+	}
+	return utils.ToBytes(keywords)
+}
+
+// entries written before policy descriptors were stored have none
+func decodePolicy(policy []byte, record *utils.Record) {
+	if len(policy) > 0 {
+		utils.FromBytes(policy, &record.Policy)
+	}
+}
+
+// list the ids, policy descriptors, metadata and creation times of the entries in a table, without their data
+// the entries can be filtered and sorted by their fields, see entryQuery
+func listEntries(w http.ResponseWriter, r *http.Request) {
+	table := mux.Vars(r)["table"]
+	if !knownTable(w, table) {
+		return
+	}
+
+	query, args, err := entryQuery(table, r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	records := []utils.Record{}
+	for rows.Next() {
+		record := utils.Record{Table: table}
+		var policy, metadata, search []byte
+		if err := rows.Scan(&record.ID, &policy, &record.Created, &metadata, &search); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		decodePolicy(policy, &record)
+		decodeFields(metadata, search, &record)
+		records = append(records, record)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(records)
+}
+
+// return the private write key field of an entry, with the public key and the policy descriptor it was encrypted under
 func getWriteKey(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	table := vars["table"]
 	id := vars["id"]
 
+	if !knownTable(w, table) {
+		return
+	}
+
 	var record utils.Record
-	query := fmt.Sprintf(`SELECT private_write_key FROM %s WHERE id = $1`, table)
-	err := db.QueryRow(query, id).Scan(&record.PrivateWriteKey)
+	var policy []byte
+	query := fmt.Sprintf(`SELECT private_write_key, public_write_key, policy FROM %s WHERE id = $1`, table)
+	err := db.QueryRow(query, id).Scan(&record.PrivateWriteKey, &record.PublicWriteKey, &policy)
 
 	if err == sql.ErrNoRows {
 		http.Error(w, "record not found", http.StatusNotFound)
@@ -195,6 +345,113 @@ func getWriteKey(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	decodePolicy(policy, &record)
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(record)
 }
+
+type searchRequest struct {
+	Trapdoors [][]byte `json:"trapdoors"`
+}
+
+// the entries of a table that have the keywords of all trapdoors, without their data
+// the trapdoors are tested against the keyword tags of every entry, the keywords themselves are never sent
+func searchEntries(w http.ResponseWriter, r *http.Request) {
+	table := mux.Vars(r)["table"]
+	if !knownTable(w, table) {
+		return
+	}
+
+	var request searchRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(request.Trapdoors) == 0 {
+		http.Error(w, "at least one trapdoor is needed", http.StatusBadRequest)
+		return
+	}
+
+	query := fmt.Sprintf(`SELECT id, policy, created, metadata, search, keywords FROM %s WHERE keywords IS NOT NULL ORDER BY created`, table)
+	rows, err := db.Query(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	records := []utils.Record{}
+	for rows.Next() {
+		record := utils.Record{Table: table}
+		var policy, metadata, search, keywords []byte
+		if err := rows.Scan(&record.ID, &policy, &record.Created, &metadata, &search, &keywords); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		var tags utils.KeywordTags
+		utils.FromBytes(keywords, &tags)
+		if !crypto.MatchesTrapdoors(tags, request.Trapdoors) {
+			continue
+		}
+		decodePolicy(policy, &record)
+		decodeFields(metadata, search, &record)
+		records = append(records, record)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(records)
+}
+
+type aggregateResponse struct {
+	Aggregate []byte `json:"aggregate"`
+}
+
+// the homomorphic sum of an encrypted numeric field over the entries that match the filters, see aggregateQuery
+// the aggregate also counts the entries, only the holder of the analytics key of the table can decrypt it
+func aggregateField(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	table := vars["table"]
+	if !knownTable(w, table) {
+		return
+	}
+
+	query, args, err := aggregateQuery(table, vars["field"], r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	ciphertexts := [][]byte{}
+	for rows.Next() {
+		var encoded string
+		if err := rows.Scan(&encoded); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		ciphertext, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		ciphertexts = append(ciphertexts, ciphertext)
+	}
+	if len(ciphertexts) == 0 {
+		http.Error(w, fmt.Sprintf("no entries with an encrypted %s match", vars["field"]), http.StatusNotFound)
+		return
+	}
+
+	aggregate, err := crypto.SumAggregateValues(ciphertexts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(aggregateResponse{aggregate})
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// tables of the first version only had these columns and no primary key
+var baselineColumns = []string{"id", "private_write_key", "public_write_key", "data", "created"}
+
+// every column addEntry writes has to exist in tables created by older versions too
+func TestSchemaMigratesOldTables(t *testing.T) {
+	statements := schema("table_one")
+	migrated := map[string]bool{}
+	for _, c := range baselineColumns {
+		migrated[c] = true
+	}
+	addColumn := regexp.MustCompile(`^ALTER TABLE table_one ADD COLUMN IF NOT EXISTS (\w+) `)
+	primaryKey := false
+	for _, s := range statements {
+		if m := addColumn.FindStringSubmatch(s); m != nil {
+			migrated[m[1]] = true
+		}
+		primaryKey = primaryKey || strings.Contains(s, "ALTER TABLE table_one ADD PRIMARY KEY (id)")
+	}
+	if !primaryKey {
+		t.Error("tables without a primary key are not migrated")
+	}
+
+	for _, column := range []string{"id", "private_write_key", "public_write_key", "data", "policy", "created", "metadata", "search", "keywords", "aggregates"} {
+		if !migrated[column] {
+			t.Errorf("column %s is not added to old tables", column)
+		}
+	}
+	if !strings.HasPrefix(statements[0], "CREATE TABLE IF NOT EXISTS table_one") {
+		t.Errorf("the table is not created first: %s", statements[0])
+	}
+}
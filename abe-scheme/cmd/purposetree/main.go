@@ -0,0 +1,48 @@
+/*
+
+Command line tool for purpose tree files
+
+	go run ./cmd/purposetree lint <file>...   validate the files, the exit status is 1 if any is invalid
+	go run ./cmd/purposetree print <file>...  validate the files and print their trees
+
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pzkt/abe-scripts/abe-scheme/internal/utils"
+)
+
+func main() {
+	if len(os.Args) < 3 || (os.Args[1] != "lint" && os.Args[1] != "print") {
+		fmt.Fprintln(os.Stderr, "usage: purposetree lint|print <file>...")
+		os.Exit(2)
+	}
+
+	failed := false
+	for _, path := range os.Args[2:] {
+		trees, err := utils.LoadPurposeTrees(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed = true
+			continue
+		}
+
+		switch os.Args[1] {
+		case "lint":
+			fmt.Printf("%s: %d trees ok\n", path, len(trees))
+		case "print":
+			fmt.Printf("# %s\n", path)
+			for _, t := range trees {
+				fmt.Print(t.String())
+			}
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"image/color"
+	"math"
+	"strconv"
+)
+
+type Point struct {
+	X    float64
+	Mean float64
+	CI   float64
+}
+
+type Line struct {
+	Name   string
+	Points []Point
+}
+
+type Chart struct {
+	Title  string
+	XLabel string
+	YLabel string
+	Lines  []Line
+}
+
+const (
+	chartWidth   = 900
+	chartHeight  = 540
+	marginLeft   = 80
+	marginRight  = 230
+	marginTop    = 40
+	marginBottom = 50
+)
+
+const (
+	anchorStart = iota
+	anchorMiddle
+	anchorEnd
+)
+
+var palette = []color.RGBA{
+	{0x00, 0x3a, 0x7d, 0xff}, {0xd8, 0x30, 0x34, 0xff}, {0x4e, 0xcb, 0x8d, 0xff}, {0xc7, 0x01, 0xff, 0xff},
+	{0xff, 0x7f, 0x00, 0xff}, {0x00, 0x8d, 0xff, 0xff}, {0x98, 0x4e, 0xa3, 0xff}, {0x4d, 0x4d, 0x4d, 0xff},
+}
+
+var (
+	black = color.RGBA{0, 0, 0, 0xff}
+	grey  = color.RGBA{0xdd, 0xdd, 0xdd, 0xff}
+)
+
+// the drawing operations of a chart, implemented for SVG and PNG
+type canvas interface {
+	line(x1, y1, x2, y2 float64, c color.RGBA, width float64)
+	marker(x, y float64, c color.RGBA)
+	text(x, y float64, s string, anchor int, size int, c color.RGBA)
+}
+
+// a linear or logarithmic mapping of values to pixels
+type axis struct {
+	min, max float64
+	log      bool
+	from, to float64
+}
+
+func (a axis) position(v float64) float64 {
+	lo, hi := a.min, a.max
+	if a.log {
+		v, lo, hi = math.Log10(v), math.Log10(lo), math.Log10(hi)
+	}
+	if hi == lo {
+		return (a.from + a.to) / 2
+	}
+	return a.from + (v-lo)/(hi-lo)*(a.to-a.from)
+}
+
+// ticks at powers of ten for logarithmic axes and at multiples of 1, 2 or 5 times a power of ten otherwise
+func (a axis) ticks() []float64 {
+	ticks := []float64{}
+	if a.log {
+		for e := math.Floor(math.Log10(a.min)); e <= math.Ceil(math.Log10(a.max)); e++ {
+			if v := math.Pow(10, e); v >= a.min && v <= a.max {
+				ticks = append(ticks, v)
+			}
+		}
+		return ticks
+	}
+	span := a.max - a.min
+	if span == 0 {
+		return []float64{a.min}
+	}
+	step := math.Pow(10, math.Floor(math.Log10(span/5)))
+	for _, f := range []float64{1, 2, 5, 10} {
+		if span/(step*f) <= 6 {
+			step *= f
+			break
+		}
+	}
+	for v := math.Ceil(a.min/step) * step; v <= a.max+step/1e6; v += step {
+		ticks = append(ticks, v)
+	}
+	return ticks
+}
+
+func formatTick(v float64) string {
+	if v != 0 && (math.Abs(v) >= 1e5 || math.Abs(v) < 1e-3) {
+		return strconv.FormatFloat(v, 'e', 0, 64)
+	}
+	return strconv.FormatFloat(v, 'g', 4, 64)
+}
+
+// an axis over the values, logarithmic if they are positive and span more than two orders of magnitude
+func newAxis(values []float64, from float64, to float64, zero bool) axis {
+	a := axis{min: math.Inf(1), max: math.Inf(-1), from: from, to: to}
+	for _, v := range values {
+		a.min, a.max = math.Min(a.min, v), math.Max(a.max, v)
+	}
+	if a.min > 0 && a.max/a.min > 100 {
+		a.log = true
+		a.min = math.Pow(10, math.Floor(math.Log10(a.min)))
+		a.max = math.Pow(10, math.Ceil(math.Log10(a.max)))
+		return a
+	}
+	if zero && a.min > 0 {
+		a.min = 0
+	}
+	if a.min == a.max {
+		a.max = a.min + 1
+	}
+	return a
+}
+
+func (ch Chart) draw(c canvas) {
+	xs, ys := []float64{}, []float64{}
+	for _, l := range ch.Lines {
+		for _, p := range l.Points {
+			xs = append(xs, p.X)
+			ys = append(ys, p.Mean, p.Mean+p.CI)
+			if p.Mean-p.CI > 0 {
+				ys = append(ys, p.Mean-p.CI)
+			}
+		}
+	}
+	right, bottom := float64(chartWidth-marginRight), float64(chartHeight-marginBottom)
+	x := newAxis(xs, marginLeft, right, false)
+	y := newAxis(ys, bottom, marginTop, true)
+
+	for _, t := range x.ticks() {
+		px := x.position(t)
+		c.line(px, marginTop, px, bottom, grey, 1)
+		c.text(px, bottom+16, formatTick(t), anchorMiddle, 1, black)
+	}
+	for _, t := range y.ticks() {
+		py := y.position(t)
+		c.line(marginLeft, py, right, py, grey, 1)
+		c.text(marginLeft-6, py+4, formatTick(t), anchorEnd, 1, black)
+	}
+	c.line(marginLeft, bottom, right, bottom, black, 1)
+	c.line(marginLeft, marginTop, marginLeft, bottom, black, 1)
+	c.text((marginLeft+right)/2, 24, ch.Title, anchorMiddle, 2, black)
+	c.text((marginLeft+right)/2, chartHeight-12, ch.XLabel, anchorMiddle, 1, black)
+	c.text(12, marginTop-12, ch.YLabel, anchorStart, 1, black)
+
+	for i, l := range ch.Lines {
+		lineColor := palette[i%len(palette)]
+		for j, p := range l.Points {
+			px, py := x.position(p.X), y.position(p.Mean)
+			if j > 0 {
+				prev := l.Points[j-1]
+				c.line(x.position(prev.X), y.position(prev.Mean), px, py, lineColor, 2)
+			}
+			if p.CI > 0 {
+				low := p.Mean - p.CI
+				if y.log && low <= 0 {
+					low = y.min
+				}
+				c.line(px, y.position(low), px, y.position(p.Mean+p.CI), lineColor, 1)
+			}
+			c.marker(px, py, lineColor)
+		}
+
+		//legend
+		ly := float64(marginTop + 10 + 18*i)
+		c.line(right+16, ly, right+36, ly, lineColor, 2)
+		c.marker(right+26, ly, lineColor)
+		c.text(right+44, ly+4, l.Name, anchorStart, 1, black)
+	}
+}
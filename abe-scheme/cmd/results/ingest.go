@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// one column of a result file, or one operation of the long format, with all values by x
+type Series struct {
+	Name   string
+	Unit   string
+	Values map[float64][]float64
+}
+
+// a result file, or one scheme of the long format. Sources without a numeric index, like the encoding results with
+// one row per entry, keep all their values at x = 0
+type Source struct {
+	Name    string
+	XLabel  string
+	Indexed bool
+	Series  []*Series
+}
+
+func (s *Source) series(name string, unit string) *Series {
+	for _, series := range s.Series {
+		if series.Name == name && series.Unit == unit {
+			return series
+		}
+	}
+	series := &Series{Name: name, Unit: unit, Values: make(map[float64][]float64)}
+	s.Series = append(s.Series, series)
+	return series
+}
+
+// the unit of the values of a file and the factor to convert them, derived from the folders the results are written to
+func unitOf(path string) (string, float64) {
+	switch {
+	case strings.Contains(path, "system-time"):
+		return "s", 1e-9
+	case strings.Contains(path, "startup"):
+		return "ms", 1
+	case strings.Contains(path, "space"), strings.Contains(path, "storage"), strings.Contains(path, "encoding"):
+		return "bytes", 1
+	default:
+		return "s", 1
+	}
+}
+
+func isNumber(s string) bool {
+	_, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	return err == nil
+}
+
+// all result files below the roots, named by their path below the root without the extension
+// the output folder is skipped, so the summary of an earlier run is not read as results
+func readSources(roots []string, skip string) ([]*Source, error) {
+	sources := []*Source{}
+	for _, root := range roots {
+		err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() && filepath.Clean(path) == filepath.Clean(skip) {
+				return filepath.SkipDir
+			}
+			if d.IsDir() || filepath.Ext(path) != ".csv" {
+				return nil
+			}
+			name := strings.TrimSuffix(filepath.ToSlash(relativePath(root, path)), ".csv")
+			read, err := readSource(path, name)
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			sources = append(sources, read...)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return sources, nil
+}
+
+func relativePath(root string, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return path
+	}
+	return rel
+}
+
+func readSource(path string, name string) ([]*Source, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 || len(records[0]) < 2 {
+		return nil, nil
+	}
+
+	header := records[0]
+	switch {
+	case slices.Contains(header, "scheme") && slices.Contains(header, "operation") && slices.Contains(header, "value"):
+		return readLong(records, name)
+	case isNumber(header[1]):
+		//name, value pairs without a header, like all_startup.csv
+		source := &Source{Name: name}
+		unit, scale := unitOf(name)
+		for _, record := range records {
+			if len(record) >= 2 && isNumber(record[1]) {
+				v, _ := strconv.ParseFloat(record[1], 64)
+				series := source.series(record[0], unit)
+				series.Values[0] = append(series.Values[0], v*scale)
+			}
+		}
+		return []*Source{source}, nil
+	}
+
+	source := &Source{Name: name, XLabel: header[0], Indexed: true}
+	for _, record := range records[1:] {
+		if !isNumber(record[0]) {
+			source.Indexed = false
+		}
+	}
+	//the storage results of the scheme benchmarks are indexed by the number of attributes
+	if source.XLabel == "index" && source.Indexed {
+		source.XLabel = "attributes"
+	}
+
+	unit, scale := unitOf(name)
+	columns := make([]*Series, len(header)-1)
+	for i, column := range header[1:] {
+		columns[i] = source.series(column, unit)
+	}
+	for _, record := range records[1:] {
+		x := 0.0
+		if source.Indexed {
+			x, _ = strconv.ParseFloat(record[0], 64)
+		}
+		for i, cell := range record[1:min(len(record), len(header))] {
+			if v, err := strconv.ParseFloat(strings.TrimSpace(cell), 64); err == nil {
+				columns[i].Values[x] = append(columns[i].Values[x], v*scale)
+			}
+		}
+	}
+	return []*Source{source}, nil
+}
+
+// the results of the Go ABE driver, one row per scheme, operation and number of attributes, every scheme becomes a source
+func readLong(records [][]string, name string) ([]*Source, error) {
+	column := map[string]int{}
+	for i, h := range records[0] {
+		column[h] = i
+	}
+	if _, found := column["attributes"]; !found {
+		return nil, fmt.Errorf("long format results need an attributes column")
+	}
+
+	sources := []*Source{}
+	byScheme := map[string]*Source{}
+	for _, record := range records[1:] {
+		if len(record) != len(records[0]) {
+			return nil, fmt.Errorf("invalid row %v", record)
+		}
+		scheme := record[column["scheme"]]
+		source, found := byScheme[scheme]
+		if !found {
+			source = &Source{Name: name + "/" + scheme, XLabel: "attributes", Indexed: true}
+			byScheme[scheme] = source
+			sources = append(sources, source)
+		}
+		x, errX := strconv.ParseFloat(record[column["attributes"]], 64)
+		v, errV := strconv.ParseFloat(record[column["value"]], 64)
+		if errX != nil || errV != nil {
+			return nil, fmt.Errorf("invalid row %v", record)
+		}
+		unit := "s"
+		if i, found := column["unit"]; found {
+			unit = record[i]
+		}
+		series := source.series(record[column["operation"]], unit)
+		series.Values[x] = append(series.Values[x], v)
+	}
+	return sources, nil
+}
@@ -0,0 +1,225 @@
+/*
+
+Aggregation and charts of the benchmark results
+Reads every CSV below the input folders, the system and encoding results of this scheme as well as the scheme benchmarks,
+and writes the number of values, the mean, the standard deviation and the 95% confidence interval of every series and x
+to summary.csv. Every source with an x axis, usually the number of attributes, gets a line chart per unit, and the
+encodings get a chart of their package size against the plaintext size. The charts are written as SVG and PNG without
+any external tools
+
+	go run ./cmd/results -in results,../scheme-benchmarking/Results -out results/charts
+
+*/
+
+package main
+
+import (
+	"cmp"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pzkt/abe-scripts/abe-scheme/internal/utils"
+)
+
+func main() {
+	in := flag.String("in", "results,../scheme-benchmarking/Results", "comma separated folders with result CSVs")
+	out := flag.String("out", "results/charts", "folder for the summary and the charts")
+	formats := flag.String("formats", "svg,png", "comma separated chart formats, svg and png")
+	flag.Parse()
+
+	sources := utils.Assure(readSources(strings.Split(*in, ","), *out))
+	utils.Try(os.MkdirAll(*out, 0755))
+	utils.Try(writeSummary(filepath.Join(*out, "summary.csv"), sources))
+
+	charts := append(sourceCharts(sources), encodingCharts(sources)...)
+	for _, c := range charts {
+		for _, format := range strings.Split(*formats, ",") {
+			file := utils.Assure(os.Create(filepath.Join(*out, c.file+"."+format)))
+			switch format {
+			case "svg":
+				utils.Try(writeSVG(file, c.chart))
+			case "png":
+				utils.Try(writePNG(file, c.chart))
+			default:
+				utils.Try(fmt.Errorf("unknown chart format %s", format))
+			}
+			utils.Try(file.Close())
+		}
+	}
+	fmt.Printf("%d sources, %d charts written to %s\n", len(sources), len(charts), *out)
+}
+
+func sortedXs(s *Series) []float64 {
+	xs := []float64{}
+	for x := range s.Values {
+		xs = append(xs, x)
+	}
+	slices.Sort(xs)
+	return xs
+}
+
+func writeSummary(fileName string, sources []*Source) error {
+	records := [][]string{{"source", "series", "unit", "x", "n", "mean", "std", "ci95_low", "ci95_high"}}
+	format := func(v float64) string { return strconv.FormatFloat(v, 'g', -1, 64) }
+	for _, source := range sources {
+		for _, series := range source.Series {
+			for _, x := range sortedXs(series) {
+				s := computeStats(series.Values[x])
+				index := ""
+				if source.Indexed {
+					index = format(x)
+				}
+				records = append(records, []string{source.Name, series.Name, series.Unit, index, strconv.Itoa(s.N),
+					format(s.Mean), format(s.Std), format(s.Mean - s.CI), format(s.Mean + s.CI)})
+			}
+		}
+	}
+
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return csv.NewWriter(file).WriteAll(records)
+}
+
+type namedChart struct {
+	file  string
+	chart Chart
+}
+
+func axisLabel(unit string) string {
+	switch unit {
+	case "s", "ms":
+		return "time [" + unit + "]"
+	case "bytes":
+		return "size [bytes]"
+	}
+	return unit
+}
+
+func line(s *Series) Line {
+	l := Line{Name: s.Name}
+	for _, x := range sortedXs(s) {
+		stats := computeStats(s.Values[x])
+		l.Points = append(l.Points, Point{x, stats.Mean, stats.CI})
+	}
+	return l
+}
+
+// the storage results have a single and a hybrid column for every payload from 1 byte to 16 MiB,
+// so wide sources only draw the columns of 1 byte, 1 KiB and 1 MiB payloads, or otherwise their first columns
+func selectSeries(series []*Series) []*Series {
+	if len(series) <= len(palette) {
+		return series
+	}
+	selected := []*Series{}
+	for _, s := range series {
+		fields := strings.Fields(s.Name)
+		if len(fields) == 0 {
+			continue
+		}
+		if n, err := strconv.Atoi(fields[len(fields)-1]); err == nil && (n == 1 || n == 1<<10 || n == 1<<20) {
+			selected = append(selected, s)
+		}
+	}
+	if len(selected) == 0 || len(selected) > len(palette) {
+		return series[:len(palette)]
+	}
+	return selected
+}
+
+// a chart per unit of every source with at least two values of x
+func sourceCharts(sources []*Source) []namedChart {
+	charts := []namedChart{}
+	for _, source := range sources {
+		if !source.Indexed {
+			continue
+		}
+		units := []string{}
+		xs := map[float64]bool{}
+		for _, s := range source.Series {
+			if !slices.Contains(units, s.Unit) {
+				units = append(units, s.Unit)
+			}
+			for x := range s.Values {
+				xs[x] = true
+			}
+		}
+		if len(xs) < 2 {
+			continue
+		}
+
+		for _, unit := range units {
+			series := []*Series{}
+			for _, s := range source.Series {
+				if s.Unit == unit && len(s.Values) > 0 {
+					series = append(series, s)
+				}
+			}
+			chart := Chart{Title: source.Name, XLabel: source.XLabel, YLabel: axisLabel(unit)}
+			for _, s := range selectSeries(series) {
+				chart.Lines = append(chart.Lines, line(s))
+			}
+			file := strings.ReplaceAll(source.Name, "/", "_")
+			if len(units) > 1 {
+				file += "_" + unit
+			}
+			charts = append(charts, namedChart{file, chart})
+		}
+	}
+	return charts
+}
+
+// the mean package size of every encoding against the mean plaintext size of the small, medium and large entries
+func encodingCharts(sources []*Source) []namedChart {
+	chart := Chart{Title: "package size of the encodings", XLabel: "plaintext size [bytes]", YLabel: "size [bytes]"}
+	lines := map[string]*Line{}
+	for _, source := range sources {
+		encoding, file := path.Split(source.Name)
+		if !strings.HasSuffix(encoding, "-encoding/") || !strings.HasPrefix(file, "new_entries_") {
+			continue
+		}
+		var plaintext, pkg *Series
+		for _, s := range source.Series {
+			switch s.Name {
+			case "plaintext size":
+				plaintext = s
+			case "package size":
+				pkg = s
+			}
+		}
+		if plaintext == nil || pkg == nil {
+			continue
+		}
+
+		name := strings.TrimSuffix(encoding, "-encoding/")
+		if lines[name] == nil {
+			lines[name] = &Line{Name: name}
+		}
+		size := computeStats(pkg.Values[0])
+		lines[name].Points = append(lines[name].Points, Point{computeStats(plaintext.Values[0]).Mean, size.Mean, size.CI})
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+
+	names := []string{}
+	for name := range lines {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		l := lines[name]
+		slices.SortFunc(l.Points, func(a, b Point) int { return cmp.Compare(a.X, b.X) })
+		chart.Lines = append(chart.Lines, *l)
+	}
+	return []namedChart{{"encodings", chart}}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"strings"
+)
+
+type svgCanvas struct {
+	elements []string
+}
+
+func hex(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+func (s *svgCanvas) line(x1, y1, x2, y2 float64, c color.RGBA, width float64) {
+	s.elements = append(s.elements, fmt.Sprintf(`<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s" stroke-width="%g"/>`, x1, y1, x2, y2, hex(c), width))
+}
+
+func (s *svgCanvas) marker(x, y float64, c color.RGBA) {
+	s.elements = append(s.elements, fmt.Sprintf(`<circle cx="%.1f" cy="%.1f" r="3.5" fill="%s"/>`, x, y, hex(c)))
+}
+
+func (s *svgCanvas) text(x, y float64, text string, anchor int, size int, c color.RGBA) {
+	anchors := []string{"start", "middle", "end"}
+	s.elements = append(s.elements, fmt.Sprintf(`<text x="%.1f" y="%.1f" text-anchor="%s" font-size="%d" fill="%s">%s</text>`, x, y, anchors[anchor], 6+6*size, hex(c), html.EscapeString(text)))
+}
+
+func writeSVG(w io.Writer, ch Chart) error {
+	s := &svgCanvas{}
+	ch.draw(s)
+	_, err := fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="sans-serif">
+<rect width="100%%" height="100%%" fill="white"/>
+%s
+</svg>
+`, chartWidth, chartHeight, strings.Join(s.elements, "\n"))
+	return err
+}
+
+type pngCanvas struct {
+	img *image.RGBA
+}
+
+func (p *pngCanvas) dot(x, y int, c color.RGBA) {
+	if image.Pt(x, y).In(p.img.Bounds()) {
+		p.img.SetRGBA(x, y, c)
+	}
+}
+
+// a line of square dots, as many as the line is long
+func (p *pngCanvas) line(x1, y1, x2, y2 float64, c color.RGBA, width float64) {
+	steps := math.Max(math.Abs(x2-x1), math.Abs(y2-y1))
+	size := int(math.Max(1, width))
+	for i := 0.0; i <= steps; i++ {
+		t := 0.0
+		if steps > 0 {
+			t = i / steps
+		}
+		x, y := int(math.Round(x1+t*(x2-x1))), int(math.Round(y1+t*(y2-y1)))
+		for dx := range size {
+			for dy := range size {
+				p.dot(x+dx-size/2, y+dy-size/2, c)
+			}
+		}
+	}
+}
+
+func (p *pngCanvas) marker(x, y float64, c color.RGBA) {
+	for dx := -3; dx <= 3; dx++ {
+		for dy := -3; dy <= 3; dy++ {
+			if dx*dx+dy*dy <= 10 {
+				p.dot(int(x)+dx, int(y)+dy, c)
+			}
+		}
+	}
+}
+
+// the glyphs of the bitmap font scaled by size, the baseline is at y
+func (p *pngCanvas) text(x, y float64, text string, anchor int, size int, c color.RGBA) {
+	width := float64(len(text) * 6 * size)
+	switch anchor {
+	case anchorMiddle:
+		x -= width / 2
+	case anchorEnd:
+		x -= width
+	}
+	top := int(y) - 7*size
+	for i, r := range text {
+		glyph := glyphFor(r)
+		left := int(x) + i*6*size
+		for col, bits := range glyph {
+			for row := range 8 {
+				if bits&(1<<row) == 0 {
+					continue
+				}
+				for dx := range size {
+					for dy := range size {
+						p.dot(left+col*size+dx, top+row*size+dy, c)
+					}
+				}
+			}
+		}
+	}
+}
+
+func writePNG(w io.Writer, ch Chart) error {
+	img := image.NewRGBA(image.Rect(0, 0, chartWidth, chartHeight))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	ch.draw(&pngCanvas{img})
+	return png.Encode(w, img)
+}
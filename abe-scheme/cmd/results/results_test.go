@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestComputeStats(t *testing.T) {
+	s := computeStats([]float64{1, 2, 3})
+	if s.N != 3 || s.Mean != 2 || s.Std != 1 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+	if want := 4.303 / math.Sqrt(3); math.Abs(s.CI-want) > 1e-9 {
+		t.Errorf("confidence interval %v, want %v", s.CI, want)
+	}
+
+	if s := computeStats([]float64{5}); s.Mean != 5 || s.Std != 0 || s.CI != 0 {
+		t.Errorf("a single value should have no spread, got %+v", s)
+	}
+	if s := computeStats(nil); s.N != 0 || s.Mean != 0 {
+		t.Errorf("no values should give empty stats, got %+v", s)
+	}
+}
+
+func writeTestFile(t *testing.T, dir string, name string, content string) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadSources(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "system-time-upload/policy.csv", "attributes,small,large\n1,1000000000,2000000000\n1,3000000000,\n5,4000000000,8000000000\n")
+	writeTestFile(t, dir, "cbor-encoding/new_entries_small.csv", "uuid,plaintext size,package size\n4f0c,100,300\n9a1b,120,340\n")
+	writeTestFile(t, dir, "go_abe.csv", "scheme,operation,attributes,value,unit,iterations\nfame,keygen,2,0.5,s,10\nfame,ciphertext,2,800,bytes,10\nfame,keygen,4,0.9,s,10\ntkn20,keygen,2,0.1,s,10\n")
+	writeTestFile(t, dir, "all_startup.csv", "authority,12\ndatabase,4\n")
+	writeTestFile(t, dir, "charts/summary.csv", "source,series\n")
+
+	sources, err := readSources([]string{dir}, filepath.Join(dir, "charts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	byName := map[string]*Source{}
+	for _, s := range sources {
+		byName[s.Name] = s
+	}
+	if len(byName) != 5 || byName["charts/summary"] != nil {
+		t.Fatalf("unexpected sources %v", byName)
+	}
+
+	policy := byName["system-time-upload/policy"]
+	if !policy.Indexed || policy.XLabel != "attributes" || len(policy.Series) != 2 {
+		t.Fatalf("unexpected wide source %+v", policy)
+	}
+	small := policy.Series[0]
+	if small.Unit != "s" || len(small.Values[1]) != 2 || small.Values[1][1] != 3 || small.Values[5][0] != 4 {
+		t.Errorf("nanoseconds should be read as seconds by x, got %v", small.Values)
+	}
+	if large := policy.Series[1]; len(large.Values[1]) != 1 {
+		t.Errorf("empty cells should be skipped, got %v", large.Values)
+	}
+
+	encoding := byName["cbor-encoding/new_entries_small"]
+	if encoding.Indexed || encoding.Series[1].Unit != "bytes" || len(encoding.Series[1].Values[0]) != 2 {
+		t.Errorf("rows without a numeric index should be kept at x = 0, got %+v", encoding)
+	}
+
+	fame := byName["go_abe/fame"]
+	if fame == nil || byName["go_abe/tkn20"] == nil || len(fame.Series) != 2 {
+		t.Fatalf("the long format should give one source per scheme, got %+v", fame)
+	}
+	if fame.Series[1].Unit != "bytes" || fame.Series[1].Values[2][0] != 800 {
+		t.Errorf("unexpected long format series %+v", fame.Series[1])
+	}
+
+	startup := byName["all_startup"]
+	if len(startup.Series) != 2 || startup.Series[0].Unit != "ms" || startup.Series[0].Values[0][0] != 12 {
+		t.Errorf("unexpected headerless source %+v", startup)
+	}
+
+	charts := sourceCharts(sources)
+	if len(charts) != 3 {
+		t.Errorf("expected a chart for the policy and one per unit of fame, got %d", len(charts))
+	}
+	if encodings := encodingCharts(sources); len(encodings) != 1 || encodings[0].chart.Lines[0].Points[0].X != 110 {
+		t.Errorf("unexpected encoding chart %+v", encodings)
+	}
+}
+
+func TestAxis(t *testing.T) {
+	a := newAxis([]float64{3, 45000}, 0, 100, true)
+	if !a.log || a.min != 1 || a.max != 1e5 {
+		t.Fatalf("unexpected axis %+v", a)
+	}
+	if p := a.position(1000); math.Abs(p-60) > 1e-9 {
+		t.Errorf("position %v, want 60", p)
+	}
+	if ticks := a.ticks(); len(ticks) != 6 {
+		t.Errorf("unexpected ticks %v", ticks)
+	}
+
+	a = newAxis([]float64{1, 50}, 0, 100, false)
+	if a.log || a.min != 1 || a.max != 50 {
+		t.Fatalf("unexpected axis %+v", a)
+	}
+	if ticks := a.ticks(); ticks[0] != 10 || ticks[len(ticks)-1] != 50 {
+		t.Errorf("unexpected ticks %v", ticks)
+	}
+}
+
+func TestRender(t *testing.T) {
+	chart := Chart{Title: "keygen <time>", XLabel: "attributes", YLabel: "time [s]", Lines: []Line{
+		{Name: "fame", Points: []Point{{1, 0.1, 0.01}, {5, 0.4, 0.02}, {10, 0.9, 0}}},
+		{Name: "tkn20", Points: []Point{{1, 0.05, 0}, {10, 0.3, 0.1}}},
+	}}
+
+	var svg bytes.Buffer
+	if err := writeSVG(&svg, chart); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(svg.String(), "<svg") || !strings.Contains(svg.String(), "keygen &lt;time&gt;") || strings.Count(svg.String(), "<circle") != 5+2 {
+		t.Errorf("unexpected svg %s", svg.String())
+	}
+
+	var buf bytes.Buffer
+	if err := writePNG(&buf, chart); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != chartWidth || b.Dy() != chartHeight {
+		t.Errorf("unexpected size %v", b)
+	}
+}
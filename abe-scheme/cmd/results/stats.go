@@ -0,0 +1,48 @@
+package main
+
+import (
+	"math"
+)
+
+type Stats struct {
+	N    int
+	Mean float64
+	Std  float64 // sample standard deviation, 0 for a single value
+	CI   float64 // half width of the 95% confidence interval of the mean, 0 for a single value
+}
+
+// two sided 97.5% quantiles of Student's t distribution for 1 to 30 degrees of freedom
+var tQuantiles = []float64{
+	12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+	2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+	2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042,
+}
+
+func tQuantile(df int) float64 {
+	if df <= len(tQuantiles) {
+		return tQuantiles[df-1]
+	}
+	return 1.96
+}
+
+func computeStats(values []float64) Stats {
+	s := Stats{N: len(values)}
+	if s.N == 0 {
+		return s
+	}
+	for _, v := range values {
+		s.Mean += v
+	}
+	s.Mean /= float64(s.N)
+	if s.N == 1 {
+		return s
+	}
+
+	var squares float64
+	for _, v := range values {
+		squares += (v - s.Mean) * (v - s.Mean)
+	}
+	s.Std = math.Sqrt(squares / float64(s.N-1))
+	s.CI = tQuantile(s.N-1) * s.Std / math.Sqrt(float64(s.N))
+	return s
+}
@@ -2,12 +2,18 @@ module github.com/pzkt/abe-scripts/abe-scheme
 
 go 1.23.4
 
-require github.com/fentec-project/gofe v0.0.0-20220829150550-ccc7482d20ef
+require (
+	github.com/BurntSushi/toml v1.6.0
+	github.com/fentec-project/gofe v0.0.0-20220829150550-ccc7482d20ef
+	github.com/fxamacker/cbor/v2 v2.8.0
+	github.com/ldsec/lattigo/v2 v2.4.1
+	github.com/vmihailenco/msgpack/v5 v5.4.1
+	golang.org/x/term v0.32.0
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	github.com/brianvoe/gofakeit/v7 v7.2.1 // indirect
-	github.com/fxamacker/cbor/v2 v2.8.0 // indirect
-	github.com/vmihailenco/msgpack/v5 v5.4.1 // indirect
 	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
 	github.com/x448/float16 v0.8.4 // indirect
 )
@@ -19,6 +25,6 @@ require (
 	github.com/lib/pq v1.10.9
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/pzkt/abe-scripts/generate-pseudodata v0.0.0-20250618225459-e749081f17fc
-	golang.org/x/crypto v0.39.0 // indirect
+	golang.org/x/crypto v0.39.0
 	golang.org/x/sys v0.33.0 // indirect
 )
@@ -0,0 +1,298 @@
+/*
+
+Shared configuration for the authority, database and client
+Values are resolved in the following order, where later sources override earlier ones:
+defaults -> config file (YAML, or TOML for .toml files) -> environment variables (ABE_*) -> command line flags
+
+*/
+
+package config
+
+import (
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+// environment variable that points to a config file if no -config flag is given
+const configFileEnv = "ABE_CONFIG"
+
+type Config struct {
+	Postgres      Postgres `yaml:"postgres" toml:"postgres"`
+	DatabasePort  int      `yaml:"database_port" toml:"database_port"`
+	AuthorityPort int      `yaml:"authority_port" toml:"authority_port"`
+	DatabaseURL   string   `yaml:"database_url" toml:"database_url"`
+	AuthorityURL  string   `yaml:"authority_url" toml:"authority_url"`
+	AuthorityUUID string   `yaml:"authority_uuid" toml:"authority_uuid"`
+
+	//purpose tree file published by the authority, the example trees are used if it is empty
+	PurposeTrees string `yaml:"purpose_trees" toml:"purpose_trees"`
+	//seconds between checks for changes of the purpose tree file, 0 disables reloading
+	PurposeTreesReload int `yaml:"purpose_trees_reload" toml:"purpose_trees_reload"`
+	//how purposes are inherited along the purpose trees, upward or downward
+	Inheritance string `yaml:"inheritance" toml:"inheritance"`
+	//file the authority keeps the key it signs the policy configs with, the database only accepts new versions signed with the same key
+	RelationsKey string `yaml:"relations_key" toml:"relations_key"`
+	//length of the time epochs in seconds, time bound keys are valid for one epoch
+	TimeEpoch int `yaml:"time_epoch" toml:"time_epoch"`
+
+	//file the client keeps its keys in, encrypted with a passphrase from ABE_KEYRING_PASSPHRASE or the terminal.
+	//Keys are only kept in memory if it is empty
+	Keyring string `yaml:"keyring" toml:"keyring"`
+	//hex encoded secret shared by the clients that may search entries by their blind indexed and deterministic fields
+	SearchKey string `yaml:"search_key" toml:"search_key"`
+}
+
+type Postgres struct {
+	Host     string `yaml:"host" toml:"host"`
+	Port     int    `yaml:"port" toml:"port"`
+	User     string `yaml:"user" toml:"user"`
+	Password string `yaml:"password" toml:"password"`
+	Name     string `yaml:"name" toml:"name"`
+	SSLMode  string `yaml:"sslmode" toml:"sslmode"`
+}
+
+// the values used before the configuration system existed
+func Default() Config {
+	return Config{
+		Postgres: Postgres{
+			Host:     "localhost",
+			Port:     5432,
+			User:     "postgres",
+			Password: "pwd",
+			Name:     "data",
+			SSLMode:  "disable",
+		},
+		DatabasePort:  8080,
+		AuthorityPort: 8081,
+		DatabaseURL:   "http://localhost:8080",
+		AuthorityURL:  "http://localhost:8081",
+		AuthorityUUID: "497dcba3-ecbf-4587-a2dd-5eb0665e6880",
+
+		PurposeTreesReload: 5,
+		Inheritance:        "upward",
+		RelationsKey:       "relations-key.pem",
+		TimeEpoch:          3600,
+	}
+}
+
+// connection string for the postgres driver, user and password may contain any character
+func (p Postgres) DSN() string {
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(p.User, p.Password),
+		Host:     net.JoinHostPort(p.Host, strconv.Itoa(p.Port)),
+		Path:     p.Name,
+		RawQuery: url.Values{"sslmode": {p.SSLMode}}.Encode(),
+	}
+	return u.String()
+}
+
+// address the database server listens on
+func (c Config) DatabaseAddr() string {
+	return fmt.Sprintf(":%d", c.DatabasePort)
+}
+
+// address the key authority listens on
+func (c Config) AuthorityAddr() string {
+	return fmt.Sprintf(":%d", c.AuthorityPort)
+}
+
+// a single configurable value, addressed by its key in the config file
+type setting struct {
+	key    string
+	target any
+}
+
+func (c *Config) settings() []setting {
+	return []setting{
+		{"postgres.host", &c.Postgres.Host},
+		{"postgres.port", &c.Postgres.Port},
+		{"postgres.user", &c.Postgres.User},
+		{"postgres.password", &c.Postgres.Password},
+		{"postgres.name", &c.Postgres.Name},
+		{"postgres.sslmode", &c.Postgres.SSLMode},
+		{"database_port", &c.DatabasePort},
+		{"authority_port", &c.AuthorityPort},
+		{"database_url", &c.DatabaseURL},
+		{"authority_url", &c.AuthorityURL},
+		{"authority_uuid", &c.AuthorityUUID},
+		{"purpose_trees", &c.PurposeTrees},
+		{"purpose_trees_reload", &c.PurposeTreesReload},
+		{"inheritance", &c.Inheritance},
+		{"relations_key", &c.RelationsKey},
+		{"time_epoch", &c.TimeEpoch},
+		{"keyring", &c.Keyring},
+		{"search_key", &c.SearchKey},
+	}
+}
+
+// postgres.host -> ABE_POSTGRES_HOST
+func (s setting) env() string {
+	return "ABE_" + strings.ToUpper(strings.NewReplacer(".", "_").Replace(s.key))
+}
+
+// postgres.host -> postgres-host
+func (s setting) flag() string {
+	return strings.NewReplacer(".", "-", "_", "-").Replace(s.key)
+}
+
+func (s setting) set(value string) error {
+	switch t := s.target.(type) {
+	case *string:
+		*t = value
+	case *int:
+		v, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", s.key, err)
+		}
+		*t = v
+	}
+	return nil
+}
+
+// load the configuration from a file, the environment and the given command line arguments, then validate it
+// args should not include the program name, pass nil to skip flag parsing (e.g. in tests)
+func Load(args []string) (Config, error) {
+	c := Default()
+
+	fs := flag.NewFlagSet("abe-scheme", flag.ContinueOnError)
+	file := fs.String("config", os.Getenv(configFileEnv), "path to a YAML or TOML config file")
+	flagValues := map[string]*string{}
+	for _, s := range c.settings() {
+		flagValues[s.key] = fs.String(s.flag(), "", fmt.Sprintf("overrides %s (env %s)", s.key, s.env()))
+	}
+	if err := fs.Parse(args); err != nil {
+		return c, err
+	}
+
+	if *file != "" {
+		if err := c.loadFile(*file); err != nil {
+			return c, err
+		}
+	}
+
+	for _, s := range c.settings() {
+		if value, ok := os.LookupEnv(s.env()); ok {
+			if err := s.set(value); err != nil {
+				return c, err
+			}
+		}
+	}
+
+	//only flags that were explicitly given override the other sources
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range c.settings() {
+			if s.flag() == f.Name && err == nil {
+				err = s.set(*flagValues[s.key])
+			}
+		}
+	})
+	if err != nil {
+		return c, err
+	}
+
+	return c, c.Validate()
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading config file: %w", err)
+	}
+	if filepath.Ext(path) == ".toml" {
+		err = toml.Unmarshal(data, c)
+	} else {
+		err = yaml.Unmarshal(data, c)
+	}
+	if err != nil {
+		return fmt.Errorf("error parsing config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// check that the configuration can actually be used, all problems are reported at once
+func (c Config) Validate() error {
+	var errs []error
+
+	ports := []struct {
+		name string
+		port int
+	}{
+		{"postgres.port", c.Postgres.Port},
+		{"database_port", c.DatabasePort},
+		{"authority_port", c.AuthorityPort},
+	}
+	for _, p := range ports {
+		if p.port < 1 || p.port > 65535 {
+			errs = append(errs, fmt.Errorf("%s: port %d out of range", p.name, p.port))
+		}
+	}
+
+	required := []struct {
+		name  string
+		value string
+	}{
+		{"postgres.host", c.Postgres.Host},
+		{"postgres.user", c.Postgres.User},
+		{"postgres.password", c.Postgres.Password},
+		{"postgres.name", c.Postgres.Name},
+	}
+	for _, r := range required {
+		if r.value == "" {
+			errs = append(errs, fmt.Errorf("%s: must not be empty", r.name))
+		}
+	}
+
+	urls := []struct {
+		name  string
+		value string
+	}{
+		{"database_url", c.DatabaseURL},
+		{"authority_url", c.AuthorityURL},
+	}
+	for _, r := range urls {
+		u, err := url.Parse(r.value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("%s: %q is not an http(s) URL", r.name, r.value))
+		}
+	}
+
+	if c.PurposeTreesReload < 0 {
+		errs = append(errs, fmt.Errorf("purpose_trees_reload: %d must not be negative", c.PurposeTreesReload))
+	}
+
+	if c.Inheritance != "upward" && c.Inheritance != "downward" {
+		errs = append(errs, fmt.Errorf("inheritance: %q must be upward or downward", c.Inheritance))
+	}
+
+	if c.TimeEpoch < 1 {
+		errs = append(errs, fmt.Errorf("time_epoch: %d must be at least one second", c.TimeEpoch))
+	}
+
+	if c.SearchKey != "" {
+		if key, err := hex.DecodeString(c.SearchKey); err != nil {
+			errs = append(errs, fmt.Errorf("search_key: %w", err))
+		} else if len(key) < 16 {
+			errs = append(errs, fmt.Errorf("search_key: %d bytes are too short, use at least 16", len(key)))
+		}
+	}
+
+	if _, err := uuid.Parse(c.AuthorityUUID); err != nil {
+		errs = append(errs, fmt.Errorf("authority_uuid: %w", err))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadPrecedence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	content := "database_port: 9000\nauthority_port: 9001\npostgres:\n  password: fromfile\n"
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(configFileEnv, file)
+	t.Setenv("ABE_AUTHORITY_PORT", "9002")
+	t.Setenv("ABE_POSTGRES_PASSWORD", "fromenv")
+
+	c, err := Load([]string{"-postgres-password", "fromflag"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.DatabasePort != 9000 {
+		t.Errorf("file value not applied: database_port = %d", c.DatabasePort)
+	}
+	if c.AuthorityPort != 9002 {
+		t.Errorf("environment did not override file: authority_port = %d", c.AuthorityPort)
+	}
+	if c.Postgres.Password != "fromflag" {
+		t.Errorf("flag did not override environment: password = %q", c.Postgres.Password)
+	}
+	if c.Postgres.Host != Default().Postgres.Host {
+		t.Errorf("default lost: host = %q", c.Postgres.Host)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Default().Validate(); err != nil {
+		t.Fatalf("default config should be valid: %v", err)
+	}
+
+	c := Default()
+	c.DatabasePort = 0
+	c.AuthorityURL = "localhost:8081"
+	c.AuthorityUUID = "not-a-uuid"
+	c.TimeEpoch = 0
+	c.SearchKey = "0011"
+
+	err := c.Validate()
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, want := range []string{"database_port", "authority_url", "authority_uuid", "time_epoch", "search_key"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("missing error for %s in %q", want, err)
+		}
+	}
+}
+
+func TestDSN(t *testing.T) {
+	p := Default().Postgres
+	p.User, p.Password, p.Name = "abe user", "p@ss:w/rd?#%", "data"
+
+	u, err := url.Parse(p.DSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+	password, _ := u.User.Password()
+	if u.User.Username() != p.User || password != p.Password {
+		t.Errorf("credentials changed: %q %q", u.User.Username(), password)
+	}
+	if u.Host != "localhost:5432" || u.Path != "/data" || u.Query().Get("sslmode") != "disable" {
+		t.Errorf("unexpected DSN %s", p.DSN())
+	}
+}
+
+func TestLoadTOML(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.toml")
+	content := "database_port = 9000\ninheritance = \"downward\"\n\n[postgres]\npassword = \"fromfile\"\n"
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := Load([]string{"-config", file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.DatabasePort != 9000 || c.Inheritance != "downward" || c.Postgres.Password != "fromfile" {
+		t.Errorf("file values not applied: %+v", c)
+	}
+	if c.Postgres.Host != Default().Postgres.Host {
+		t.Errorf("default lost: host = %q", c.Postgres.Host)
+	}
+}
+
+// the example configs have to stay in sync
+func TestExampleConfigs(t *testing.T) {
+	yamlConfig, err := Load([]string{"-config", "../../configs/config.example.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tomlConfig, err := Load([]string{"-config", "../../configs/config.example.toml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if yamlConfig != tomlConfig {
+		t.Errorf("example configs differ:\n%+v\n%+v", yamlConfig, tomlConfig)
+	}
+}
+
+// the keyring passphrase is read by the client from its environment or terminal, never from flags
+func TestNoPassphraseFlag(t *testing.T) {
+	if _, err := Load([]string{"-keyring-passphrase", "secret"}); err == nil {
+		t.Error("the keyring passphrase should not be accepted as a flag")
+	}
+}
@@ -10,8 +10,13 @@ package crypto
 
 import (
 	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/fentec-project/gofe/abe"
+	"github.com/fentec-project/gofe/data"
 	"github.com/pzkt/abe-scripts/abe-scheme/internal/utils"
 )
 
@@ -37,11 +42,11 @@ func Setup() *ABEscheme {
 
 func (s *ABEscheme) EndToEndTest() {
 
-	cipher := s.Encrypt(utils.ToBytes("wow schgloopy"), "test OR few")
+	cipher := utils.Assure(s.Encrypt(utils.ToBytes("wow schgloopy"), "test OR few"))
 
 	key := s.KeyGen([]string{"test", "wow"})
 
-	text := s.Decrypt(cipher, key)
+	text := utils.Assure(s.Decrypt(cipher, key))
 
 	fmt.Println(string(text))
 }
@@ -52,20 +57,179 @@ func (s *ABEscheme) KeyGen(attributes []string) []byte {
 	return keyBytes
 }
 
-func (s *ABEscheme) Encrypt(data []byte, policy string) []byte {
-	msp, _ := abe.BooleanToMSP(policy, false)
-	cipher, _ := s.Scheme.Encrypt(string(data), msp, s.PublicKey)
-
-	return utils.ToBytes(cipher)
+// policies that use an attribute more than once are rejected by FAME
+func (s *ABEscheme) Encrypt(data []byte, policy string) ([]byte, error) {
+	msp, err := PolicyToMSP(policy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid policy %q: %w", policy, err)
+	}
+	cipher, err := s.Scheme.Encrypt(string(data), msp, s.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("cannot encrypt under %q: %w", policy, err)
+	}
+	return utils.ToBytes(cipher), nil
 }
 
-func (s *ABEscheme) Decrypt(ciphertext []byte, secret_key []byte) []byte {
+// keys that do not satisfy the policy of the ciphertext are rejected by FAME
+func (s *ABEscheme) Decrypt(ciphertext []byte, secret_key []byte) ([]byte, error) {
 	var cipher abe.FAMECipher
-	utils.FromBytes(ciphertext, &cipher)
+	if err := utils.DecodeBytes(ciphertext, &cipher); err != nil {
+		return nil, fmt.Errorf("invalid ciphertext: %w", err)
+	}
+
+	var key abe.FAMEAttribKeys
+	if err := utils.DecodeBytes(secret_key, &key); err != nil {
+		return nil, fmt.Errorf("invalid key: %w", err)
+	}
+
+	//the data as it was given to Encrypt
+	plaintext, err := s.Scheme.Decrypt(&cipher, &key, s.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt: %w", err)
+	}
+	return []byte(plaintext), nil
+}
 
+// the attributes a key was generated for, these are not secret and can be read without decrypting anything
+func KeyAttributes(secret_key []byte) []string {
 	var key abe.FAMEAttribKeys
 	utils.FromBytes(secret_key, &key)
 
-	plaintext := utils.Assure(s.Scheme.Decrypt(&cipher, &key, s.PublicKey))
-	return utils.ToBytes(plaintext)
+	attributes := make([]string, 0, len(key.AttribToI))
+	for attribute := range key.AttribToI {
+		attributes = append(attributes, attribute)
+	}
+	sort.Strings(attributes)
+	return attributes
+}
+
+// a gate of an attribute policy, AND is 2 of 2 and OR is 1 of 2, attributes have no children
+type PolicyGate struct {
+	Threshold int
+	Attribute string
+	Children  []*PolicyGate
+}
+
+// parse an attribute policy the way the ABE scheme reads it: the first AND or OR outside of brackets
+// splits the policy, so "a AND b OR c" means "a AND (b OR c)", and "k of (a, b, c)" is a threshold
+func ParsePolicy(policy string) (*PolicyGate, error) {
+	gate, _, err := parsePolicy(policy)
+	return gate, err
+}
+
+// the parsed policy and whether it has any "k of" thresholds
+func parsePolicy(policy string) (*PolicyGate, bool, error) {
+	tokens := strings.Fields(strings.NewReplacer("(", " ( ", ")", " ) ", ",", " , ").Replace(policy))
+	gate, rest, hasThreshold, err := parsePolicySequence(tokens)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(rest) > 0 {
+		return nil, false, fmt.Errorf("unexpected %q in policy %q", rest[0], policy)
+	}
+	return gate, hasThreshold, nil
+}
+
+// the MSP of an attribute policy of AND and OR gates and "k of (a, b, c)" thresholds
+// policies without thresholds are converted by gofe's BooleanToMSP. A threshold written as an OR over ANDs would put
+// its attributes on several rows, so thresholds are shared directly, see rows
+func PolicyToMSP(policy string) (*abe.MSP, error) {
+	gate, hasThreshold, err := parsePolicy(policy)
+	if err != nil {
+		return nil, err
+	}
+	if !hasThreshold {
+		return abe.BooleanToMSP(policy, false)
+	}
+
+	msp := &abe.MSP{}
+	columns := 1
+	gate.rows(data.Vector{big.NewInt(1)}, &columns, msp)
+	for i, row := range msp.Mat {
+		for len(row) < columns {
+			row = append(row, big.NewInt(0))
+		}
+		msp.Mat[i] = row
+	}
+	return msp, nil
+}
+
+func parsePolicySequence(tokens []string) (*PolicyGate, []string, bool, error) {
+	if len(tokens) == 0 {
+		return nil, nil, false, fmt.Errorf("unexpected end of policy")
+	}
+
+	var left *PolicyGate
+	hasThreshold := false
+	k, err := strconv.Atoi(tokens[0])
+	switch {
+	case err == nil && len(tokens) > 2 && tokens[1] == "of" && tokens[2] == "(":
+		left, hasThreshold = &PolicyGate{Threshold: k}, true
+		tokens = tokens[2:]
+		for len(tokens) > 0 && (tokens[0] == "(" || tokens[0] == ",") {
+			child, rest, _, err := parsePolicySequence(tokens[1:])
+			if err != nil {
+				return nil, nil, false, err
+			}
+			left.Children = append(left.Children, child)
+			tokens = rest
+		}
+		if len(tokens) == 0 || tokens[0] != ")" {
+			return nil, nil, false, fmt.Errorf("missing ')'")
+		}
+		if k < 1 || k > len(left.Children) {
+			return nil, nil, false, fmt.Errorf("threshold must be between 1 and %d, got %d", len(left.Children), k)
+		}
+		tokens = tokens[1:]
+	case tokens[0] == "(":
+		inner, rest, innerThreshold, err := parsePolicySequence(tokens[1:])
+		if err != nil {
+			return nil, nil, false, err
+		}
+		if len(rest) == 0 || rest[0] != ")" {
+			return nil, nil, false, fmt.Errorf("missing ')'")
+		}
+		left, tokens, hasThreshold = inner, rest[1:], innerThreshold
+	case tokens[0] == ")", tokens[0] == ",", tokens[0] == "AND", tokens[0] == "OR":
+		return nil, nil, false, fmt.Errorf("unexpected %q", tokens[0])
+	default:
+		left, tokens = &PolicyGate{Attribute: tokens[0]}, tokens[1:]
+	}
+
+	if len(tokens) == 0 || (tokens[0] != "AND" && tokens[0] != "OR") {
+		return left, tokens, hasThreshold, nil
+	}
+	threshold := map[string]int{"AND": 2, "OR": 1}[tokens[0]]
+	right, rest, rightThreshold, err := parsePolicySequence(tokens[1:])
+	if err != nil {
+		return nil, nil, false, err
+	}
+	return &PolicyGate{Threshold: threshold, Children: []*PolicyGate{left, right}}, rest, hasThreshold || rightThreshold, nil
+}
+
+// the Lewko-Waters construction generalized to thresholds: a k of n gate with vector v gives its i-th child
+// v followed by i, i^2, ..., i^(k-1) in k-1 new columns. These are the values at i of a polynomial of degree k-1 whose
+// value at 0 is v, so any k children interpolate v and fewer learn nothing about it. Rows are padded with zeros later
+func (g *PolicyGate) rows(v data.Vector, columns *int, msp *abe.MSP) {
+	if g.Children == nil {
+		msp.Mat = append(msp.Mat, v)
+		msp.RowToAttrib = append(msp.RowToAttrib, g.Attribute)
+		return
+	}
+
+	first := *columns
+	*columns += g.Threshold - 1
+	for i, c := range g.Children {
+		child := make(data.Vector, first+g.Threshold-1)
+		for j := range child {
+			child[j] = big.NewInt(0)
+		}
+		copy(child, v)
+		x, power := big.NewInt(int64(i+1)), big.NewInt(1)
+		for j := first; j < len(child); j++ {
+			power = new(big.Int).Mul(power, x)
+			child[j] = power
+		}
+		c.rows(child, columns, msp)
+	}
 }
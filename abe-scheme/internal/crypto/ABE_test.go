@@ -0,0 +1,101 @@
+package crypto
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/fentec-project/gofe/abe"
+	"github.com/pzkt/abe-scripts/abe-scheme/internal/utils"
+)
+
+// decrypt with every subset of the attributes and compare with the number of attributes the policy needs
+func TestThresholdPolicies(t *testing.T) {
+	s := Setup()
+	for _, tc := range []struct {
+		policy     string
+		attributes []string
+		decrypts   func(held []string) bool
+	}{
+		{"2 of (A, B, C)", []string{"A", "B", "C"}, func(held []string) bool { return len(held) >= 2 }},
+		{"3 of (A, B, C, D)", []string{"A", "B", "C", "D"}, func(held []string) bool { return len(held) >= 3 }},
+		{"E AND 2 of (A, B OR C, (D AND F))", []string{"A", "B", "C", "D", "E", "F"}, func(held []string) bool {
+			has := func(a string) bool { return slices.Contains(held, a) }
+			count := 0
+			for _, ok := range []bool{has("A"), has("B") || has("C"), has("D") && has("F")} {
+				if ok {
+					count++
+				}
+			}
+			return has("E") && count >= 2
+		}},
+		{"1 of (A, 2 of (B, C, D))", []string{"A", "B", "C", "D"}, func(held []string) bool {
+			return slices.Contains(held, "A") || len(held) >= 2
+		}},
+	} {
+		cipher, err := s.Encrypt([]byte("plaintext"), tc.policy)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.policy, err)
+		}
+		var fameCipher abe.FAMECipher
+		utils.FromBytes(cipher, &fameCipher)
+
+		for mask := 1; mask < 1<<len(tc.attributes); mask++ {
+			held := []string{}
+			for i, a := range tc.attributes {
+				if mask&(1<<i) != 0 {
+					held = append(held, a)
+				}
+			}
+			key := utils.Assure(s.Scheme.GenerateAttribKeys(held, s.SecretKey))
+			plaintext, err := s.Scheme.Decrypt(&fameCipher, key, s.PublicKey)
+			if want := tc.decrypts(held); (err == nil) != want {
+				t.Errorf("%s with %v: decryption error %v, want success %v", tc.policy, held, err, want)
+			}
+			if err == nil && plaintext != "plaintext" {
+				t.Errorf("%s with %v: decrypted %q", tc.policy, held, plaintext)
+			}
+		}
+	}
+}
+
+func TestEncryptErrors(t *testing.T) {
+	s := Setup()
+	for _, policy := range []string{"A AND (B OR A)", "2 of (A, B, A)", "4 of (A, B, C)", "2 of (A, B", "A AND"} {
+		if _, err := s.Encrypt([]byte("plaintext"), policy); err == nil {
+			t.Errorf("%q should not be encrypted", policy)
+		}
+	}
+}
+
+// the first AND or OR outside of brackets splits the policy, like BooleanToMSP
+func TestParsePolicy(t *testing.T) {
+	gate, err := ParsePolicy("a AND b OR 2 of (c, d, e)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gate.Threshold != 2 || gate.Children[0].Attribute != "a" {
+		t.Fatalf("expected a AND (...), got %+v", gate)
+	}
+	or := gate.Children[1]
+	if or.Threshold != 1 || or.Children[0].Attribute != "b" {
+		t.Fatalf("expected b OR (...), got %+v", or)
+	}
+	if threshold := or.Children[1]; threshold.Threshold != 2 || len(threshold.Children) != 3 {
+		t.Fatalf("expected 2 of 3, got %+v", threshold)
+	}
+}
+
+// keys that do not satisfy the policy and malformed input are errors instead of stopping the caller
+func TestDecryptErrors(t *testing.T) {
+	s := Setup()
+	cipher := utils.Assure(s.Encrypt([]byte("plaintext"), "A AND B"))
+	if plaintext, err := s.Decrypt(cipher, s.KeyGen([]string{"A", "B"})); err != nil || string(plaintext) != "plaintext" {
+		t.Fatalf("decrypted %q, error %v", plaintext, err)
+	}
+	if _, err := s.Decrypt(cipher, s.KeyGen([]string{"A"})); err == nil {
+		t.Error("a key with only A should not decrypt A AND B")
+	}
+	if _, err := s.Decrypt([]byte("not a ciphertext"), s.KeyGen([]string{"A", "B"})); err == nil {
+		t.Error("a malformed ciphertext should be an error")
+	}
+}
@@ -0,0 +1,109 @@
+/*
+
+Functions for homomorphic aggregates over encrypted numeric fields
+Values are BFV encrypted with the public analytics key, together with a 1 in the second slot, so adding the ciphertexts
+of several entries gives both the sum and the count. Adding needs no key, the database can aggregate without learning the values
+and only the holder of the secret analytics key can decrypt the result
+
+*/
+
+package crypto
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ldsec/lattigo/v2/bfv"
+	"github.com/ldsec/lattigo/v2/rlwe"
+	"github.com/pzkt/abe-scripts/abe-scheme/internal/utils"
+)
+
+// the default parameters for N = 4096 with a larger plaintext modulus, sums and counts are computed modulo AggregateModulus
+// the modulus is a prime congruent 1 modulo 2N so values can be encoded in slots
+var analyticsParameters = bfv.ParametersLiteral{
+	LogN:  12,
+	T:     0x3ee0001,
+	Q:     bfv.PN12QP109.Q,
+	P:     bfv.PN12QP109.P,
+	Sigma: rlwe.DefaultSigma,
+}
+
+const AggregateModulus = 0x3ee0001
+
+// sums are only correct while they stay below AggregateModulus, so values and the number of values in an aggregate
+// are bounded, enough for measurements such as heart rates or blood pressures of about 16000 entries
+const (
+	MaxAggregateValue = 1<<12 - 1
+	MaxAggregateCount = (AggregateModulus - 1) / MaxAggregateValue
+)
+
+func analyticsParams() bfv.Parameters {
+	return utils.Assure(bfv.NewParametersFromLiteral(analyticsParameters))
+}
+
+// a new BFV key pair, both marshaled
+func GenerateAnalyticsKey() (publicKey []byte, secretKey []byte) {
+	kgen := bfv.NewKeyGenerator(analyticsParams())
+	sk := kgen.GenSecretKey()
+	pk := kgen.GenPublicKey(sk)
+	return utils.Assure(pk.MarshalBinary()), utils.Assure(sk.MarshalBinary())
+}
+
+// encrypt a value for an aggregate, the ciphertext is counted once when it is added to others
+func EncryptAggregateValue(publicKey []byte, value uint64) ([]byte, error) {
+	if value > MaxAggregateValue {
+		return nil, fmt.Errorf("%d is too large for an encrypted aggregate, values must be at most %d", value, MaxAggregateValue)
+	}
+	params := analyticsParams()
+	pk := rlwe.NewPublicKey(params.Parameters)
+	if err := pk.UnmarshalBinary(publicKey); err != nil {
+		return nil, fmt.Errorf("analytics public key: %w", err)
+	}
+
+	plaintext := bfv.NewPlaintext(params)
+	bfv.NewEncoder(params).EncodeUint([]uint64{value, 1}, plaintext)
+	return bfv.NewEncryptor(params, pk).EncryptNew(plaintext).MarshalBinary()
+}
+
+// the homomorphic sum of encrypted values, it is encrypted under the same key
+// more than MaxAggregateCount values could wrap around the plaintext modulus and are rejected
+func SumAggregateValues(ciphertexts [][]byte) ([]byte, error) {
+	if len(ciphertexts) == 0 {
+		return nil, errors.New("no values to aggregate")
+	}
+	if len(ciphertexts) > MaxAggregateCount {
+		return nil, fmt.Errorf("%d values could add up to more than the plaintext modulus, an aggregate can have at most %d", len(ciphertexts), MaxAggregateCount)
+	}
+	params := analyticsParams()
+	evaluator := bfv.NewEvaluator(params, rlwe.EvaluationKey{})
+
+	sum := new(bfv.Ciphertext)
+	if err := sum.UnmarshalBinary(ciphertexts[0]); err != nil {
+		return nil, err
+	}
+	for _, data := range ciphertexts[1:] {
+		ct := new(bfv.Ciphertext)
+		if err := ct.UnmarshalBinary(data); err != nil {
+			return nil, err
+		}
+		evaluator.Add(sum, ct, sum)
+	}
+	return sum.MarshalBinary()
+}
+
+// the sum and the number of the values in an aggregate
+func DecryptAggregate(secretKey []byte, aggregate []byte) (sum uint64, count uint64, err error) {
+	params := analyticsParams()
+	sk := rlwe.NewSecretKey(params.Parameters)
+	if err := sk.UnmarshalBinary(secretKey); err != nil {
+		return 0, 0, fmt.Errorf("analytics secret key: %w", err)
+	}
+	ct := new(bfv.Ciphertext)
+	if err := ct.UnmarshalBinary(aggregate); err != nil {
+		return 0, 0, err
+	}
+
+	slots := make([]uint64, params.N())
+	bfv.NewEncoder(params).DecodeUint(bfv.NewDecryptor(params, sk).DecryptNew(ct), slots)
+	return slots[0], slots[1], nil
+}
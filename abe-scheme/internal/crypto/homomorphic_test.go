@@ -0,0 +1,48 @@
+package crypto
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestAggregates(t *testing.T) {
+	publicKey, secretKey := GenerateAnalyticsKey()
+	_, otherSecretKey := GenerateAnalyticsKey()
+
+	ciphertexts := [][]byte{}
+	var want uint64
+	for range 50 {
+		value := uint64(rand.Intn(200))
+		want += value
+		ct, err := EncryptAggregateValue(publicKey, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ciphertexts = append(ciphertexts, ct)
+	}
+
+	aggregate, err := SumAggregateValues(ciphertexts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum, count, err := DecryptAggregate(secretKey, aggregate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum != want || count != 50 {
+		t.Errorf("got sum %d and count %d, want %d and 50", sum, count, want)
+	}
+
+	if sum, count, _ := DecryptAggregate(otherSecretKey, aggregate); sum == want && count == 50 {
+		t.Error("another secret key should not decrypt the aggregate")
+	}
+	if _, err := EncryptAggregateValue(publicKey, MaxAggregateValue+1); err == nil {
+		t.Error("values above the maximum should be rejected")
+	}
+	if _, err := SumAggregateValues(make([][]byte, MaxAggregateCount+1)); err == nil {
+		t.Error("aggregates that could wrap around the plaintext modulus should be rejected")
+	}
+	if _, err := SumAggregateValues(nil); err == nil {
+		t.Error("an empty aggregate has no ciphertext")
+	}
+}
@@ -0,0 +1,66 @@
+/*
+
+Searchable symmetric encryption of keywords
+Every table can have an index key, which is ABE encrypted so only holders of the right attributes can derive trapdoors from it.
+An entry is indexed by the keyed hashes of a random nonce under the trapdoors of its keywords, so the database can test a
+trapdoor against every entry without learning the keyword, and equal keywords of different entries have unrelated tags.
+The database learns which entries match a searched trapdoor and when the same trapdoor is searched again
+
+*/
+
+package crypto
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"slices"
+
+	"github.com/pzkt/abe-scripts/abe-scheme/internal/utils"
+)
+
+const IndexKeySize = 32
+
+func NewIndexKey() []byte {
+	key := make([]byte, IndexKeySize)
+	utils.Assure(rand.Read(key))
+	return key
+}
+
+// the trapdoor of a keyword lets the database find entries with it, only the index key can derive it
+func Trapdoor(indexKey []byte, keyword string) []byte {
+	mac := hmac.New(sha256.New, indexKey)
+	mac.Write([]byte(keyword))
+	return mac.Sum(nil)
+}
+
+func keywordTag(trapdoor []byte, nonce []byte) []byte {
+	mac := hmac.New(sha256.New, trapdoor)
+	mac.Write(nonce)
+	return mac.Sum(nil)
+}
+
+// the tags of the keywords of an entry, sorted so their order does not reveal the order of the keywords
+func IndexKeywords(indexKey []byte, keywords []string) utils.KeywordTags {
+	nonce := make([]byte, 16)
+	utils.Assure(rand.Read(nonce))
+
+	tags := [][]byte{}
+	for _, keyword := range slices.Compact(slices.Sorted(slices.Values(keywords))) {
+		tags = append(tags, keywordTag(Trapdoor(indexKey, keyword), nonce))
+	}
+	slices.SortFunc(tags, bytes.Compare)
+	return utils.KeywordTags{Nonce: nonce, Tags: tags}
+}
+
+// true if the entry has the keywords of all trapdoors
+func MatchesTrapdoors(keywords utils.KeywordTags, trapdoors [][]byte) bool {
+	for _, trapdoor := range trapdoors {
+		tag := keywordTag(trapdoor, keywords.Nonce)
+		if !slices.ContainsFunc(keywords.Tags, func(t []byte) bool { return hmac.Equal(t, tag) }) {
+			return false
+		}
+	}
+	return true
+}
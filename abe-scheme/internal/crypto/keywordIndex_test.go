@@ -0,0 +1,40 @@
+package crypto
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestKeywordIndex(t *testing.T) {
+	key := NewIndexKey()
+	other := NewIndexKey()
+
+	first := IndexKeywords(key, []string{"cancer_type=Melanoma", "cancer_stage=II", "cancer_type=Melanoma"})
+	second := IndexKeywords(key, []string{"cancer_type=Melanoma"})
+	if len(first.Tags) != 2 {
+		t.Errorf("duplicate keywords should be indexed once, got %d tags", len(first.Tags))
+	}
+	for _, tag := range first.Tags {
+		if bytes.Equal(tag, second.Tags[0]) {
+			t.Error("equal keywords of different entries should have different tags")
+		}
+	}
+
+	melanoma := Trapdoor(key, "cancer_type=Melanoma")
+	tests := []struct {
+		trapdoors [][]byte
+		first     bool
+		second    bool
+	}{
+		{[][]byte{melanoma}, true, true},
+		{[][]byte{melanoma, Trapdoor(key, "cancer_stage=II")}, true, false},
+		{[][]byte{Trapdoor(key, "cancer_type=Sarcoma")}, false, false},
+		{[][]byte{Trapdoor(other, "cancer_type=Melanoma")}, false, false},
+		{[][]byte{}, true, true},
+	}
+	for i, tc := range tests {
+		if MatchesTrapdoors(first, tc.trapdoors) != tc.first || MatchesTrapdoors(second, tc.trapdoors) != tc.second {
+			t.Errorf("case %d: matched %v and %v", i, MatchesTrapdoors(first, tc.trapdoors), MatchesTrapdoors(second, tc.trapdoors))
+		}
+	}
+}
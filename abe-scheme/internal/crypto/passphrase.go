@@ -0,0 +1,74 @@
+/*
+
+Functions for encrypting data with a passphrase
+The key is derived with Argon2id and the data is encrypted with AES-256-GCM
+
+*/
+
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Argon2id parameters as recommended by RFC 9106 for memory constrained environments
+const (
+	saltSize      = 16
+	argonTime     = 3
+	argonMemory   = 64 * 1024
+	argonThreads  = 4
+	argonKeyBytes = 32
+)
+
+func passphraseCipher(passphrase []byte, salt []byte) (cipher.AEAD, error) {
+	key := argon2.IDKey(passphrase, salt, argonTime, argonMemory, argonThreads, argonKeyBytes)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// salt, nonce and ciphertext are returned as a single slice
+func SealWithPassphrase(passphrase []byte, plaintext []byte) ([]byte, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	aead, err := passphraseCipher(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	out := append(salt, nonce...)
+	return aead.Seal(out, nonce, plaintext, nil), nil
+}
+
+func OpenWithPassphrase(passphrase []byte, sealed []byte) ([]byte, error) {
+	if len(sealed) < saltSize {
+		return nil, errors.New("sealed data is too short")
+	}
+	aead, err := passphraseCipher(passphrase, sealed[:saltSize])
+	if err != nil {
+		return nil, err
+	}
+	sealed = sealed[saltSize:]
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("sealed data is too short")
+	}
+
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, errors.New("wrong passphrase or corrupted data")
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,82 @@
+/*
+
+Functions for searchable fields
+Clients that share a search key can find entries by the value of a field without the database learning the value:
+a blind index is a keyed hash of the value, a deterministic encryption can also be decrypted again.
+Both reveal which entries have equal values, so they are only meant for fields where that is acceptable
+
+*/
+
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+)
+
+type SearchKey struct {
+	index      []byte
+	encryption []byte
+	iv         []byte
+}
+
+// independent keys for every use are derived from the shared secret
+func NewSearchKey(secret []byte) SearchKey {
+	derive := func(label string) []byte {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(label))
+		return mac.Sum(nil)
+	}
+	return SearchKey{
+		index:      derive("blind index"),
+		encryption: derive("deterministic encryption"),
+		iv:         derive("deterministic iv"),
+	}
+}
+
+// the field is part of every hash, so equal values of different fields can not be linked
+func keyedHash(key []byte, field string, value string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(field))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return mac.Sum(nil)
+}
+
+func (k SearchKey) BlindIndex(field string, value string) string {
+	return hex.EncodeToString(keyedHash(k.index, field, value))
+}
+
+// the nonce is derived from the value (synthetic IV), so equal values always give the same ciphertext
+func (k SearchKey) EncryptDeterministic(field string, value string) string {
+	aead := k.aead()
+	nonce := keyedHash(k.iv, field, value)[:aead.NonceSize()]
+	return base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(value), []byte(field)))
+}
+
+func (k SearchKey) DecryptDeterministic(field string, token string) (string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return "", err
+	}
+	aead := k.aead()
+	if len(data) < aead.NonceSize() {
+		return "", errors.New("deterministic ciphertext is too short")
+	}
+	value, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], []byte(field))
+	if err != nil {
+		return "", errors.New("wrong search key or corrupted ciphertext")
+	}
+	return string(value), nil
+}
+
+func (k SearchKey) aead() cipher.AEAD {
+	block, _ := aes.NewCipher(k.encryption)
+	aead, _ := cipher.NewGCM(block)
+	return aead
+}
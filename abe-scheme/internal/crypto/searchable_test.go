@@ -0,0 +1,38 @@
+package crypto
+
+import "testing"
+
+func TestSearchableFields(t *testing.T) {
+	key := NewSearchKey([]byte("shared search secret"))
+	other := NewSearchKey([]byte("another secret"))
+
+	if key.BlindIndex("patient_id", "345") != key.BlindIndex("patient_id", "345") {
+		t.Error("blind indexes of equal values should be equal")
+	}
+	for _, different := range []string{
+		key.BlindIndex("patient_id", "346"),
+		key.BlindIndex("record_type", "345"),
+		other.BlindIndex("patient_id", "345"),
+	} {
+		if different == key.BlindIndex("patient_id", "345") {
+			t.Error("blind index should depend on the value, the field and the key")
+		}
+	}
+
+	token := key.EncryptDeterministic("patient_id", "345")
+	if token != key.EncryptDeterministic("patient_id", "345") {
+		t.Error("deterministic encryption of equal values should be equal")
+	}
+	if token == key.EncryptDeterministic("record_type", "345") {
+		t.Error("deterministic encryption should depend on the field")
+	}
+	if value, err := key.DecryptDeterministic("patient_id", token); err != nil || value != "345" {
+		t.Errorf("got %q, %v", value, err)
+	}
+	if _, err := other.DecryptDeterministic("patient_id", token); err == nil {
+		t.Error("another key should not decrypt the value")
+	}
+	if _, err := key.DecryptDeterministic("record_type", token); err == nil {
+		t.Error("the ciphertext of one field should not decrypt as another field")
+	}
+}
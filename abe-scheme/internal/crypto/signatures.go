@@ -30,7 +30,11 @@ func Sign(privateKey *ecdsa.PrivateKey, data []byte) []byte {
 		log.Fatal(err)
 	}
 
-	sig := append(r.Bytes(), s.Bytes()...)
+	//both halves are padded to the size of the curve, Verify splits the signature in the middle
+	size := (privateKey.Curve.Params().BitSize + 7) / 8
+	sig := make([]byte, 2*size)
+	r.FillBytes(sig[:size])
+	s.FillBytes(sig[size:])
 	return sig
 }
 
@@ -42,3 +46,15 @@ func Verify(publicKey *ecdsa.PublicKey, data, signature []byte) bool {
 
 	return ecdsa.Verify(publicKey, hashed[:], r, s)
 }
+
+// check that an update of an entry is signed with the write key stored with the entry
+// public keys are stored without their curve, see putEntry of the client
+func VerifyUpdate(storedPublicKey []byte, update utils.Record) bool {
+	var publicKey ecdsa.PublicKey
+	utils.FromBytes(storedPublicKey, &publicKey)
+	if publicKey.X == nil || publicKey.Y == nil || len(update.Signature) == 0 {
+		return false
+	}
+	publicKey.Curve = elliptic.P256()
+	return Verify(&publicKey, update.Checksum(), update.Signature)
+}
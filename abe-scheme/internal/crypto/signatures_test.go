@@ -0,0 +1,57 @@
+package crypto
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/pzkt/abe-scripts/abe-scheme/internal/utils"
+)
+
+// r or s with leading zero bytes must not shift the split between them
+func TestSignatures(t *testing.T) {
+	key := GenerateSignatureKey()
+	other := GenerateSignatureKey()
+	for i := 0; i < 2000; i++ {
+		data := []byte(fmt.Sprint(i))
+		signature := Sign(key, data)
+		if !Verify(&key.PublicKey, data, signature) {
+			t.Fatalf("signature %d does not verify", i)
+		}
+		if Verify(&other.PublicKey, data, signature) {
+			t.Fatalf("signature %d verifies with another key", i)
+		}
+	}
+}
+
+// updates are only accepted with a signature of the write key stored with the entry, over the whole record
+func TestVerifyUpdate(t *testing.T) {
+	key := GenerateSignatureKey()
+	publicKey := key.PublicKey
+	publicKey.Curve = nil
+	stored := utils.ToBytes(publicKey)
+
+	update := utils.Record{Table: "table_one", ID: uuid.New(), Data: []byte("data"), PublicWriteKey: stored}
+	update.Signature = Sign(key, update.Checksum())
+	if !VerifyUpdate(stored, update) {
+		t.Fatal("update signed with the write key was rejected")
+	}
+
+	tampered := update
+	tampered.Data = []byte("other data")
+	if VerifyUpdate(stored, tampered) {
+		t.Error("tampered update was accepted")
+	}
+
+	forged := update
+	forged.Signature = Sign(GenerateSignatureKey(), forged.Checksum())
+	if VerifyUpdate(stored, forged) {
+		t.Error("update signed with another key was accepted")
+	}
+
+	unsigned := update
+	unsigned.Signature = nil
+	if VerifyUpdate(stored, unsigned) {
+		t.Error("unsigned update was accepted")
+	}
+}
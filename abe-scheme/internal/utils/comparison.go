@@ -0,0 +1,172 @@
+/*
+
+numeric comparisons as attribute policies
+A key holds one attribute per bit of a value, e.g. age = 5 with 4 bits gives age#0***, age#*1**, age#**0* and age#***1
+A comparison with a constant is then a chain of these bit attributes, see Comparison
+
+*/
+
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Ops int
+
+const (
+	Less Ops = iota
+	Greater
+	LessOrEqual
+	GreaterOrEqual
+)
+
+var opSymbols = map[Ops]string{
+	Less:           "<",
+	Greater:        ">",
+	LessOrEqual:    "<=",
+	GreaterOrEqual: ">=",
+}
+
+func (op Ops) String() string {
+	return opSymbols[op]
+}
+
+// the operator with swapped operands, 3 < x is x > 3
+func (op Ops) Mirror() Ops {
+	return map[Ops]Ops{Less: Greater, Greater: Less, LessOrEqual: GreaterOrEqual, GreaterOrEqual: LessOrEqual}[op]
+}
+
+// the operator that holds exactly when op does not, NOT x < 3 is x >= 3
+func (op Ops) Negate() Ops {
+	return map[Ops]Ops{Less: GreaterOrEqual, GreaterOrEqual: Less, Greater: LessOrEqual, LessOrEqual: Greater}[op]
+}
+
+// number of bits used to encode numeric attributes, values range from 0 to 65535
+// dates are encoded as days since 1970-01-01, which covers dates until 2149
+const ComparisonValueSize = 16
+
+const dateLayout = "2006-01-02"
+
+// a numeric value as written in a policy or key request, either a number or a date (YYYY-MM-DD)
+func ParseNumericValue(s string) (int, error) {
+	if strings.Count(s, "-") == 2 {
+		date, err := time.Parse(dateLayout, s)
+		if err != nil {
+			return 0, fmt.Errorf("invalid date %q: expected YYYY-MM-DD", s)
+		}
+		days := int(date.Unix() / (24 * 60 * 60))
+		if days < 0 || days >= 1<<ComparisonValueSize {
+			return 0, fmt.Errorf("date %s is out of range, dates from 1970-01-01 to %s are supported", s, FormatDate(1<<ComparisonValueSize-1))
+		}
+		return days, nil
+	}
+
+	value, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+	if value < 0 || value >= 1<<ComparisonValueSize {
+		return 0, fmt.Errorf("value %s does not fit into %d bits", s, ComparisonValueSize)
+	}
+	return value, nil
+}
+
+// the date of a value from ParseNumericValue
+func FormatDate(days int) string {
+	return time.Unix(int64(days)*24*60*60, 0).UTC().Format(dateLayout)
+}
+
+// the attribute for a single bit: the bit at its position, all other positions are *
+func bitAttribute(name string, bit string, position int, valueSize int) string {
+	return name + "#" + strings.Repeat("*", valueSize-position-1) + bit + strings.Repeat("*", position)
+}
+
+// the key attributes for a numeric attribute, one per bit from the most significant bit down
+func BitAttributes(name string, value uint, valueSize int) []string {
+	out := []string{}
+	for i := valueSize - 1; i >= 0; i-- {
+		bit := (value >> i) & 1
+		out = append(out, bitAttribute(name, strconv.Itoa(int(bit)), i, valueSize))
+	}
+	return out
+}
+
+// the value encoded in the bit attributes of a key, false if the key does not hold all bits of the attribute
+func BitValue(name string, attributes []string, valueSize int) (int, bool) {
+	value, found := 0, 0
+	for _, a := range attributes {
+		pattern, ok := strings.CutPrefix(a, name+"#")
+		if !ok || len(pattern) != valueSize {
+			continue
+		}
+		i := strings.IndexAny(pattern, "01")
+		if i < 0 || strings.Count(pattern, "*") != valueSize-1 {
+			continue
+		}
+		if pattern[i] == '1' {
+			value |= 1 << (valueSize - 1 - i)
+		}
+		found++
+	}
+	return value, found == valueSize
+}
+
+// the policy that is satisfied by keys whose value of the attribute compares to value with op
+// gates are not bracketed: every gate binds everything to its right, e.g. a AND b OR c is a AND (b OR c)
+// comparisons that hold for every value are a tautology over the lowest bit, comparisons that hold for none are an error
+func Comparison(name string, value int, valueSize int, op Ops) (string, error) {
+	//only strict comparisons are encoded
+	switch op {
+	case GreaterOrEqual:
+		return comparison(name, value-1, valueSize, Greater, fmt.Sprintf("%s >= %d", name, value))
+	case LessOrEqual:
+		return comparison(name, value+1, valueSize, Less, fmt.Sprintf("%s <= %d", name, value))
+	}
+	return comparison(name, value, valueSize, op, fmt.Sprintf("%s %s %d", name, op, value))
+}
+
+func comparison(name string, value int, valueSize int, op Ops, written string) (string, error) {
+	max := 1<<valueSize - 1
+	switch {
+	case op == Less && value <= 0, op == Greater && value >= max:
+		return "", fmt.Errorf("%s can not be satisfied by any value from 0 to %d", written, max)
+	case op == Less && value > max, op == Greater && value < 0:
+		return bitAttribute(name, "0", 0, valueSize) + " OR " + bitAttribute(name, "1", 0, valueSize), nil
+	}
+
+	//a value below the constant has a 0 where the constant has its first differing 1, a value above has a 1 where the constant has a 0
+	//going from the most significant bit down, bits equal to the constant continue the chain with AND, the others end it with OR
+	wanted, continues, ends := "0", " AND ", " OR "
+	if op == Greater {
+		wanted, continues, ends = "1", " OR ", " AND "
+	}
+
+	out := ""
+	for i := valueSize - 1; i > 0; i-- {
+		bit := (value >> i) & 1
+		mask := (1 << i) - 1
+		switch {
+		case op == Greater && bit == 0:
+			//only ones below: a 1 here is the only way to be greater
+			if ^(mask&value)&mask == 0 {
+				return out + bitAttribute(name, wanted, i, valueSize), nil
+			}
+			out += bitAttribute(name, wanted, i, valueSize) + continues
+		case op == Greater && bit == 1:
+			out += bitAttribute(name, wanted, i, valueSize) + ends
+		case op == Less && bit == 1:
+			//only zeros below: a 0 here is the only way to be less
+			if mask&value == 0 {
+				return out + bitAttribute(name, wanted, i, valueSize), nil
+			}
+			out += bitAttribute(name, wanted, i, valueSize) + ends
+		case op == Less && bit == 0:
+			out += bitAttribute(name, wanted, i, valueSize) + continues
+		}
+	}
+	return out + bitAttribute(name, wanted, 0, valueSize), nil
+}
@@ -0,0 +1,126 @@
+package utils
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// evaluate a chain from Comparison, every gate binds everything to its right
+func evalChain(fields []string, attributes []string) bool {
+	held := slices.Contains(attributes, fields[0])
+	if len(fields) == 1 {
+		return held
+	}
+	if fields[1] == "AND" {
+		return held && evalChain(fields[2:], attributes)
+	}
+	return held || evalChain(fields[2:], attributes)
+}
+
+func TestComparisonExhaustive(t *testing.T) {
+	holds := map[Ops]func(x, v int) bool{
+		Less:           func(x, v int) bool { return x < v },
+		Greater:        func(x, v int) bool { return x > v },
+		LessOrEqual:    func(x, v int) bool { return x <= v },
+		GreaterOrEqual: func(x, v int) bool { return x >= v },
+	}
+
+	for size := 1; size <= 6; size++ {
+		max := 1<<size - 1
+		for op, holds := range holds {
+			//constants just outside the range of values are included to cover the boundaries
+			for v := -2; v <= max+2; v++ {
+				satisfiable := false
+				for x := 0; x <= max; x++ {
+					satisfiable = satisfiable || holds(x, v)
+				}
+
+				policy, err := Comparison("x", v, size, op)
+				if (err == nil) != satisfiable {
+					t.Errorf("x %s %d with %d bits: satisfiable %v, error %v", op, v, size, satisfiable, err)
+					continue
+				}
+				if err != nil {
+					continue
+				}
+
+				fields := strings.Fields(policy)
+				for x := 0; x <= max; x++ {
+					if got := evalChain(fields, BitAttributes("x", uint(x), size)); got != holds(x, v) {
+						t.Errorf("x %s %d with %d bits and x = %d: got %v from %s", op, v, size, x, got, policy)
+					}
+				}
+			}
+		}
+	}
+}
+
+// a comparison uses every bit attribute at most once, so it can be encrypted under FAME
+func TestComparisonUsesAttributesOnce(t *testing.T) {
+	for _, op := range []Ops{Less, Greater, LessOrEqual, GreaterOrEqual} {
+		for v := 1; v < 1<<8-1; v++ {
+			policy, err := Comparison("x", v, 8, op)
+			if err != nil {
+				t.Fatal(err)
+			}
+			seen := map[string]bool{}
+			for i, f := range strings.Fields(policy) {
+				if i%2 == 0 && seen[f] {
+					t.Errorf("x %s %d repeats %s: %s", op, v, f, policy)
+				}
+				seen[f] = true
+			}
+		}
+	}
+}
+
+func TestBitAttributes(t *testing.T) {
+	want := []string{"age#0***", "age#*1**", "age#**0*", "age#***1"}
+	if got := BitAttributes("age", 5, 4); !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestBitValue(t *testing.T) {
+	for _, v := range []uint{0, 1, 5, 200, 255} {
+		attributes := append([]string{"commerce:Admin", "y#1*******"}, BitAttributes("x", v, 8)...)
+		if got, ok := BitValue("x", attributes, 8); !ok || got != int(v) {
+			t.Errorf("got %d, %v, want %d", got, ok, v)
+		}
+	}
+	if _, ok := BitValue("x", BitAttributes("x", 5, 8)[1:], 8); ok {
+		t.Error("a value with missing bits should not be found")
+	}
+	if _, ok := BitValue("x", BitAttributes("x", 5, 4), 8); ok {
+		t.Error("a value with a different size should not be found")
+	}
+}
+
+func TestParseNumericValue(t *testing.T) {
+	for _, tc := range []struct {
+		value string
+		want  int
+	}{
+		{"0", 0},
+		{"65535", 65535},
+		{"1970-01-01", 0},
+		{"1970-02-01", 31},
+		{"2024-02-29", 19782},
+		{"2149-06-06", 65535},
+	} {
+		if got, err := ParseNumericValue(tc.value); err != nil || got != tc.want {
+			t.Errorf("%s: got %d, %v, want %d", tc.value, got, err, tc.want)
+		}
+	}
+
+	for _, value := range []string{"65536", "-1", "x", "1969-12-31", "2149-06-07", "2023-02-29", "2024-1-1"} {
+		if got, err := ParseNumericValue(value); err == nil {
+			t.Errorf("%s should be rejected, got %d", value, got)
+		}
+	}
+
+	if date := FormatDate(19782); date != "2024-02-29" {
+		t.Errorf("got %s", date)
+	}
+}
@@ -7,23 +7,200 @@ config struct isolated to avoid circular dependencies
 package policyConfig
 
 import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
 	"github.com/pzkt/abe-scripts/abe-scheme/internal/crypto"
 	"github.com/pzkt/abe-scripts/abe-scheme/internal/utils"
 )
 
+// how purposes are inherited along the purpose trees
+type InheritanceMode int
+
+const (
+	// a key for a purpose also grants every purpose below it, e.g. a General-Purpose key can read data intended for Admin
+	// a prohibited purpose excludes itself and the purposes below it
+	Upward InheritanceMode = iota
+	// data intended for a purpose may also be used for every purpose below it, e.g. a Direct key can read data intended for Marketing
+	// a prohibited purpose excludes itself, the purposes below it and the purposes above it, since those would cover it
+	Downward
+)
+
+func (m InheritanceMode) String() string {
+	switch m {
+	case Upward:
+		return "upward"
+	case Downward:
+		return "downward"
+	default:
+		return fmt.Sprintf("InheritanceMode(%d)", int(m))
+	}
+}
+
+func ParseInheritanceMode(s string) (InheritanceMode, error) {
+	for _, m := range []InheritanceMode{Upward, Downward} {
+		if m.String() == s {
+			return m, nil
+		}
+	}
+	return Upward, fmt.Errorf("unknown inheritance mode %q, expected upward or downward", s)
+}
+
 type Config struct {
+	Version      int
 	PurposeTrees []*utils.Tree
+	Inheritance  InheritanceMode
 	Scheme       crypto.ABEscheme
+	TimeEpoch    time.Duration // length of the epochs time bound keys are issued for, 0 if the authority does not issue them
+
+	//not serialized, has to be built again with BuildIndex after loading or changing the purpose trees
+	index *purposeIndex
+}
+
+// every published version is also stored under its own id, so ciphertexts can refer to the version they were encrypted under
+// the latest version is always stored under the id of the authority
+func VersionID(authority uuid.UUID, version int) uuid.UUID {
+	return uuid.NewSHA1(authority, []byte(fmt.Sprintf("policy-config-%d", version)))
 }
 
+// the key attributes that may access data intended for the purpose
 func (p Config) ResolvePurpose(purpose string) []string {
+	return p.ResolveIntendedPurposes([]string{purpose}, nil)
+}
+
+// the key attributes that may access data with the allowed and prohibited intended purposes
+// attributes of purposes are qualified by their tree, e.g. health:Research
+// purposes that are not part of any tree only stand for themselves
+func (p Config) ResolveIntendedPurposes(allowed []string, prohibited []string) []string {
+	excluded := map[string]bool{}
+	added := map[string]bool{}
 	out := []string{}
+
+	for _, purpose := range prohibited {
+		for _, value := range p.Below(purpose) {
+			excluded[value] = true
+		}
+		if p.Inheritance == Downward {
+			for _, value := range p.Above(purpose) {
+				excluded[value] = true
+			}
+		}
+	}
+
+	for _, purpose := range allowed {
+		implied := p.Above(purpose)
+		if p.Inheritance == Downward {
+			implied = p.Below(purpose)
+		}
+		for _, value := range implied {
+			if !excluded[value] && !added[value] {
+				added[value] = true
+				out = append(out, value)
+			}
+		}
+	}
+	return out
+}
+
+// the nodes a purpose refers to. A qualified purpose (tree:purpose or tree:root/.../purpose) refers to at most one node,
+// a bare purpose to its node in every tree that contains it
+func (p Config) Lookup(purpose string) ([]*utils.Tree, error) {
+	if p.index != nil {
+		return p.index.lookup(purpose)
+	}
+
+	name, path, qualified := strings.Cut(purpose, ":")
+	if !qualified {
+		out := []*utils.Tree{}
+		for _, pt := range p.PurposeTrees {
+			if node, found := pt.FindValue(purpose); found {
+				out = append(out, node)
+			}
+		}
+		return out, nil
+	}
+
 	for _, pt := range p.PurposeTrees {
-		node, found := pt.FindValue(purpose)
-		if !found {
+		if pt.TreeName() != name {
 			continue
 		}
-		out = append(out, node.GetRootPath()...)
+		if node := findPath(pt, path); node != nil {
+			return []*utils.Tree{node}, nil
+		}
+		return nil, fmt.Errorf("purpose %q not found in tree %q", path, name)
+	}
+	return nil, fmt.Errorf("unknown purpose tree %q", name)
+}
+
+// a single value anywhere in the tree, or a path from the root
+func findPath(t *utils.Tree, path string) *utils.Tree {
+	values := strings.Split(path, "/")
+	if len(values) == 1 {
+		node, _ := t.FindValue(path)
+		return node
+	}
+	if values[0] != t.Value {
+		return nil
+	}
+	for _, value := range values[1:] {
+		i := slices.IndexFunc(t.Children, func(c *utils.Tree) bool { return c.Value == value })
+		if i < 0 {
+			return nil
+		}
+		t = t.Children[i]
+	}
+	return t
+}
+
+// the names of the trees a bare purpose appears in if there is more than one
+func (p Config) Ambiguous(purpose string) []string {
+	nodes, _ := p.Lookup(purpose)
+	if len(nodes) < 2 {
+		return nil
+	}
+	names := []string{}
+	for _, n := range nodes {
+		names = append(names, n.TreeName())
+	}
+	return names
+}
+
+// key attributes for purposes are always qualified, bare purposes are qualified for every tree that contains them
+// attributes that are not purposes are kept as they are
+func (p Config) QualifyAttributes(attributes []string) []string {
+	out := []string{}
+	for _, a := range attributes {
+		nodes, err := p.Lookup(a)
+		if err != nil || len(nodes) == 0 {
+			out = append(out, a)
+			continue
+		}
+		for _, n := range nodes {
+			if !slices.Contains(out, n.QualifiedValue()) {
+				out = append(out, n.QualifiedValue())
+			}
+		}
+	}
+	return out
+}
+
+// the purpose and its ancestors in every tree that contains it, from the root down
+func (p Config) Above(purpose string) []string {
+	nodes, _ := p.Lookup(purpose)
+	out := []string{}
+	for _, node := range nodes {
+		if p.index != nil {
+			out = append(out, p.index.above[node]...)
+			continue
+		}
+		path := []string{}
+		for n := node; n != nil; n = n.Parent {
+			path = append([]string{n.QualifiedValue()}, path...)
+		}
+		out = append(out, path...)
 	}
 
 	if len(out) == 0 {
@@ -32,3 +209,30 @@ func (p Config) ResolvePurpose(purpose string) []string {
 		return out
 	}
 }
+
+// the purpose and its descendants in every tree that contains it
+func (p Config) Below(purpose string) []string {
+	nodes, _ := p.Lookup(purpose)
+	out := []string{}
+	for _, node := range nodes {
+		if p.index != nil {
+			out = append(out, p.index.below(node)...)
+			continue
+		}
+		out = append(out, descendants(node)...)
+	}
+
+	if len(out) == 0 {
+		return []string{purpose}
+	} else {
+		return out
+	}
+}
+
+func descendants(t *utils.Tree) []string {
+	out := []string{t.QualifiedValue()}
+	for _, c := range t.Children {
+		out = append(out, descendants(c)...)
+	}
+	return out
+}
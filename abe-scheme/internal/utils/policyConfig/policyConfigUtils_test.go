@@ -0,0 +1,152 @@
+package policyConfig
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/pzkt/abe-scripts/abe-scheme/internal/utils"
+)
+
+func TestResolveIntendedPurposes(t *testing.T) {
+	for _, tc := range []struct {
+		mode       InheritanceMode
+		allowed    []string
+		prohibited []string
+		want       []string
+	}{
+		{Upward, []string{"Admin"}, nil, []string{"commerce:Admin", "commerce:General-Purpose"}},
+		{Upward, []string{"Radiology", "Email"}, nil, []string{
+			"commerce:Email", "commerce:General-Purpose", "commerce:Marketing", "commerce:Third-Party",
+			"health:General-Purpose", "health:Health-Record", "health:Radiology",
+		}},
+		{Upward, []string{"Auditing"}, nil, []string{"Auditing"}},
+		// no key above Marketing is for a more specific purpose than Third-Party
+		{Upward, []string{"Marketing"}, []string{"Third-Party"}, []string{"commerce:General-Purpose", "commerce:Marketing"}},
+		{Upward, []string{"Email"}, []string{"Marketing"}, []string{"commerce:General-Purpose"}},
+		{Upward, []string{"Radiology"}, []string{"General-Purpose"}, []string{}},
+
+		{Downward, []string{"Admin"}, nil, []string{"commerce:Admin", "commerce:Analysis", "commerce:Profiling"}},
+		{Downward, []string{"Auditing"}, nil, []string{"Auditing"}},
+		// Marketing and General-Purpose keys would also cover Third-Party
+		{Downward, []string{"Marketing"}, []string{"Third-Party"}, []string{"commerce:Direct"}},
+		{Downward, []string{"Health-Record"}, []string{"Radiology"}, []string{"health:Need-To-Know", "health:Optometry"}},
+		{Downward, []string{"General-Purpose"}, []string{"Research"}, []string{
+			"commerce:Admin", "commerce:Analysis", "commerce:Direct", "commerce:Email", "commerce:General-Purpose",
+			"commerce:Marketing", "commerce:Phone", "commerce:Profiling", "commerce:Purchase", "commerce:Shipping",
+			"commerce:Third-Party", "health:Health-Record", "health:Need-To-Know", "health:Optometry", "health:Radiology",
+		}},
+		// a qualified purpose only refers to its own tree
+		{Downward, []string{"health:General-Purpose"}, []string{"Research"}, []string{
+			"health:Health-Record", "health:Need-To-Know", "health:Optometry", "health:Radiology",
+		}},
+		{Upward, []string{"commerce:General-Purpose/Marketing/Third-Party"}, nil, []string{
+			"commerce:General-Purpose", "commerce:Marketing", "commerce:Third-Party",
+		}},
+	} {
+		c := Config{PurposeTrees: utils.ExamplePurposeTrees(), Inheritance: tc.mode}
+		got := c.ResolveIntendedPurposes(tc.allowed, tc.prohibited)
+		slices.Sort(got)
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("%v %v except %v: got %v, want %v", tc.mode, tc.allowed, tc.prohibited, got, tc.want)
+		}
+	}
+}
+
+func TestResolvePurposeDefaultsToUpward(t *testing.T) {
+	c := Config{PurposeTrees: utils.ExamplePurposeTrees()}
+	if got := c.ResolvePurpose("Profiling"); !slices.Equal(got, []string{"commerce:General-Purpose", "commerce:Admin", "commerce:Profiling"}) {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	c := Config{PurposeTrees: utils.ExamplePurposeTrees()}
+
+	if names := c.Ambiguous("General-Purpose"); !slices.Equal(names, []string{"commerce", "health"}) {
+		t.Errorf("General-Purpose should be ambiguous, got %v", names)
+	}
+	if names := c.Ambiguous("Research"); names != nil {
+		t.Errorf("Research should not be ambiguous, got %v", names)
+	}
+
+	for _, purpose := range []string{"finance:Admin", "health:Admin", "commerce:General-Purpose/Phone"} {
+		if _, err := c.Lookup(purpose); err == nil {
+			t.Errorf("%s should not be found", purpose)
+		}
+	}
+
+	nodes, err := c.Lookup("health:Research")
+	if err != nil || len(nodes) != 1 || nodes[0].QualifiedValue() != "health:Research" {
+		t.Errorf("unexpected lookup result %v, %v", nodes, err)
+	}
+
+	got := c.QualifyAttributes([]string{"General-Purpose", "Radiology", "health:Research", "age#1*"})
+	want := []string{"commerce:General-Purpose", "health:General-Purpose", "health:Radiology", "health:Research", "age#1*"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestIndexMatchesSearch(t *testing.T) {
+	for _, trees := range [][]*utils.Tree{
+		utils.ExamplePurposeTrees(),
+		{utils.SyntheticPurposeTree(500, 4)},
+	} {
+		search := Config{PurposeTrees: trees}
+		indexed := Config{PurposeTrees: trees}
+		indexed.BuildIndex()
+
+		purposes := []string{"Unknown", "synthetic:Unknown", "unknown:Purpose-1", "commerce:General-Purpose/Admin/Profiling", "commerce:Admin/Profiling"}
+		for _, tree := range trees {
+			for _, value := range descendants(tree) {
+				_, label, _ := strings.Cut(value, ":")
+				purposes = append(purposes, value, label)
+			}
+		}
+
+		for _, purpose := range purposes {
+			a, errA := search.Lookup(purpose)
+			b, errB := indexed.Lookup(purpose)
+			if !slices.Equal(a, b) || (errA == nil) != (errB == nil) {
+				t.Errorf("lookup of %s differs: %v %v, indexed %v %v", purpose, a, errA, b, errB)
+			}
+			if !slices.Equal(search.Above(purpose), indexed.Above(purpose)) {
+				t.Errorf("purposes above %s differ: %v, indexed %v", purpose, search.Above(purpose), indexed.Above(purpose))
+			}
+			if !slices.Equal(search.Below(purpose), indexed.Below(purpose)) {
+				t.Errorf("purposes below %s differ", purpose)
+			}
+		}
+	}
+}
+
+// resolving purposes from all over the hierarchy, with and without the index
+func BenchmarkResolvePurpose(b *testing.B) {
+	for _, size := range []int{100, 1000, 10000} {
+		trees := []*utils.Tree{utils.SyntheticPurposeTree(size, 8)}
+		for _, indexed := range []bool{false, true} {
+			c := Config{PurposeTrees: trees}
+			if indexed {
+				c.BuildIndex()
+			}
+			b.Run(fmt.Sprintf("purposes=%d/indexed=%v", size, indexed), func(b *testing.B) {
+				for n := 0; n < b.N; n++ {
+					c.ResolvePurpose(fmt.Sprintf("Purpose-%d", n%size))
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkBuildIndex(b *testing.B) {
+	for _, size := range []int{100, 1000, 10000} {
+		c := Config{PurposeTrees: []*utils.Tree{utils.SyntheticPurposeTree(size, 8)}}
+		b.Run(fmt.Sprintf("purposes=%d", size), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				c.BuildIndex()
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+/*
+
+lookup tables for the purpose trees, so resolving a purpose does not have to search the trees
+the index is built once when a config is loaded and shared by all copies of the config
+
+*/
+
+package policyConfig
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pzkt/abe-scripts/abe-scheme/internal/utils"
+)
+
+type purposeIndex struct {
+	byValue map[string][]*utils.Tree          // bare purpose -> its node in every tree, in tree order
+	byTree  map[string]map[string]*utils.Tree // tree name -> purpose -> node
+	byPath  map[string]*utils.Tree            // tree:root/.../purpose -> node
+	above   map[*utils.Tree][]string          // qualified purposes from the root down to the node
+
+	//qualified purposes of every tree in depth-first order, the descendants of a node are the range [start, end)
+	order []string
+	start map[*utils.Tree]int
+	end   map[*utils.Tree]int
+}
+
+// build the index for the current purpose trees, this has to be repeated whenever the trees change
+func (p *Config) BuildIndex() {
+	index := &purposeIndex{
+		byValue: map[string][]*utils.Tree{},
+		byTree:  map[string]map[string]*utils.Tree{},
+		byPath:  map[string]*utils.Tree{},
+		above:   map[*utils.Tree][]string{},
+		start:   map[*utils.Tree]int{},
+		end:     map[*utils.Tree]int{},
+	}
+	for _, t := range p.PurposeTrees {
+		name := t.TreeName()
+		if index.byTree[name] == nil {
+			index.byTree[name] = map[string]*utils.Tree{}
+		}
+		index.add(t, name, nil, "")
+	}
+	p.index = index
+}
+
+func (index *purposeIndex) add(t *utils.Tree, name string, above []string, path string) {
+	qualified := name + ":" + t.Value
+	if path == "" {
+		path = t.Value
+	} else {
+		path = path + "/" + t.Value
+	}
+
+	index.byValue[t.Value] = append(index.byValue[t.Value], t)
+	if _, ok := index.byTree[name][t.Value]; !ok {
+		index.byTree[name][t.Value] = t
+	}
+	index.byPath[name+":"+path] = t
+	//the full slice expression makes every node append to its own copy
+	index.above[t] = append(above[:len(above):len(above)], qualified)
+
+	index.start[t] = len(index.order)
+	index.order = append(index.order, qualified)
+	for _, c := range t.Children {
+		index.add(c, name, index.above[t], path)
+	}
+	index.end[t] = len(index.order)
+}
+
+func (index *purposeIndex) below(t *utils.Tree) []string {
+	return index.order[index.start[t]:index.end[t]]
+}
+
+// same results as the search in Config.Lookup
+func (index *purposeIndex) lookup(purpose string) ([]*utils.Tree, error) {
+	name, path, qualified := strings.Cut(purpose, ":")
+	if !qualified {
+		return append([]*utils.Tree{}, index.byValue[purpose]...), nil
+	}
+
+	tree, ok := index.byTree[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown purpose tree %q", name)
+	}
+	node, ok := tree[path]
+	if strings.Contains(path, "/") {
+		node, ok = index.byPath[purpose]
+	}
+	if !ok {
+		return nil, fmt.Errorf("purpose %q not found in tree %q", path, name)
+	}
+	return []*utils.Tree{node}, nil
+}
@@ -0,0 +1,100 @@
+/*
+
+encoding of purpose trees for JSON, CBOR and MessagePack
+Parent pointers make a tree cyclic, so a tree is encoded as a flat list of its nodes in depth-first order
+where every node refers to its parent by index. Decoding rebuilds the parent pointers
+
+*/
+
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type flatNode struct {
+	Parent int    `json:"parent" cbor:"parent" msgpack:"parent"` // -1 for the root of the encoded tree
+	Value  string `json:"value" cbor:"value" msgpack:"value"`
+	ID     string `json:"id" cbor:"id" msgpack:"id"`
+	Name   string `json:"name,omitempty" cbor:"name,omitempty" msgpack:"name,omitempty"`
+}
+
+// the node and its descendants, the parent of the node itself is not part of the encoding
+func (t Tree) flatten() []flatNode {
+	nodes := []flatNode{}
+	var add func(n *Tree, parent int)
+	add = func(n *Tree, parent int) {
+		index := len(nodes)
+		nodes = append(nodes, flatNode{Parent: parent, Value: n.Value, ID: n.ID, Name: n.Name})
+		for _, c := range n.Children {
+			add(c, index)
+		}
+	}
+	add(&t, -1)
+	return nodes
+}
+
+// rebuild the tree into t, parents always come before their children
+func (t *Tree) unflatten(nodes []flatNode) error {
+	if len(nodes) == 0 || nodes[0].Parent != -1 {
+		return fmt.Errorf("purpose tree encoding must start with its root")
+	}
+
+	trees := make([]*Tree, len(nodes))
+	for i, n := range nodes {
+		node := &Tree{Value: n.Value, ID: n.ID, Name: n.Name}
+		if i == 0 {
+			*t = *node
+			trees[0] = t
+			continue
+		}
+		if n.Parent < 0 || n.Parent >= i {
+			return fmt.Errorf("purpose tree node %d (%q) has invalid parent %d", i, n.Value, n.Parent)
+		}
+		parent := trees[n.Parent]
+		node.Parent = parent
+		parent.Children = append(parent.Children, node)
+		trees[i] = node
+	}
+	return nil
+}
+
+func (t Tree) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.flatten())
+}
+
+func (t *Tree) UnmarshalJSON(data []byte) error {
+	var nodes []flatNode
+	if err := json.Unmarshal(data, &nodes); err != nil {
+		return err
+	}
+	return t.unflatten(nodes)
+}
+
+func (t Tree) MarshalCBOR() ([]byte, error) {
+	return cbor.Marshal(t.flatten())
+}
+
+func (t *Tree) UnmarshalCBOR(data []byte) error {
+	var nodes []flatNode
+	if err := cbor.Unmarshal(data, &nodes); err != nil {
+		return err
+	}
+	return t.unflatten(nodes)
+}
+
+func (t Tree) MarshalMsgpack() ([]byte, error) {
+	return msgpack.Marshal(t.flatten())
+}
+
+func (t *Tree) UnmarshalMsgpack(data []byte) error {
+	var nodes []flatNode
+	if err := msgpack.Unmarshal(data, &nodes); err != nil {
+		return err
+	}
+	return t.unflatten(nodes)
+}
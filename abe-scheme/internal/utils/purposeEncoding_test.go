@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"slices"
+	"testing"
+)
+
+type encodedTrees struct {
+	Version int
+	Trees   []*Tree
+}
+
+func TestTreeRoundTrip(t *testing.T) {
+	for _, codec := range []struct {
+		name   string
+		encode func(any) []byte
+		decode func([]byte, any)
+	}{
+		{"json", ToBytesJson, FromBytesJson},
+		{"cbor", ToBytesCbor, FromBytesCbor},
+		{"msgpack", ToBytesMsgPack, FromBytesMsgPack},
+	} {
+		original := encodedTrees{Version: 3, Trees: append(ExamplePurposeTrees(), SyntheticPurposeTree(50, 3))}
+
+		var decoded encodedTrees
+		codec.decode(codec.encode(original), &decoded)
+
+		if decoded.Version != original.Version || len(decoded.Trees) != len(original.Trees) {
+			t.Fatalf("%s: got %+v", codec.name, decoded)
+		}
+		for i, tree := range decoded.Trees {
+			want := original.Trees[i]
+			if tree.String() != want.String() || tree.Name != want.Name || !slices.Equal(ids(tree), ids(want)) {
+				t.Errorf("%s: tree %d differs:\n%s\nwant:\n%s", codec.name, i, tree, want)
+			}
+			if tree.Parent != nil {
+				t.Errorf("%s: root of tree %d has a parent", codec.name, i)
+			}
+			checkParents(t, codec.name, tree)
+		}
+
+		//parents are rebuilt, so root paths and qualified values work right away
+		email, found := decoded.Trees[0].FindValue("Email")
+		if !found || email.QualifiedValue() != "commerce:Email" || len(email.GetRootPath()) != 4 {
+			t.Errorf("%s: parents of Email not rebuilt", codec.name)
+		}
+	}
+}
+
+func checkParents(t *testing.T, codec string, tree *Tree) {
+	for _, c := range tree.Children {
+		if c.Parent != tree {
+			t.Errorf("%s: parent of %s is not %s", codec, c.Value, tree.Value)
+		}
+		checkParents(t, codec, c)
+	}
+}
+
+func TestSubtreeEncoding(t *testing.T) {
+	marketing, _ := ExamplePurposeTrees()[0].FindValue("Marketing")
+
+	var decoded Tree
+	FromBytes(ToBytes(marketing), &decoded)
+	if decoded.Parent != nil || decoded.String() != marketing.String() {
+		t.Errorf("a subtree should decode as a tree of its own, got:\n%s", decoded.String())
+	}
+}
+
+func TestInvalidTreeEncoding(t *testing.T) {
+	for _, data := range []string{
+		`[]`,
+		`[{"parent": 0, "value": "A"}]`,
+		`[{"parent": -1, "value": "A"}, {"parent": 1, "value": "B"}]`,
+	} {
+		var tree Tree
+		if err := tree.UnmarshalJSON([]byte(data)); err == nil {
+			t.Errorf("%s should be rejected", data)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+/*
+
+loading and validation of purpose hierarchies defined in YAML or JSON files
+a file contains a list of trees, every node has a value and optional children
+roots can have a name to refer to the tree, otherwise it is referred to by the root value
+nodes can have an id, otherwise one is derived from the id of the parent and the value
+
+trees:
+  - name: commerce
+    value: General-Purpose
+    children:
+      - value: Purchase
+      - value: Admin
+        children:
+          - value: Profiling
+
+*/
+
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+type PurposeFile struct {
+	Trees []PurposeNode `yaml:"trees" json:"trees"`
+}
+
+type PurposeNode struct {
+	Name     string        `yaml:"name,omitempty" json:"name,omitempty"`
+	ID       string        `yaml:"id,omitempty" json:"id,omitempty"`
+	Value    string        `yaml:"value" json:"value"`
+	Children []PurposeNode `yaml:"children,omitempty" json:"children,omitempty"`
+}
+
+// read, build and validate the purpose trees of a file, .json files are read as JSON and everything else as YAML
+func LoadPurposeTrees(path string) ([]*Tree, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading purpose tree file: %w", err)
+	}
+
+	var file PurposeFile
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&file)
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error parsing purpose tree file %s: %w", path, err)
+	}
+
+	trees := file.Build()
+	if err := ValidatePurposeTrees(trees); err != nil {
+		return nil, fmt.Errorf("invalid purpose tree file %s: %w", path, err)
+	}
+	return trees, nil
+}
+
+// turn the file representation into connected trees
+func (f PurposeFile) Build() []*Tree {
+	trees := []*Tree{}
+	for _, n := range f.Trees {
+		root := NewTree(n.Value)
+		if n.Name != "" {
+			root = NewNamedTree(n.Name, n.Value)
+		}
+		n.addChildren(root)
+		trees = append(trees, root)
+	}
+	return trees
+}
+
+func (n PurposeNode) addChildren(t *Tree) {
+	if n.ID != "" {
+		t.ID = n.ID
+	}
+	for _, c := range n.Children {
+		child := t.AddChild(c.Value)
+		child.ID = derivedID(t.ID, c.Value)
+		c.addChildren(child)
+	}
+}
+
+// the file representation of existing trees, e.g. to export ExamplePurposeTrees
+func NewPurposeFile(trees []*Tree) PurposeFile {
+	f := PurposeFile{}
+	for _, t := range trees {
+		f.Trees = append(f.Trees, newPurposeNode(t))
+	}
+	return f
+}
+
+func newPurposeNode(t *Tree) PurposeNode {
+	n := PurposeNode{Name: t.Name, ID: t.ID, Value: t.Value}
+	for _, c := range t.Children {
+		n.Children = append(n.Children, newPurposeNode(c))
+	}
+	return n
+}
+
+// check that every label is set, no value appears twice within a tree, no node is reachable twice,
+// tree names are unique and every node has a unique id
+// the same purpose may appear in different trees, e.g. a shared root
+func ValidatePurposeTrees(trees []*Tree) error {
+	var errs []error
+	if len(trees) == 0 {
+		errs = append(errs, errors.New("no purpose trees defined"))
+	}
+
+	names := map[string]int{}
+	ids := map[string]string{}
+	for i, t := range trees {
+		if t == nil {
+			errs = append(errs, fmt.Errorf("tree %d: missing root", i))
+			continue
+		}
+
+		name := t.TreeName()
+		if first, ok := names[name]; ok {
+			errs = append(errs, fmt.Errorf("tree %d: name %q is already used by tree %d, set a name for one of them", i, name, first))
+		} else {
+			names[name] = i
+		}
+		if t.Name != "" && !usableInPolicy(t.Name) {
+			errs = append(errs, fmt.Errorf("tree %d: name %q can not be used in a policy", i, t.Name))
+		}
+
+		visited := map[*Tree]bool{}
+		values := map[string][]string{}
+		errs = append(errs, validateNode(t, fmt.Sprintf("tree %d", i), visited, values, ids)...)
+
+		//sorted so the message does not change between runs
+		for _, value := range slices.Sorted(maps.Keys(values)) {
+			if paths := values[value]; len(paths) > 1 {
+				errs = append(errs, fmt.Errorf("tree %d: duplicate value %q at %s", i, value, strings.Join(paths, " and ")))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func validateNode(t *Tree, path string, visited map[*Tree]bool, values map[string][]string, ids map[string]string) []error {
+	if visited[t] {
+		return []error{fmt.Errorf("%s: cycle back to %q", path, t.Value)}
+	}
+	visited[t] = true
+
+	var errs []error
+	switch {
+	case strings.TrimSpace(t.Value) == "":
+		errs = append(errs, fmt.Errorf("%s: empty label", path))
+	case !usableInPolicy(t.Value):
+		errs = append(errs, fmt.Errorf("%s: label %q can not be used in a policy", path, t.Value))
+	default:
+		path = path + "/" + t.Value
+		values[t.Value] = append(values[t.Value], path)
+	}
+
+	if t.ID == "" {
+		errs = append(errs, fmt.Errorf("%s: missing id", path))
+	} else if other, ok := ids[t.ID]; ok {
+		errs = append(errs, fmt.Errorf("%s: id %q is already used by %s", path, t.ID, other))
+	} else {
+		ids[t.ID] = path
+	}
+
+	for _, c := range t.Children {
+		if c == nil {
+			errs = append(errs, fmt.Errorf("%s: missing child", path))
+			continue
+		}
+		errs = append(errs, validateNode(c, path, visited, values, ids)...)
+	}
+	return errs
+}
+
+// labels have to be identifiers of the policy language: a letter followed by letters, digits, '_' or '-'
+// and none of the keywords in any case
+func usableInPolicy(label string) bool {
+	if slices.Contains([]string{"AND", "OR", "NOT", "OF", "EXCEPT"}, strings.ToUpper(label)) {
+		return false
+	}
+	for i, r := range label {
+		if !unicode.IsLetter(r) && (i == 0 || !unicode.IsDigit(r) && r != '_' && r != '-') {
+			return false
+		}
+	}
+	return true
+}
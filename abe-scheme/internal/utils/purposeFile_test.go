@@ -0,0 +1,138 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestExampleFileMatchesExampleTrees(t *testing.T) {
+	trees, err := LoadPurposeTrees("../../configs/purpose-trees.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ExamplePurposeTrees()
+	if len(trees) != len(want) {
+		t.Fatalf("got %d trees, want %d", len(trees), len(want))
+	}
+	for i := range trees {
+		if trees[i].String() != want[i].String() || trees[i].Name != want[i].Name {
+			t.Errorf("tree %d differs:\n%s\nwant:\n%s", i, trees[i], want[i])
+		}
+		if !slices.Equal(ids(trees[i]), ids(want[i])) {
+			t.Errorf("tree %d: node ids differ from the example trees", i)
+		}
+	}
+
+	//parents have to be connected for purpose resolution
+	node, found := trees[0].FindValue("Email")
+	if !found {
+		t.Fatal("Email not found")
+	}
+	if strings.Join(node.GetRootPath(), "/") != "General-Purpose/Marketing/Third-Party/Email" {
+		t.Errorf("unexpected root path %v", node.GetRootPath())
+	}
+}
+
+func TestLoadJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trees.json")
+	content := `{"trees": [{"value": "Root", "children": [{"value": "Leaf"}]}]}`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	trees, err := LoadPurposeTrees(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trees[0].String() != "Root\n-Leaf\n" {
+		t.Errorf("unexpected tree:\n%s", trees[0])
+	}
+}
+
+func TestValidatePurposeTrees(t *testing.T) {
+	duplicate := NewTree("Root")
+	duplicate.AddChild("A").AddChild("B")
+	duplicate.AddChild("B")
+
+	cycle := NewTree("Root")
+	child := cycle.AddChild("A")
+	child.Children = append(child.Children, cycle)
+
+	empty := NewTree("Root")
+	empty.AddChild(" ")
+
+	keyword := NewTree("Root")
+	keyword.AddChild("or")
+	keyword.AddChild("Third Party")
+
+	for _, tc := range []struct {
+		name  string
+		tree  *Tree
+		wants []string
+	}{
+		{"duplicate", duplicate, []string{`duplicate value "B" at tree 0/Root/A/B and tree 0/Root/B`}},
+		{"cycle", cycle, []string{`tree 0/Root/A: cycle back to "Root"`}},
+		{"empty", empty, []string{"tree 0/Root: empty label"}},
+		{"keyword", keyword, []string{`label "or"`, `label "Third Party"`}},
+	} {
+		err := ValidatePurposeTrees([]*Tree{tc.tree})
+		if err == nil {
+			t.Errorf("%s: expected an error", tc.name)
+			continue
+		}
+		for _, want := range tc.wants {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("%s: %q does not contain %q", tc.name, err, want)
+			}
+		}
+	}
+
+	unnamed := []*Tree{NewTree("General-Purpose"), NewTree("General-Purpose")}
+	unnamed[1].AddChild("Research")
+	if err := ValidatePurposeTrees(unnamed); err == nil || !strings.Contains(err.Error(), `name "General-Purpose" is already used`) {
+		t.Errorf("trees with the same root need names, got %v", err)
+	}
+
+	if err := ValidatePurposeTrees(ExamplePurposeTrees()); err != nil {
+		t.Errorf("example trees should be valid, a value may repeat across trees: %v", err)
+	}
+}
+
+func ids(t *Tree) []string {
+	out := []string{t.ID}
+	for _, c := range t.Children {
+		out = append(out, ids(c)...)
+	}
+	return out
+}
+
+func TestNodeIDs(t *testing.T) {
+	trees := ExamplePurposeTrees()
+	marketing, _ := trees[0].FindValue("Marketing")
+	id := marketing.ID
+
+	if again, _ := ExamplePurposeTrees()[0].FindValue("Marketing"); again.ID != id {
+		t.Error("ids should be the same every time the trees are built")
+	}
+
+	//renames and moves keep the id, copies keep all ids
+	marketing.Value = "Advertising"
+	admin, _ := trees[0].FindValue("Admin")
+	marketing.MoveTo(admin)
+	clone := CloneTrees(trees)
+	if moved, _ := clone[0].FindValue("Advertising"); moved.ID != id {
+		t.Errorf("id changed from %s to %s", id, moved.ID)
+	}
+	if moved, _ := clone[0].FindValue("Advertising"); moved.QualifiedValue() != "commerce:Advertising" {
+		t.Errorf("unexpected qualified value %s", moved.QualifiedValue())
+	}
+
+	//the same purpose in different trees has different ids
+	first, _ := trees[0].FindValue("General-Purpose")
+	second, _ := trees[1].FindValue("General-Purpose")
+	if first.ID == second.ID {
+		t.Error("roots of different trees share an id")
+	}
+}
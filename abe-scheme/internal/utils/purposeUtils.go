@@ -6,51 +6,98 @@ functions for the purpose hierarchy tree struct
 
 package utils
 
-import "strings"
+import (
+	"fmt"
+	"slices"
+	"strings"
 
+	"github.com/google/uuid"
+)
+
+// encoded as a flat list of nodes, see purposeEncoding.go
 type Tree struct {
-	Parent   *Tree   `json:"-"`
-	Children []*Tree `json:"children"`
-	Value    string  `json:"value"`
+	Parent   *Tree
+	Children []*Tree
+	Value    string
+	ID       string // stable across renames and moves
+	Name     string // only for roots, qualifies purposes as name:purpose
 }
 
+// namespace of the ids of roots and of nodes loaded without an id, see derivedID
+var purposeNamespace = uuid.MustParse("5a1c9e7e-3b0f-4c55-9d4e-2f6f1b8a7c31")
+
 func NewTree(rootValue string) *Tree {
 	t := new(Tree)
 	t.Value = rootValue
+	t.ID = uuid.NewSHA1(purposeNamespace, []byte(rootValue)).String()
 	return t
 }
 
-func (t Tree) FindValue(value string) (Tree, bool) {
+// a tree that is referred to by name instead of its root value, e.g. to distinguish trees with the same root
+func NewNamedTree(name string, rootValue string) *Tree {
+	t := NewTree(rootValue)
+	t.Name = name
+	t.ID = uuid.NewSHA1(purposeNamespace, []byte(name)).String()
+	return t
+}
+
+// the name of the tree the node belongs to, the root value if the tree has no name
+func (t *Tree) TreeName() string {
+	root := t
+	for root.Parent != nil {
+		root = root.Parent
+	}
+	if root.Name == "" {
+		return root.Value
+	}
+	return root.Name
+}
+
+// the purpose qualified by its tree, e.g. health:Research
+func (t *Tree) QualifiedValue() string {
+	return t.TreeName() + ":" + t.Value
+}
+
+// the first node with the value, depth-first. Use the index of policyConfig.Config for repeated lookups
+func (t *Tree) FindValue(value string) (*Tree, bool) {
 	if t.Value == value {
 		return t, true
 	}
 	for _, ct := range t.Children {
-		node, found := ct.FindValue(value)
-		if found {
+		if node, found := ct.FindValue(value); found {
 			return node, true
 		}
 	}
-	return t, false
+	return nil, false
 }
 
-func (t Tree) GetRootPath() []string {
-	if t.Parent == nil {
-		return []string{t.Value}
+// check if the node is t or one of its descendants
+func (t *Tree) Contains(node *Tree) bool {
+	for ; node != nil; node = node.Parent {
+		if node == t {
+			return true
+		}
 	}
-	return append(t.Parent.GetRootPath(), t.Value)
+	return false
 }
 
-func (t *Tree) ReconnectParents(p *Tree) {
-	t.Parent = p
-	for _, c := range t.Children {
-		c.ReconnectParents(t)
+func (t *Tree) GetRootPath() []string {
+	depth := 0
+	for n := t; n != nil; n = n.Parent {
+		depth++
+	}
+	path := make([]string, depth)
+	for n := t; n != nil; n = n.Parent {
+		depth--
+		path[depth] = n.Value
 	}
+	return path
 }
 
-func (t *Tree) DisconnectParents() {
-	t.Parent = nil
+func (t *Tree) ReconnectParents(p *Tree) {
+	t.Parent = p
 	for _, c := range t.Children {
-		c.DisconnectParents()
+		c.ReconnectParents(t)
 	}
 }
 
@@ -58,12 +105,54 @@ func (t *Tree) AddChild(value string) *Tree {
 	child := new(Tree)
 	t.Children = append(t.Children, child)
 	child.Value = value
+	child.ID = uuid.New().String()
 	child.Parent = t
 	return child
 }
 
+// remove the node from its parent, its children are moved up to the parent so their purposes are kept
+func (t *Tree) Remove() {
+	if t.Parent == nil {
+		return
+	}
+	parent := t.Parent
+	t.Detach()
+	for _, c := range t.Children {
+		c.Parent = parent
+	}
+	parent.Children = append(parent.Children, t.Children...)
+	t.Children = nil
+}
+
+// remove the node together with its descendants from its parent
+func (t *Tree) Detach() {
+	if t.Parent == nil {
+		return
+	}
+	t.Parent.Children = slices.DeleteFunc(t.Parent.Children, func(c *Tree) bool { return c == t })
+	t.Parent = nil
+}
+
+// move the node with its descendants below a new parent, which must not be one of its descendants
+func (t *Tree) MoveTo(parent *Tree) {
+	t.Detach()
+	parent.Children = append(parent.Children, t)
+	t.Parent = parent
+}
+
+// the id of a node that was loaded without one, derived from the id of its parent and its value so it is the same
+// every time the same file is loaded. Nodes added later get random ids, a derived id would collide with the id
+// a renamed node kept from its old value. Ids from files do not have to be uuids
+func derivedID(parentID string, value string) string {
+	parent, err := uuid.Parse(parentID)
+	if err != nil {
+		parent = uuid.NewSHA1(purposeNamespace, []byte(parentID))
+	}
+	return uuid.NewSHA1(parent, []byte(value)).String()
+}
+
 func ExamplePurposeTrees() []*Tree {
-	firstTree := NewTree("General-Purpose")
+	firstTree := NewNamedTree("commerce", "General-Purpose")
 	firstTree.AddChild("Purchase")
 	firstTree.AddChild("Shipping")
 	admin := firstTree.AddChild("Admin")
@@ -75,7 +164,7 @@ func ExamplePurposeTrees() []*Tree {
 	thirdParty.AddChild("Email")
 	thirdParty.AddChild("Phone")
 
-	secondTree := NewTree("General-Purpose")
+	secondTree := NewNamedTree("health", "General-Purpose")
 	healthRecord := secondTree.AddChild("Health-Record")
 	healthRecord.AddChild("Optometry")
 	healthRecord.AddChild("Radiology")
@@ -84,9 +173,19 @@ func ExamplePurposeTrees() []*Tree {
 	research.AddChild("Anonymized-Research")
 	research.AddChild("Masked-Research")
 
+	//built again at every start, so the ids are derived like those of a purpose file without ids
+	deriveIDs(firstTree)
+	deriveIDs(secondTree)
 	return []*Tree{firstTree, secondTree}
 }
 
+func deriveIDs(t *Tree) {
+	for _, c := range t.Children {
+		c.ID = derivedID(t.ID, c.Value)
+		deriveIDs(c)
+	}
+}
+
 func (t *Tree) String() string {
 	var builder strings.Builder
 	t.stringHelper(&builder, 0)
@@ -107,3 +206,23 @@ func (t *Tree) stringHelper(b *strings.Builder, depth int) {
 		child.stringHelper(b, depth+1)
 	}
 }
+
+// deep copy of the trees with connected parents
+func CloneTrees(trees []*Tree) []*Tree {
+	return NewPurposeFile(trees).Build()
+}
+
+// a single tree with the given number of purposes named Purpose-0, Purpose-1, ... where every purpose has up to fanout
+// children, for benchmarks with large hierarchies
+func SyntheticPurposeTree(purposes int, fanout int) *Tree {
+	root := NewNamedTree("synthetic", "Purpose-0")
+	queue := []*Tree{root}
+	for i := 1; i < purposes; i++ {
+		parent := queue[0]
+		queue = append(queue, parent.AddChild(fmt.Sprintf("Purpose-%d", i)))
+		if len(parent.Children) == fanout {
+			queue = queue[1:]
+		}
+	}
+	return root
+}
@@ -0,0 +1,39 @@
+/*
+
+time bound access
+Time is divided into epochs of a fixed length, counted from 1970-01-01. The authority issues keys with the bit attributes
+of the current epoch, and data owners restrict their policies to a range of epochs, so access expires without revocation
+
+*/
+
+package utils
+
+import (
+	"fmt"
+	"time"
+)
+
+// attribute the epoch of a key is encoded in, it can not be requested as a numeric value
+const TimeAttribute = "time"
+
+// number of bits used to encode epochs, with one hour epochs this lasts until the year 3883
+const TimeValueSize = 24
+
+// the epoch that contains t
+func Epoch(t time.Time, length time.Duration) int {
+	return int(t.Unix() / int64(length/time.Second))
+}
+
+// the first moment of an epoch
+func EpochStart(epoch int, length time.Duration) time.Time {
+	return time.Unix(int64(epoch)*int64(length/time.Second), 0).UTC()
+}
+
+// the key attributes for the epoch that contains t
+func TimeAttributes(t time.Time, length time.Duration) ([]string, error) {
+	epoch := Epoch(t, length)
+	if epoch < 0 || epoch >= 1<<TimeValueSize {
+		return nil, fmt.Errorf("%s is outside of the %d epochs that can be encoded", t, 1<<TimeValueSize)
+	}
+	return BitAttributes(TimeAttribute, uint(epoch), TimeValueSize), nil
+}
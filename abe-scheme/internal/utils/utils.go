@@ -1,13 +1,16 @@
 package utils
 
 import (
+	"bytes"
 	"database/sql"
 	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"maps"
 	"os"
+	"slices"
 	"time"
 
 	"github.com/fxamacker/cbor/v2"
@@ -17,13 +20,74 @@ import (
 
 // generic struct for transmitting information between different parties
 type Record struct {
-	Table           string    `json:"table"`
-	ID              uuid.UUID `json:"id"`
-	PrivateWriteKey []byte    `json:"private_write_key"`
-	PublicWriteKey  []byte    `json:"public_write_key"`
-	Data            []byte    `json:"data"`
-	Created         time.Time `json:"created"`
-	Signature       []byte    `json:"signature"`
+	Table           string           `json:"table"`
+	ID              uuid.UUID        `json:"id"`
+	PrivateWriteKey []byte           `json:"private_write_key"`
+	PublicWriteKey  []byte           `json:"public_write_key"`
+	Data            []byte           `json:"data"`
+	Policy          PolicyDescriptor `json:"policy"`
+	Created         time.Time        `json:"created"`
+	Signature       []byte           `json:"signature"`
+
+	//fields of the entry the database can filter and sort by, in plain text
+	Metadata map[string]string `json:"metadata,omitempty"`
+	//fields that can only be compared for equality: blind indexes and deterministic ciphertexts, see crypto.SearchKey
+	Search map[string]string `json:"search,omitempty"`
+	//encrypted keyword index of the entry, only searchable with trapdoors from the index key of its table
+	Keywords *KeywordTags `json:"keywords,omitempty"`
+	//numeric fields encrypted with the analytics key of the table, the database can add them up, see crypto.SumAggregateValues
+	Aggregates map[string][]byte `json:"aggregates,omitempty"`
+}
+
+// the keywords of an entry, each as a keyed hash of the entry's nonce under the trapdoor of the keyword
+// the nonce makes the tags of equal keywords differ between entries, see crypto.IndexKeywords
+type KeywordTags struct {
+	Nonce []byte   `json:"nonce"`
+	Tags  [][]byte `json:"tags"`
+}
+
+// non-secret description of the policies an entry was encrypted under, stored next to the ciphertext
+// this lets users check if they can decrypt an entry without trying it
+type PolicyDescriptor struct {
+	ReadPurposes  string `json:"read_purposes"`  // read policy as written by the data owner
+	ReadPolicy    string `json:"read_policy"`    // attribute policy the data was encrypted under
+	WritePurposes string `json:"write_purposes"` // write policy as written by the data owner
+	WritePolicy   string `json:"write_policy"`   // attribute policy the write key was encrypted under
+	ConfigVersion int    `json:"config_version"` // version of the policy config the purposes were resolved with
+
+	//the data can only be read with time bound keys for epochs between these times, zero times do not restrict it
+	ReadAfter time.Time `json:"read_after"`
+	ReadUntil time.Time `json:"read_until"`
+
+	//entries with field level encryption have one ciphertext per field, in this order, see FieldPolicy
+	Fields []FieldPolicy `json:"fields,omitempty"`
+}
+
+// the policy a single field of an entry was encrypted under
+// fields without a policy of their own use the read policy of the entry and have no purposes
+type FieldPolicy struct {
+	Field    string `json:"field"`
+	Purposes string `json:"purposes,omitempty"`
+	Policy   string `json:"policy"`
+}
+
+// all parts of a record that are covered by its signature, this prevents any part of the record from being tampered with
+func (r Record) Checksum() []byte {
+	var checkSum bytes.Buffer
+	for _, s := range [][]byte{ToBytes(r.Table), r.ID[:], r.PrivateWriteKey, r.PublicWriteKey, r.Data, ToBytes(r.Policy), ToBytes(r.Created),
+		sortedFields(r.Metadata), sortedFields(r.Search), ToBytes(r.Keywords), sortedFields(r.Aggregates)} {
+		checkSum.Write(s)
+	}
+	return checkSum.Bytes()
+}
+
+// maps are encoded in no particular order, the checksum needs the same bytes every time
+func sortedFields[V any](fields map[string]V) []byte {
+	pairs := [][]any{}
+	for _, key := range slices.Sorted(maps.Keys(fields)) {
+		pairs = append(pairs, []any{key, fields[key]})
+	}
+	return ToBytes(pairs)
 }
 
 // try will exit if the function returned an error
@@ -43,10 +107,7 @@ func Assure[A any](result A, err error) A {
 }
 
 // connects to the postgres database and returns an sql.DB variable
-func Connect() *sql.DB {
-	db_password := "pwd"
-	connection := fmt.Sprintf("postgres://postgres:%s@localhost:5432/data?sslmode=disable", db_password)
-
+func Connect(connection string) *sql.DB {
 	db := Assure(sql.Open("postgres", connection))
 	Try(db.Ping())
 	return db
@@ -62,6 +123,11 @@ func FromBytes(data []byte, target any) {
 	FromBytesCbor(data, target)
 }
 
+// like FromBytes, but malformed data is returned as an error, e.g. for ciphertexts and keys received from others
+func DecodeBytes(data []byte, target any) error {
+	return cbor.Unmarshal(data, target)
+}
+
 // --- byte encoding using msgPack ---
 
 func ToBytesMsgPack(a any) []byte {
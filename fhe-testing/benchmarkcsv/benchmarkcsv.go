@@ -0,0 +1,174 @@
+/*
+
+The CSV harness shared by the benchmark packages of lattigo-HE and go-tfhe
+Every workload is run for every number of values and averaged over several runs, and the results are written in the
+layout of scheme-benchmarking/Results: the seconds to encrypt and evaluate to performance/<file>.csv and the bytes of
+the encrypted inputs to storage/<file>_ct.csv, one row per number of values. The files are only written once every
+workload succeeded, so a failing run leaves the existing results as they were
+
+*/
+
+package benchmarkcsv
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// the time to encrypt the inputs and to evaluate the workload, and the size of the encrypted inputs
+type Measurement struct {
+	Encrypt  time.Duration
+	Evaluate time.Duration
+	Bytes    int
+}
+
+// a workload run on the setup S of a library
+type Workload[S any] struct {
+	Name string
+	Run  func(setup S, values []uint64) (Measurement, error)
+}
+
+// comma separated positive numbers of values
+func ParseSizes(sizes string) ([]int, error) {
+	out := []int{}
+	for _, s := range strings.Split(sizes, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid size %q", s)
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+// run every workload with every number of values of random(n), average the runs and write them to the results folder
+func WriteResults[S any](results, file string, setup S, workloads []Workload[S], sizes []int, runs int, random func(n int) []uint64) error {
+	performance := filepath.Join(results, "performance", file+".csv")
+	storage := filepath.Join(results, "storage", file+"_ct.csv")
+	performanceTable, err := ReadTable(performance, "values")
+	if err != nil {
+		return err
+	}
+	storageTable, err := ReadTable(storage, "values")
+	if err != nil {
+		return err
+	}
+
+	for _, n := range sizes {
+		for _, w := range workloads {
+			var total Measurement
+			for range runs {
+				m, err := w.Run(setup, random(n))
+				if err != nil {
+					return fmt.Errorf("%s with %d values: %w", w.Name, n, err)
+				}
+				total.Encrypt += m.Encrypt
+				total.Evaluate += m.Evaluate
+				total.Bytes = m.Bytes
+			}
+			index := fmt.Sprint(n)
+			performanceTable.Set(index, w.Name+" encrypt", fmt.Sprintf("%.9f", total.Encrypt.Seconds()/float64(runs)))
+			performanceTable.Set(index, w.Name, fmt.Sprintf("%.9f", total.Evaluate.Seconds()/float64(runs)))
+			storageTable.Set(index, w.Name, fmt.Sprint(total.Bytes))
+		}
+	}
+
+	if err := performanceTable.Write(performance); err != nil {
+		return err
+	}
+	return storageTable.Write(storage)
+}
+
+// a CSV whose first column is the index
+type Table struct {
+	records [][]string
+}
+
+// the table of an existing CSV, or an empty table if the file does not exist
+func ReadTable(fileName, indexName string) (*Table, error) {
+	file, err := os.Open(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Table{[][]string{{indexName}}}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %w", err)
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("error reading CSV: %w", err)
+	}
+	if len(records) == 0 {
+		records = [][]string{{indexName}}
+	}
+	return &Table{records}, nil
+}
+
+// set a single value, missing rows and columns are added
+func (t *Table) Set(index, column, value string) {
+	columnIndex := -1
+	for i, h := range t.records[0] {
+		if h == column {
+			columnIndex = i
+		}
+	}
+	if columnIndex == -1 {
+		t.records[0] = append(t.records[0], column)
+		columnIndex = len(t.records[0]) - 1
+	}
+
+	rowIndex := -1
+	for i, record := range t.records[1:] {
+		if record[0] == index {
+			rowIndex = i + 1
+		}
+	}
+	if rowIndex == -1 {
+		t.records = append(t.records, []string{index})
+		rowIndex = len(t.records) - 1
+	}
+
+	for i := range t.records {
+		for len(t.records[i]) < len(t.records[0]) {
+			t.records[i] = append(t.records[i], "")
+		}
+	}
+	t.records[rowIndex][columnIndex] = value
+}
+
+// the value of a cell, empty if the row or column does not exist
+func (t *Table) Get(index, column string) string {
+	for i, h := range t.records[0] {
+		if h != column {
+			continue
+		}
+		for _, record := range t.records[1:] {
+			if record[0] == index {
+				return record[i]
+			}
+		}
+	}
+	return ""
+}
+
+// write the table to a temporary file next to fileName and rename it, so the CSV is never half written
+func (t *Table) Write(fileName string) error {
+	if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(filepath.Dir(fileName), filepath.Base(fileName)+".*")
+	if err != nil {
+		return fmt.Errorf("error creating file: %w", err)
+	}
+	defer os.Remove(file.Name())
+	if err := errors.Join(file.Chmod(0644), csv.NewWriter(file).WriteAll(t.records), file.Close()); err != nil {
+		return fmt.Errorf("error writing CSV: %w", err)
+	}
+	return os.Rename(file.Name(), fileName)
+}
@@ -0,0 +1,95 @@
+package benchmarkcsv
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var workloads = []Workload[int]{
+	{"sum", func(limit int, values []uint64) (Measurement, error) {
+		if len(values) > limit {
+			return Measurement{}, errors.New("too many values")
+		}
+		return Measurement{time.Second, 2 * time.Second, 8 * len(values)}, nil
+	}},
+}
+
+func zeros(n int) []uint64 {
+	return make([]uint64, n)
+}
+
+func TestWriteResults(t *testing.T) {
+	dir := t.TempDir()
+	if err := WriteResults(dir, "test", 100, workloads, []int{1, 10}, 2, zeros); err != nil {
+		t.Fatal(err)
+	}
+	//a second run with other sizes keeps the earlier rows
+	if err := WriteResults(dir, "test", 100, workloads, []int{100}, 1, zeros); err != nil {
+		t.Fatal(err)
+	}
+
+	performance, err := ReadTable(filepath.Join(dir, "performance", "test.csv"), "values")
+	if err != nil {
+		t.Fatal(err)
+	}
+	storage, err := ReadTable(filepath.Join(dir, "storage", "test_ct.csv"), "values")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		table               *Table
+		index, column, want string
+	}{
+		{performance, "1", "sum encrypt", "1.000000000"},
+		{performance, "10", "sum", "2.000000000"},
+		{performance, "100", "sum", "2.000000000"},
+		{storage, "10", "sum", "80"},
+		{storage, "100", "sum", "800"},
+	} {
+		if got := c.table.Get(c.index, c.column); got != c.want {
+			t.Errorf("%s of %s values is %q, want %q", c.column, c.index, got, c.want)
+		}
+	}
+}
+
+func TestFailedRunWritesNothing(t *testing.T) {
+	dir := t.TempDir()
+	if err := WriteResults(dir, "test", 100, workloads, []int{1}, 1, zeros); err != nil {
+		t.Fatal(err)
+	}
+	performance := filepath.Join(dir, "performance", "test.csv")
+	before, err := os.ReadFile(performance)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteResults(dir, "test", 100, workloads, []int{10, 1000}, 1, zeros); err == nil {
+		t.Fatal("1000 values should fail")
+	}
+	after, err := os.ReadFile(performance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(after) != string(before) {
+		t.Errorf("the failed run changed the results to\n%s", after)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(performance))
+	if len(entries) != 1 {
+		t.Errorf("%d files in the results folder, want only the CSV", len(entries))
+	}
+}
+
+func TestParseSizes(t *testing.T) {
+	sizes, err := ParseSizes("1, 100,10000")
+	if err != nil || len(sizes) != 3 || sizes[2] != 10000 {
+		t.Errorf("got %v, %v", sizes, err)
+	}
+	for _, invalid := range []string{"", "0", "1,x"} {
+		if _, err := ParseSizes(invalid); err == nil {
+			t.Errorf("%q should be invalid", invalid)
+		}
+	}
+}
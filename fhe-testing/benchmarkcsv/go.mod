@@ -0,0 +1,3 @@
+module benchmarkcsv
+
+go 1.23.4
@@ -0,0 +1,197 @@
+package benchmark
+
+import (
+	"flag"
+	"fmt"
+	"math/bits"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"benchmarkcsv"
+	"main/circuits"
+
+	"github.com/thedonutfactory/go-tfhe/gates"
+)
+
+// the same workloads as fhe-testing/lattigo-HE/benchmark, written in the layout of scheme-benchmarking/Results
+// every gate is bootstrapped, so at the default parameters a single 8 bit comparison takes several seconds
+// go test -run CSV -timeout 0 -results ../../../scheme-benchmarking/Results -params default128 -sizes 1,2,4
+var (
+	sizesFlag   = flag.String("sizes", "1,2,4", "comma separated numbers of encrypted values")
+	paramsFlag  = flag.String("params", "default128", "comma separated TFHE parameter sets, default128, default80 or the insecure test")
+	bitsFlag    = flag.Int("bits", 8, "bits of the summed and compared values")
+	runsFlag    = flag.Int("runs", 1, "runs averaged for every value in the CSVs")
+	resultsFlag = flag.String("results", "", "folder with the performance and storage results, TestCSV is skipped without it")
+)
+
+var parameterSets = map[string]func() *gates.GateBootstrappingParameterSet{
+	"default128": gates.Default128bitGateBootstrappingParameters,
+	"default80":  gates.Default80bitGateBootstrappingParameters,
+	"test":       gates.TestGateBootstrappingParameters,
+}
+
+type tfheSetup struct {
+	prv *gates.PrivateKey
+	c   *circuits.Circuits
+}
+
+func newSetup(name string) (*tfheSetup, error) {
+	params, found := parameterSets[name]
+	if !found {
+		return nil, fmt.Errorf("unknown parameter set %s", name)
+	}
+	pub, prv := params().GenerateKeys()
+	return &tfheSetup{prv, circuits.New(pub)}, nil
+}
+
+func randomValues(n int) []uint64 {
+	values := make([]uint64, n)
+	for i := range values {
+		values[i] = uint64(rand.Intn(1 << *bitsFlag))
+	}
+	return values
+}
+
+// every value is encrypted bit by bit, there is no packing
+func (s *tfheSetup) encrypt(values []uint64) ([]circuits.Int, int) {
+	encrypted := make([]circuits.Int, len(values))
+	bytes := 0
+	for i, v := range values {
+		encrypted[i] = circuits.Encrypt(s.prv, v, *bitsFlag)
+		bytes += encrypted[i].Bytes()
+	}
+	return encrypted, bytes
+}
+
+func (s *tfheSetup) sum(values []uint64) (benchmarkcsv.Measurement, error) {
+	start := time.Now()
+	encrypted, bytes := s.encrypt(values)
+	encrypt := time.Since(start)
+
+	//the sum is wide enough for all values, the narrower values are zero extended by Add
+	start = time.Now()
+	sum := append(encrypted[0], s.c.Constant(0, bits.Len(uint(len(values)-1)))...)
+	for _, x := range encrypted[1:] {
+		sum, _ = s.c.Add(sum, x)
+	}
+	evaluate := time.Since(start)
+
+	var want uint64
+	for _, v := range values {
+		want += v
+	}
+	if got := circuits.Decrypt(s.prv, sum); got != want {
+		return benchmarkcsv.Measurement{}, fmt.Errorf("sum %d, want %d", got, want)
+	}
+	return benchmarkcsv.Measurement{Encrypt: encrypt, Evaluate: evaluate, Bytes: bytes}, nil
+}
+
+func (s *tfheSetup) countAbove(values []uint64) (benchmarkcsv.Measurement, error) {
+	threshold := uint64(1) << (*bitsFlag - 1)
+	start := time.Now()
+	encrypted, bytes := s.encrypt(values)
+	t := circuits.Encrypt(s.prv, threshold, *bitsFlag)
+	encrypt := time.Since(start)
+
+	start = time.Now()
+	count := s.c.Constant(0, bits.Len(uint(len(values))))
+	for _, x := range encrypted {
+		count, _ = s.c.Add(count, circuits.Int{s.c.GreaterThan(x, t)})
+	}
+	evaluate := time.Since(start)
+
+	var want uint64
+	for _, v := range values {
+		if v > threshold {
+			want++
+		}
+	}
+	if got := circuits.Decrypt(s.prv, count); got != want {
+		return benchmarkcsv.Measurement{}, fmt.Errorf("count %d, want %d", got, want)
+	}
+	return benchmarkcsv.Measurement{Encrypt: encrypt, Evaluate: evaluate, Bytes: bytes}, nil
+}
+
+func (s *tfheSetup) compare(values []uint64) (benchmarkcsv.Measurement, error) {
+	others := randomValues(len(values))
+	start := time.Now()
+	a, bytes := s.encrypt(values)
+	b, _ := s.encrypt(others)
+	encrypt := time.Since(start)
+
+	start = time.Now()
+	greater := make(circuits.Int, len(values))
+	for i := range a {
+		greater[i] = s.c.GreaterThan(a[i], b[i])
+	}
+	evaluate := time.Since(start)
+
+	for i, bit := range greater {
+		if circuits.DecryptBit(s.prv, bit) != (values[i] > others[i]) {
+			return benchmarkcsv.Measurement{}, fmt.Errorf("%d > %d decrypted wrongly", values[i], others[i])
+		}
+	}
+	return benchmarkcsv.Measurement{Encrypt: encrypt, Evaluate: evaluate, Bytes: bytes}, nil
+}
+
+var workloads = []benchmarkcsv.Workload[*tfheSetup]{
+	{Name: "sum", Run: (*tfheSetup).sum},
+	{Name: "count above", Run: (*tfheSetup).countAbove},
+	{Name: "compare", Run: (*tfheSetup).compare},
+}
+
+// the evaluation time of every workload, without encryption, with the size of the encrypted inputs and the number of gates
+func BenchmarkWorkloads(b *testing.B) {
+	sizes, err := benchmarkcsv.ParseSizes(*sizesFlag)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, name := range strings.Split(*paramsFlag, ",") {
+		s, err := newSetup(name)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, w := range workloads {
+			for _, n := range sizes {
+				b.Run(fmt.Sprintf("%s/%s/Values_%d", name, strings.ReplaceAll(w.Name, " ", "_"), n), func(b *testing.B) {
+					values := randomValues(n)
+					var evaluate time.Duration
+					var m benchmarkcsv.Measurement
+					s.c.Reset()
+					for range b.N {
+						if m, err = w.Run(s, values); err != nil {
+							b.Fatal(err)
+						}
+						evaluate += m.Evaluate
+					}
+					b.ReportMetric(float64(evaluate.Nanoseconds())/float64(b.N), "eval-ns/op")
+					b.ReportMetric(float64(m.Bytes), "ct-bytes")
+					b.ReportMetric(float64(s.c.Gates())/float64(b.N), "gates/op")
+				})
+			}
+		}
+	}
+}
+
+// performance/gotfhe_<params>.csv with the seconds to encrypt and evaluate every workload,
+// storage/gotfhe_<params>_ct.csv with the bytes of the encrypted inputs
+func TestCSV(t *testing.T) {
+	if *resultsFlag == "" {
+		t.Skip("set -results to write the benchmark CSVs")
+	}
+	sizes, err := benchmarkcsv.ParseSizes(*sizesFlag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range strings.Split(*paramsFlag, ",") {
+		s, err := newSetup(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := benchmarkcsv.WriteResults(*resultsFlag, "gotfhe_"+name, s, workloads, sizes, *runsFlag, randomValues); err != nil {
+			t.Fatalf("%s %v", name, err)
+		}
+	}
+}
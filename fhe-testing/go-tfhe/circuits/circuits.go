@@ -0,0 +1,236 @@
+/*
+
+Bootstrapped gate circuits over encrypted integers
+An integer is a slice of encrypted bits, least significant bit first, of any width. Every binary gate and every
+multiplexer is bootstrapped, so the noise does not grow with the depth of a circuit and its cost is the number of gates.
+Not and constants need no bootstrapping and are not counted. Operands of different widths are zero extended, results
+of Add and Subtract wrap around modulo 2^width like unsigned integers
+
+*/
+
+package circuits
+
+import (
+	"time"
+
+	"github.com/thedonutfactory/go-tfhe/core"
+	"github.com/thedonutfactory/go-tfhe/gates"
+)
+
+// the bits of an unsigned integer, least significant first
+type Int []*core.LweSample
+
+// the number and the total time of the bootstrapped gates of one kind
+type GateStats struct {
+	Count int
+	Time  time.Duration
+}
+
+func (s GateStats) PerGate() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Time / time.Duration(s.Count)
+}
+
+type Circuits struct {
+	pub   *gates.PublicKey
+	Stats map[string]GateStats
+}
+
+func New(pub *gates.PublicKey) *Circuits {
+	return &Circuits{pub: pub, Stats: make(map[string]GateStats)}
+}
+
+// the gates evaluated since the last reset, by kind
+func (c *Circuits) Reset() {
+	c.Stats = make(map[string]GateStats)
+}
+
+func (c *Circuits) Gates() int {
+	count := 0
+	for _, s := range c.Stats {
+		count += s.Count
+	}
+	return count
+}
+
+// the size of the masks and bodies of all bits, as they would be stored or sent
+func (x Int) Bytes() int {
+	bytes := 0
+	for _, bit := range x {
+		bytes += 4 * (len(bit.A) + 1)
+	}
+	return bytes
+}
+
+func Encrypt(prv *gates.PrivateKey, value uint64, width int) Int {
+	x := make(Int, width)
+	for i := range x {
+		x[i] = prv.BootsSymEncrypt(int(value >> i & 1))
+	}
+	return x
+}
+
+func Decrypt(prv *gates.PrivateKey, x Int) uint64 {
+	var value uint64
+	for i, bit := range x {
+		value |= uint64(prv.BootsSymDecrypt(bit)) << i
+	}
+	return value
+}
+
+func DecryptBit(prv *gates.PrivateKey, bit *core.LweSample) bool {
+	return prv.BootsSymDecrypt(bit) == 1
+}
+
+// a trivial encryption of a public value, it can be mixed with encrypted integers
+func (c *Circuits) Constant(value uint64, width int) Int {
+	x := make(Int, width)
+	for i := range x {
+		x[i] = c.pub.Constant(value>>i&1 == 1)
+	}
+	return x
+}
+
+func (c *Circuits) gate(kind string, f func() *core.LweSample) *core.LweSample {
+	start := time.Now()
+	result := f()
+	s := c.Stats[kind]
+	s.Count++
+	s.Time += time.Since(start)
+	c.Stats[kind] = s
+	return result
+}
+
+func (c *Circuits) and(a, b *core.LweSample) *core.LweSample {
+	return c.gate("and", func() *core.LweSample { return c.pub.And(a, b) })
+}
+
+func (c *Circuits) xor(a, b *core.LweSample) *core.LweSample {
+	return c.gate("xor", func() *core.LweSample { return c.pub.Xor(a, b) })
+}
+
+func (c *Circuits) xnor(a, b *core.LweSample) *core.LweSample {
+	return c.gate("xnor", func() *core.LweSample { return c.pub.Xnor(a, b) })
+}
+
+// not(a) and b
+func (c *Circuits) andNY(a, b *core.LweSample) *core.LweSample {
+	return c.gate("andny", func() *core.LweSample { return c.pub.AndNY(a, b) })
+}
+
+// sel ? a : b
+func (c *Circuits) mux(sel, a, b *core.LweSample) *core.LweSample {
+	return c.gate("mux", func() *core.LweSample { return c.pub.Mux(sel, a, b) })
+}
+
+// both operands zero extended to the larger width
+func (c *Circuits) extend(a, b Int) (Int, Int) {
+	for len(a) < len(b) {
+		a = append(a[:len(a):len(a)], c.pub.Constant(false))
+	}
+	for len(b) < len(a) {
+		b = append(b[:len(b):len(b)], c.pub.Constant(false))
+	}
+	return a, b
+}
+
+// a + b and the carry out of the most significant bit, 3 gates per bit
+func (c *Circuits) Add(a, b Int) (Int, *core.LweSample) {
+	a, b = c.extend(a, b)
+	sum := make(Int, len(a))
+	carry := c.pub.Constant(false)
+	for i := range a {
+		t := c.xor(a[i], b[i])
+		if i == 0 {
+			sum[i] = t
+			carry = c.and(a[i], b[i])
+			continue
+		}
+		sum[i] = c.xor(t, carry)
+		//if the bits differ the carry passes through, otherwise it is their common value
+		carry = c.mux(t, carry, a[i])
+	}
+	return sum, carry
+}
+
+// a - b and the borrow out of the most significant bit, which is set if a < b, 3 gates per bit
+func (c *Circuits) Subtract(a, b Int) (Int, *core.LweSample) {
+	a, b = c.extend(a, b)
+	diff := make(Int, len(a))
+	borrow := c.pub.Constant(false)
+	for i := range a {
+		t := c.xor(a[i], b[i])
+		if i == 0 {
+			diff[i] = t
+			borrow = c.andNY(a[i], b[i])
+			continue
+		}
+		diff[i] = c.xor(t, borrow)
+		//if the bits differ b decides, otherwise the borrow passes through
+		borrow = c.mux(t, b[i], borrow)
+	}
+	return diff, borrow
+}
+
+// the borrow chain of a - b without the difference, 2 gates per bit
+func (c *Circuits) LessThan(a, b Int) *core.LweSample {
+	a, b = c.extend(a, b)
+	borrow := c.pub.Constant(false)
+	for i := range a {
+		if i == 0 {
+			borrow = c.andNY(a[i], b[i])
+			continue
+		}
+		borrow = c.mux(c.xor(a[i], b[i]), b[i], borrow)
+	}
+	return borrow
+}
+
+func (c *Circuits) GreaterThan(a, b Int) *core.LweSample {
+	return c.LessThan(b, a)
+}
+
+func (c *Circuits) LessOrEqual(a, b Int) *core.LweSample {
+	return c.pub.Not(c.LessThan(b, a))
+}
+
+func (c *Circuits) GreaterOrEqual(a, b Int) *core.LweSample {
+	return c.pub.Not(c.LessThan(a, b))
+}
+
+// 2 gates per bit
+func (c *Circuits) Equal(a, b Int) *core.LweSample {
+	a, b = c.extend(a, b)
+	equal := c.pub.Constant(true)
+	for i := range a {
+		same := c.xnor(a[i], b[i])
+		if i == 0 {
+			equal = same
+			continue
+		}
+		equal = c.and(equal, same)
+	}
+	return equal
+}
+
+// sel ? a : b bit by bit, 1 gate per bit
+func (c *Circuits) Mux(sel *core.LweSample, a, b Int) Int {
+	a, b = c.extend(a, b)
+	result := make(Int, len(a))
+	for i := range a {
+		result[i] = c.mux(sel, a[i], b[i])
+	}
+	return result
+}
+
+// 3 gates per bit
+func (c *Circuits) Min(a, b Int) Int {
+	return c.Mux(c.LessThan(a, b), a, b)
+}
+
+// 3 gates per bit
+func (c *Circuits) Max(a, b Int) Int {
+	return c.Mux(c.LessThan(a, b), b, a)
+}
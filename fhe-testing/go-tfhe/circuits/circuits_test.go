@@ -0,0 +1,131 @@
+package circuits
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/thedonutfactory/go-tfhe/core"
+	"github.com/thedonutfactory/go-tfhe/gates"
+)
+
+func bit(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func checkPair(t *testing.T, c *Circuits, prv *gates.PrivateKey, a, b uint64, widthA, widthB int) {
+	width := max(widthA, widthB)
+	mask := uint64(1)<<width - 1
+	x, y := Encrypt(prv, a, widthA), Encrypt(prv, b, widthB)
+
+	sum, carry := c.Add(x, y)
+	diff, borrow := c.Subtract(x, y)
+	results := []struct {
+		name      string
+		got, want uint64
+	}{
+		{"add", Decrypt(prv, sum), (a + b) & mask},
+		{"add carry", bit(DecryptBit(prv, carry)), bit(a+b > mask)},
+		{"subtract", Decrypt(prv, diff), (a - b) & mask},
+		{"subtract borrow", bit(DecryptBit(prv, borrow)), bit(a < b)},
+		{"less than", bit(DecryptBit(prv, c.LessThan(x, y))), bit(a < b)},
+		{"greater than", bit(DecryptBit(prv, c.GreaterThan(x, y))), bit(a > b)},
+		{"less or equal", bit(DecryptBit(prv, c.LessOrEqual(x, y))), bit(a <= b)},
+		{"greater or equal", bit(DecryptBit(prv, c.GreaterOrEqual(x, y))), bit(a >= b)},
+		{"equal", bit(DecryptBit(prv, c.Equal(x, y))), bit(a == b)},
+		{"min", Decrypt(prv, c.Min(x, y)), min(a, b)},
+		{"max", Decrypt(prv, c.Max(x, y)), max(a, b)},
+	}
+	for _, r := range results {
+		if r.got != r.want {
+			t.Errorf("%s of %d (%d bits) and %d (%d bits): got %d, want %d", r.name, a, widthA, b, widthB, r.got, r.want)
+		}
+	}
+}
+
+// the test parameters are insecure but fast, the circuits do not depend on them
+func TestCircuits(t *testing.T) {
+	pub, prv := gates.TestGateBootstrappingParameters().GenerateKeys()
+	c := New(pub)
+
+	//all pairs of 2 bit values, every gate is bootstrapped so the tests take a while even at the test parameters
+	for a := range uint64(4) {
+		for b := range uint64(4) {
+			checkPair(t, c, prv, a, b, 2, 2)
+		}
+	}
+	//wider values and operands of different widths
+	for range 2 {
+		a, b := rand.Uint64()&0xffff, rand.Uint64()&0xffff
+		checkPair(t, c, prv, a, b, 16, 16)
+		checkPair(t, c, prv, a&0xff, b, 8, 16)
+	}
+	checkPair(t, c, prv, 0xffff, 1, 16, 1)
+
+	x, y := Encrypt(prv, 5, 4), Encrypt(prv, 9, 4)
+	for sel, want := range map[bool]uint64{true: 5, false: 9} {
+		if got := Decrypt(prv, c.Mux(c.Constant(bit(sel), 1)[0], x, y)); got != want {
+			t.Errorf("mux with %v: got %d, want %d", sel, got, want)
+		}
+	}
+	if got := Decrypt(prv, c.Mux(c.Equal(x, c.Constant(5, 4)), y, x)); got != 9 {
+		t.Errorf("mux on an encrypted condition: got %d, want 9", got)
+	}
+
+	c.Reset()
+	c.Add(x, y)
+	if c.Gates() != 3*4-1 {
+		t.Errorf("a 4 bit addition took %d gates, want %d", c.Gates(), 3*4-1)
+	}
+}
+
+// the time of a single bootstrapped gate at the default 128 bit parameters, every circuit is a sequence of them
+func BenchmarkGates(b *testing.B) {
+	pub, prv := gates.DefaultGateBootstrappingParameters(100).GenerateKeys()
+	c := New(pub)
+	x, y, z := prv.BootsSymEncrypt(1), prv.BootsSymEncrypt(0), prv.BootsSymEncrypt(1)
+	for _, g := range []struct {
+		name string
+		f    func() *core.LweSample
+	}{
+		{"and", func() *core.LweSample { return c.and(x, y) }},
+		{"xor", func() *core.LweSample { return c.xor(x, y) }},
+		{"xnor", func() *core.LweSample { return c.xnor(x, y) }},
+		{"andny", func() *core.LweSample { return c.andNY(x, y) }},
+		{"mux", func() *core.LweSample { return c.mux(x, y, z) }},
+	} {
+		b.Run(g.name, func(b *testing.B) {
+			for range b.N {
+				g.f()
+			}
+		})
+	}
+}
+
+// the gates and the time of the circuits on 8 bit values at the default parameters
+func BenchmarkCircuits(b *testing.B) {
+	pub, prv := gates.DefaultGateBootstrappingParameters(100).GenerateKeys()
+	c := New(pub)
+	x, y := Encrypt(prv, 200, 8), Encrypt(prv, 57, 8)
+	for _, op := range []struct {
+		name string
+		f    func()
+	}{
+		{"add", func() { c.Add(x, y) }},
+		{"subtract", func() { c.Subtract(x, y) }},
+		{"less", func() { c.LessThan(x, y) }},
+		{"equal", func() { c.Equal(x, y) }},
+		{"max", func() { c.Max(x, y) }},
+	} {
+		b.Run(fmt.Sprintf("%s-8bit", op.name), func(b *testing.B) {
+			c.Reset()
+			for range b.N {
+				op.f()
+			}
+			b.ReportMetric(float64(c.Gates())/float64(b.N), "gates/op")
+		})
+	}
+}
@@ -15,3 +15,7 @@ require (
 	golang.org/x/exp v0.0.0-20210729172720-737cce5152fc // indirect
 	gonum.org/v1/gonum v0.9.3 // indirect
 )
+
+require benchmarkcsv v0.0.0
+
+replace benchmarkcsv => ../benchmarkcsv
@@ -1,59 +1,69 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"math/rand"
+	"os"
+	"slices"
+	"text/tabwriter"
 	"time"
 
+	"main/circuits"
+
 	"github.com/thedonutfactory/go-tfhe/gates"
 )
 
 func main() {
+	width := flag.Int("bits", 8, "width of the encrypted integers")
+	flag.Parse()
+
 	start := time.Now()
 	// generate public and private keys
-	fmt.Println("generating ctx")
-	ctx := gates.DefaultGateBootstrappingParameters(100)
 	fmt.Println("generating keys")
-	pub, prv := ctx.GenerateKeys()
-
-	fmt.Printf("\ndone (%v)\n", time.Since(start))
-	start = time.Now()
-
-	fmt.Println("encrypting")
-
-	// encrypt 2 8-bit ciphertexts
-	x := prv.Encrypt(int8(22))
-	y := prv.Encrypt(int8(33))
-
-	fmt.Printf("\ndone (%v)\n", time.Since(start))
-	start = time.Now()
-
-	// perform homomorphic sum gate operations
-	BITS := 8
-	temp := ctx.Int(3)
-	sum := ctx.Int(9)
-	carry := ctx.Int2()
-	fmt.Println("sum gate operations")
-	for i := 0; i < BITS; i++ {
-		//sumi = xi XOR yi XOR carry(i-1)
-		temp[0] = pub.Xor(x[i], y[i]) // temp = xi XOR yi
-		sum[i] = pub.Xor(temp[0], carry[0])
-
-		// carry = (xi AND yi) XOR (carry(i-1) AND (xi XOR yi))
-		temp[1] = pub.And(x[i], y[i])
-		temp[2] = pub.And(carry[0], temp[0])
-		carry[1] = pub.Xor(temp[1], temp[2])
-		carry[0] = pub.Copy(carry[1])
-		fmt.Printf("\ndone one bit (%v)\n", time.Since(start))
-		start = time.Now()
+	pub, prv := gates.DefaultGateBootstrappingParameters(100).GenerateKeys()
+	fmt.Printf("done (%v)\n", time.Since(start))
+
+	mask := uint64(1)<<*width - 1
+	a, b := rand.Uint64()&mask, rand.Uint64()&mask
+	x, y := circuits.Encrypt(prv, a, *width), circuits.Encrypt(prv, b, *width)
+	c := circuits.New(pub)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(w, "operation\tresult\tplaintext\tgates\ttime\ttime per gate\t\n")
+	run := func(name string, want uint64, f func() uint64) {
+		before := c.Gates()
+		start := time.Now()
+		got := f()
+		elapsed := time.Since(start)
+		count := c.Gates() - before
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%v\t%v\t\n", name, got, want, count, elapsed.Round(time.Millisecond), (elapsed / time.Duration(count)).Round(time.Microsecond))
+	}
+	bit := func(b bool) uint64 {
+		if b {
+			return 1
+		}
+		return 0
 	}
-	sum[BITS] = pub.Copy(carry[0])
 
-	fmt.Printf("\ndone (%v)\n", time.Since(start))
-	start = time.Now()
-	fmt.Println("decryption")
+	fmt.Printf("\n%d bit operations on %d and %d\n", *width, a, b)
+	run("add", (a+b)&mask, func() uint64 { sum, _ := c.Add(x, y); return circuits.Decrypt(prv, sum) })
+	run("subtract", (a-b)&mask, func() uint64 { diff, _ := c.Subtract(x, y); return circuits.Decrypt(prv, diff) })
+	run("less than", bit(a < b), func() uint64 { return bit(circuits.DecryptBit(prv, c.LessThan(x, y))) })
+	run("equal", bit(a == b), func() uint64 { return bit(circuits.DecryptBit(prv, c.Equal(x, y))) })
+	run("min", min(a, b), func() uint64 { return circuits.Decrypt(prv, c.Min(x, y)) })
+	run("max", max(a, b), func() uint64 { return circuits.Decrypt(prv, c.Max(x, y)) })
+	run("mux", a, func() uint64 { return circuits.Decrypt(prv, c.Mux(prv.BootsSymEncrypt(1), x, y)) })
+	w.Flush()
 
-	// decrypt results
-	z := prv.Decrypt(sum[:])
-	fmt.Printf("\ndone (%v)\n", time.Since(start))
-	fmt.Println("The sum of of x and y: ", z)
+	// time per kind of gate over all operations above
+	fmt.Println("\ntime per bootstrapped gate")
+	kinds := []string{}
+	for kind := range c.Stats {
+		kinds = append(kinds, kind)
+	}
+	slices.Sort(kinds)
+	for _, kind := range kinds {
+		fmt.Printf("%-6s %4d gates  %v\n", kind, c.Stats[kind].Count, c.Stats[kind].PerGate().Round(time.Microsecond))
+	}
 }
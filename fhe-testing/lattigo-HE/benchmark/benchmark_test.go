@@ -0,0 +1,238 @@
+package benchmark
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"benchmarkcsv"
+	"main/comparison"
+	"main/summation"
+
+	"github.com/ldsec/lattigo/v2/bfv"
+)
+
+// the same workloads as fhe-testing/go-tfhe/benchmark, written in the layout of scheme-benchmarking/Results
+// go test -run CSV -results ../../../scheme-benchmarking/Results -params PN13QP218,PN14QP438 -bits 3 -sizes 1,100,10000
+var (
+	sizesFlag   = flag.String("sizes", "1,10,100,1000,10000", "comma separated numbers of encrypted values")
+	paramsFlag  = flag.String("params", "PN14QP438", "comma separated BFV parameter sets, PN12QP109, PN13QP218 or PN14QP438")
+	bitsFlag    = flag.Int("bits", 8, "bits of the compared values, at most 3 with PN13QP218 and 1 with PN12QP109")
+	runsFlag    = flag.Int("runs", 3, "runs averaged for every value in the CSVs")
+	resultsFlag = flag.String("results", "", "folder with the performance and storage results, TestCSV is skipped without it")
+)
+
+var parameterSets = map[string]bfv.ParametersLiteral{
+	"PN12QP109": bfv.PN12QP109,
+	"PN13QP218": bfv.PN13QP218,
+	"PN14QP438": bfv.PN14QP438,
+}
+
+type bfvSetup struct {
+	summer   *summation.Summer
+	comparer *comparison.Context
+}
+
+func newSetup(name string) (*bfvSetup, error) {
+	literal, found := parameterSets[name]
+	if !found {
+		return nil, fmt.Errorf("unknown parameter set %s", name)
+	}
+	comparer, err := comparison.NewContext(literal, *bitsFlag)
+	if err != nil {
+		return nil, err
+	}
+	//sums need the larger plaintext modulus of the summation package, it has to be congruent 1 modulo 2N
+	literal.T = summation.DefaultParameters.T
+	if (literal.T-1)%(2<<literal.LogN) != 0 {
+		return nil, fmt.Errorf("the summation modulus %d can not be used with %s", literal.T, name)
+	}
+	summer, err := summation.NewSummer(literal)
+	if err != nil {
+		return nil, err
+	}
+	return &bfvSetup{summer, comparer}, nil
+}
+
+func randomValues(n int) []uint64 {
+	values := make([]uint64, n)
+	for i := range values {
+		values[i] = uint64(rand.Intn(1 << *bitsFlag))
+	}
+	return values
+}
+
+func (s *bfvSetup) sum(values []uint64) (benchmarkcsv.Measurement, error) {
+	result, err := s.summer.SumPacked(values)
+	if err != nil {
+		return benchmarkcsv.Measurement{}, err
+	}
+	var want uint64
+	for _, v := range values {
+		want += v
+	}
+	if result.Sum != want {
+		return benchmarkcsv.Measurement{}, fmt.Errorf("sum %d, want %d", result.Sum, want)
+	}
+	return benchmarkcsv.Measurement{Encrypt: result.Encrypt, Evaluate: result.Aggregate, Bytes: result.CiphertextBytes}, nil
+}
+
+// the values in chunks of at most N, one encrypted vector per chunk as a vector only fills the slots of one ciphertext
+func chunks(values []uint64, slots int) [][]uint64 {
+	out := [][]uint64{}
+	for i := 0; i < len(values); i += slots {
+		out = append(out, values[i:min(i+slots, len(values))])
+	}
+	return out
+}
+
+func (s *bfvSetup) encrypt(values []uint64) ([]*comparison.EncryptedVector, int, error) {
+	vectors := []*comparison.EncryptedVector{}
+	bytes := 0
+	for _, chunk := range chunks(values, s.comparer.Params.N()) {
+		vector, err := s.comparer.EncryptVector(chunk)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, ct := range vector.Bits {
+			bytes += ct.GetDataLen(true)
+		}
+		vectors = append(vectors, vector)
+	}
+	return vectors, bytes, nil
+}
+
+func (s *bfvSetup) countAbove(values []uint64) (benchmarkcsv.Measurement, error) {
+	threshold := uint64(1) << (*bitsFlag - 1)
+	start := time.Now()
+	vectors, bytes, err := s.encrypt(values)
+	if err != nil {
+		return benchmarkcsv.Measurement{}, err
+	}
+	thresholds := make([]*comparison.EncryptedVector, len(vectors))
+	for i, vector := range vectors {
+		if thresholds[i], err = s.comparer.EncryptThreshold(threshold, vector.Len); err != nil {
+			return benchmarkcsv.Measurement{}, err
+		}
+	}
+	encrypt := time.Since(start)
+
+	start = time.Now()
+	counts := make([]*bfv.Ciphertext, len(vectors))
+	for i, vector := range vectors {
+		if counts[i], err = s.comparer.CountAbove(vector, thresholds[i]); err != nil {
+			return benchmarkcsv.Measurement{}, err
+		}
+	}
+	evaluate := time.Since(start)
+
+	//the counts of the chunks are added after decryption, the plaintext modulus is only checked to hold N
+	var got, want uint64
+	for _, count := range counts {
+		got += s.comparer.DecryptCount(count)
+	}
+	for _, v := range values {
+		if v > threshold {
+			want++
+		}
+	}
+	if got != want {
+		return benchmarkcsv.Measurement{}, fmt.Errorf("count %d, want %d, the parameters may be too small for %d bits", got, want, *bitsFlag)
+	}
+	return benchmarkcsv.Measurement{Encrypt: encrypt, Evaluate: evaluate, Bytes: bytes}, nil
+}
+
+func (s *bfvSetup) compare(values []uint64) (benchmarkcsv.Measurement, error) {
+	others := randomValues(len(values))
+	start := time.Now()
+	a, bytes, err := s.encrypt(values)
+	if err != nil {
+		return benchmarkcsv.Measurement{}, err
+	}
+	b, _, err := s.encrypt(others)
+	if err != nil {
+		return benchmarkcsv.Measurement{}, err
+	}
+	encrypt := time.Since(start)
+
+	start = time.Now()
+	greater := make([]*bfv.Ciphertext, len(a))
+	for i := range a {
+		if greater[i], err = s.comparer.GreaterThan(a[i], b[i]); err != nil {
+			return benchmarkcsv.Measurement{}, err
+		}
+	}
+	evaluate := time.Since(start)
+
+	decrypted := []uint64{}
+	for i, ct := range greater {
+		decrypted = append(decrypted, s.comparer.Decrypt(ct, a[i].Len)...)
+	}
+	for i, got := range decrypted {
+		if (got == 1) != (values[i] > others[i]) {
+			return benchmarkcsv.Measurement{}, fmt.Errorf("%d > %d decrypted to %d, the parameters may be too small for %d bits", values[i], others[i], got, *bitsFlag)
+		}
+	}
+	return benchmarkcsv.Measurement{Encrypt: encrypt, Evaluate: evaluate, Bytes: bytes}, nil
+}
+
+var workloads = []benchmarkcsv.Workload[*bfvSetup]{
+	{Name: "sum", Run: (*bfvSetup).sum},
+	{Name: "count above", Run: (*bfvSetup).countAbove},
+	{Name: "compare", Run: (*bfvSetup).compare},
+}
+
+// the evaluation time of every workload, without encryption, with the size of the encrypted inputs
+func BenchmarkWorkloads(b *testing.B) {
+	sizes, err := benchmarkcsv.ParseSizes(*sizesFlag)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, name := range strings.Split(*paramsFlag, ",") {
+		s, err := newSetup(name)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, w := range workloads {
+			for _, n := range sizes {
+				b.Run(fmt.Sprintf("%s/%s/Values_%d", name, strings.ReplaceAll(w.Name, " ", "_"), n), func(b *testing.B) {
+					values := randomValues(n)
+					var evaluate time.Duration
+					var m benchmarkcsv.Measurement
+					for range b.N {
+						if m, err = w.Run(s, values); err != nil {
+							b.Fatal(err)
+						}
+						evaluate += m.Evaluate
+					}
+					b.ReportMetric(float64(evaluate.Nanoseconds())/float64(b.N), "eval-ns/op")
+					b.ReportMetric(float64(m.Bytes), "ct-bytes")
+				})
+			}
+		}
+	}
+}
+
+// performance/lattigo_bfv_<params>.csv with the seconds to encrypt and evaluate every workload,
+// storage/lattigo_bfv_<params>_ct.csv with the bytes of the encrypted inputs
+func TestCSV(t *testing.T) {
+	if *resultsFlag == "" {
+		t.Skip("set -results to write the benchmark CSVs")
+	}
+	sizes, err := benchmarkcsv.ParseSizes(*sizesFlag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range strings.Split(*paramsFlag, ",") {
+		s, err := newSetup(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := benchmarkcsv.WriteResults(*resultsFlag, "lattigo_bfv_"+strings.ToLower(name), s, workloads, sizes, *runsFlag, randomValues); err != nil {
+			t.Fatalf("%s %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+/*
+
+Encrypted comparisons over BFV by bit decomposition
+Every value is split into its bits and bit i of all values is packed into the slots of one ciphertext, so a comparison
+compares up to N values at once. For bits a_i and b_i (least significant first) a > b is
+
+	sum_i a_i (1 - b_i) * prod_{j > i} (1 - (a_j - b_j)^2)
+
+which is exact in Z_t as long as every factor is 0 or 1. The products over the higher bits are computed as a prefix
+product over the bits - 1 higher bits in log2(bits - 1) rounds, so the multiplicative depth is log2(bits - 1) + 2 (rounded up)
+
+*/
+
+package comparison
+
+import (
+	"fmt"
+	"math/bits"
+
+	"github.com/ldsec/lattigo/v2/bfv"
+	"github.com/ldsec/lattigo/v2/rlwe"
+)
+
+// enough depth for comparisons of 32 bit values and counting them, PN13QP218 only supports 3 bits and PN12QP109 a single bit
+var DefaultParameters = bfv.PN14QP438
+
+type Context struct {
+	Params    bfv.Parameters
+	Bits      int
+	encoder   bfv.Encoder
+	encryptor bfv.Encryptor
+	decryptor bfv.Decryptor
+	evaluator bfv.Evaluator
+	one       *bfv.Plaintext
+}
+
+// the bits of a vector of values, bit i of value k is slot k of Bits[i]
+type EncryptedVector struct {
+	Bits []*bfv.Ciphertext
+	Len  int
+}
+
+// keys for values below 2^bits, including the relinearization key and the rotation keys for counting
+// the plaintext modulus has to be larger than the number of slots, so counts do not wrap around
+func NewContext(literal bfv.ParametersLiteral, bits int) (*Context, error) {
+	params, err := bfv.NewParametersFromLiteral(literal)
+	if err != nil {
+		return nil, err
+	}
+	if bits < 1 || bits > 32 {
+		return nil, fmt.Errorf("values must have between 1 and 32 bits, not %d", bits)
+	}
+	if depth(bits) > maxDepth(params) {
+		return nil, fmt.Errorf("comparing %d bit values needs depth %d, the parameters only support %d", bits, depth(bits), maxDepth(params))
+	}
+	if params.T() <= uint64(params.N()) {
+		return nil, fmt.Errorf("plaintext modulus %d is too small to count %d slots", params.T(), params.N())
+	}
+
+	kgen := bfv.NewKeyGenerator(params)
+	sk, pk := kgen.GenKeyPair()
+	rlk := kgen.GenRelinearizationKey(sk, 1)
+	rotations := kgen.GenRotationKeysForInnerSum(sk)
+
+	c := &Context{
+		Params:    params,
+		Bits:      bits,
+		encoder:   bfv.NewEncoder(params),
+		encryptor: bfv.NewEncryptor(params, pk),
+		decryptor: bfv.NewDecryptor(params, sk),
+		evaluator: bfv.NewEvaluator(params, rlwe.EvaluationKey{Rlk: rlk, Rtks: rotations}),
+	}
+	c.one = c.encode(fill(params.N(), 1))
+	return c, nil
+}
+
+// the multiplicative depth of GreaterThan, one multiplication for the bits, the prefix product and one to combine them
+func depth(n int) int {
+	if n == 1 {
+		return 1
+	}
+	return 2 + bits.Len(uint(n-2))
+}
+
+// an estimate of the depth the noise budget allows, checked against the lattigo default parameters
+// every multiplication costs about log2(t) + log2(N) bits of the ciphertext modulus and decryption needs log2(t) + 10 bits,
+// adding up the N slots of a count multiplies the noise by up to N, which needs another log2(N) + 1 bits
+func maxDepth(params bfv.Parameters) int {
+	logT := bits.Len64(params.T())
+	return (params.LogQ() - logT - 10 - params.LogN() - 1) / (logT + params.LogN() + 1)
+}
+
+func fill(n int, value uint64) []uint64 {
+	values := make([]uint64, n)
+	for i := range values {
+		values[i] = value
+	}
+	return values
+}
+
+func (c *Context) encode(values []uint64) *bfv.Plaintext {
+	pt := bfv.NewPlaintext(c.Params)
+	c.encoder.EncodeUint(values, pt)
+	return pt
+}
+
+// encrypt values below 2^Bits, one per slot
+func (c *Context) EncryptVector(values []uint64) (*EncryptedVector, error) {
+	if len(values) > c.Params.N() {
+		return nil, fmt.Errorf("%d values do not fit into %d slots", len(values), c.Params.N())
+	}
+	for _, v := range values {
+		if v>>c.Bits != 0 {
+			return nil, fmt.Errorf("%d does not fit into %d bits", v, c.Bits)
+		}
+	}
+
+	vector := &EncryptedVector{Len: len(values)}
+	for i := 0; i < c.Bits; i++ {
+		bits := make([]uint64, len(values))
+		for k, v := range values {
+			bits[k] = (v >> i) & 1
+		}
+		vector.Bits = append(vector.Bits, c.encryptor.EncryptNew(c.encode(bits)))
+	}
+	return vector, nil
+}
+
+// the same value in the first n slots, to compare a vector of length n against
+// the remaining slots stay 0, so they are neither above nor below the threshold
+func (c *Context) EncryptThreshold(threshold uint64, n int) (*EncryptedVector, error) {
+	return c.EncryptVector(fill(n, threshold))
+}
+
+func (c *Context) mul(a *bfv.Ciphertext, b *bfv.Ciphertext) *bfv.Ciphertext {
+	product := c.evaluator.MulNew(a, b)
+	c.evaluator.Relinearize(product, product)
+	return product
+}
+
+// 1 in every slot where a is larger than b, 0 otherwise
+func (c *Context) GreaterThan(a *EncryptedVector, b *EncryptedVector) (*bfv.Ciphertext, error) {
+	if len(a.Bits) != c.Bits || len(b.Bits) != c.Bits {
+		return nil, fmt.Errorf("vectors with %d and %d bits can not be compared with a %d bit context", len(a.Bits), len(b.Bits), c.Bits)
+	}
+
+	//a_i (1 - b_i) is 1 where bit i decides for a, (1 - (a_i - b_i)^2) is 1 where the bits are equal
+	greater := make([]*bfv.Ciphertext, c.Bits)
+	equal := make([]*bfv.Ciphertext, c.Bits)
+	for i := range c.Bits {
+		greater[i] = c.evaluator.SubNew(a.Bits[i], c.mul(a.Bits[i], b.Bits[i]))
+		diff := c.evaluator.SubNew(a.Bits[i], b.Bits[i])
+		equal[i] = c.evaluator.SubNew(c.one, c.mul(diff, diff))
+	}
+
+	//higher[m] is the product of the equalities of the m+1 highest bits, computed as a parallel prefix product
+	higher := make([]*bfv.Ciphertext, c.Bits-1)
+	for m := range higher {
+		higher[m] = equal[c.Bits-1-m]
+	}
+	for d := 1; d < len(higher); d *= 2 {
+		next := make([]*bfv.Ciphertext, len(higher))
+		copy(next, higher)
+		for m := d; m < len(higher); m++ {
+			next[m] = c.mul(higher[m], higher[m-d])
+		}
+		higher = next
+	}
+
+	//the highest bit decides if it differs, every lower bit only if all bits above it are equal
+	result := greater[c.Bits-1].CopyNew()
+	for i := c.Bits - 2; i >= 0; i-- {
+		c.evaluator.Add(result, c.mul(greater[i], higher[c.Bits-2-i]), result)
+	}
+	return result, nil
+}
+
+// the number of values above the threshold, in every slot of the result
+func (c *Context) CountAbove(values *EncryptedVector, threshold *EncryptedVector) (*bfv.Ciphertext, error) {
+	return c.count(values, threshold)
+}
+
+// the number of values below the threshold, in every slot of the result
+func (c *Context) CountBelow(values *EncryptedVector, threshold *EncryptedVector) (*bfv.Ciphertext, error) {
+	return c.count(threshold, values)
+}
+
+func (c *Context) count(a *EncryptedVector, b *EncryptedVector) (*bfv.Ciphertext, error) {
+	if a.Len != b.Len {
+		return nil, fmt.Errorf("vectors of length %d and %d can not be compared", a.Len, b.Len)
+	}
+	indicator, err := c.GreaterThan(a, b)
+	if err != nil {
+		return nil, err
+	}
+	count := bfv.NewCiphertext(c.Params, 1)
+	c.evaluator.InnerSum(indicator, count)
+	return count, nil
+}
+
+// the first n slots of a ciphertext
+func (c *Context) Decrypt(ct *bfv.Ciphertext, n int) []uint64 {
+	slots := make([]uint64, c.Params.N())
+	c.encoder.DecodeUint(c.decryptor.DecryptNew(ct), slots)
+	return slots[:n]
+}
+
+func (c *Context) DecryptCount(ct *bfv.Ciphertext) uint64 {
+	return c.Decrypt(ct, 1)[0]
+}
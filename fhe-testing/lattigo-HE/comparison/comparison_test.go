@@ -0,0 +1,143 @@
+package comparison
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/ldsec/lattigo/v2/bfv"
+)
+
+func TestGreaterThanAllPairs(t *testing.T) {
+	c, err := NewContext(bfv.PN13QP218, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//every pair of 3 bit values
+	a, b := []uint64{}, []uint64{}
+	for x := range uint64(8) {
+		for y := range uint64(8) {
+			a, b = append(a, x), append(b, y)
+		}
+	}
+	encA, _ := c.EncryptVector(a)
+	encB, _ := c.EncryptVector(b)
+	greater, err := c.GreaterThan(encA, encB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, got := range c.Decrypt(greater, len(a)) {
+		want := uint64(0)
+		if a[k] > b[k] {
+			want = 1
+		}
+		if got != want {
+			t.Errorf("%d > %d gave %d", a[k], b[k], got)
+		}
+	}
+}
+
+func TestCounts(t *testing.T) {
+	c, err := NewContext(DefaultParameters, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := make([]uint64, 1000)
+	for i := range values {
+		values[i] = uint64(rand.Intn(256))
+	}
+	encrypted, err := c.EncryptVector(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, threshold := range []uint64{0, 50, 128, 255} {
+		var above, below uint64
+		for _, v := range values {
+			if v > threshold {
+				above++
+			}
+			if v < threshold {
+				below++
+			}
+		}
+
+		encThreshold, _ := c.EncryptThreshold(threshold, len(values))
+		countAbove, err := c.CountAbove(encrypted, encThreshold)
+		if err != nil {
+			t.Fatal(err)
+		}
+		countBelow, err := c.CountBelow(encrypted, encThreshold)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := c.DecryptCount(countAbove); got != above {
+			t.Errorf("%d values above %d, got %d", above, threshold, got)
+		}
+		if got := c.DecryptCount(countBelow); got != below {
+			t.Errorf("%d values below %d, got %d", below, threshold, got)
+		}
+	}
+}
+
+// the largest values each parameter set supports still compare and count correctly, one bit more is rejected
+func TestBitLimits(t *testing.T) {
+	for _, tc := range []struct {
+		literal bfv.ParametersLiteral
+		bits    int
+	}{{bfv.PN12QP109, 1}, {bfv.PN13QP218, 3}, {DefaultParameters, 32}} {
+		c, err := NewContext(tc.literal, tc.bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		max := uint64(1)<<tc.bits - 1
+		a, _ := c.EncryptVector([]uint64{max, max - 1, 0, max, 1})
+		b, _ := c.EncryptVector([]uint64{max - 1, max, max, 0, 0})
+		greater, err := c.GreaterThan(a, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := c.Decrypt(greater, 5); !slices.Equal(got, []uint64{1, 0, 0, 1, 1}) {
+			t.Errorf("%d bits with N = %d: got %v", tc.bits, c.Params.N(), got)
+		}
+		count, err := c.CountAbove(a, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := c.DecryptCount(count); got != 3 {
+			t.Errorf("%d bits with N = %d: counted %d", tc.bits, c.Params.N(), got)
+		}
+
+		if tc.bits < 32 {
+			if _, err := NewContext(tc.literal, tc.bits+1); err == nil {
+				t.Errorf("%d bits should be rejected with N = %d", tc.bits+1, c.Params.N())
+			}
+		}
+	}
+}
+
+func TestInvalidInputs(t *testing.T) {
+	c, err := NewContext(bfv.PN13QP218, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.EncryptVector([]uint64{8}); err == nil {
+		t.Error("values with more bits than the context should be rejected")
+	}
+	if _, err := c.EncryptVector(make([]uint64, c.Params.N()+1)); err == nil {
+		t.Error("more values than slots should be rejected")
+	}
+	a, _ := c.EncryptVector([]uint64{1, 2})
+	b, _ := c.EncryptThreshold(1, 3)
+	if _, err := c.CountAbove(a, b); err == nil {
+		t.Error("vectors of different length should be rejected")
+	}
+	if _, err := NewContext(bfv.PN12QP109, 0); err == nil {
+		t.Error("values need at least one bit")
+	}
+	if _, err := NewContext(DefaultParameters, 33); err == nil {
+		t.Error("values have at most 32 bits")
+	}
+}
@@ -8,3 +8,7 @@ require (
 	golang.org/x/crypto v0.0.0-20210921155107-089bfa567519 // indirect
 	golang.org/x/sys v0.0.0-20211007075335-d3039528d8ac // indirect
 )
+
+require benchmarkcsv v0.0.0
+
+replace benchmarkcsv => ../benchmarkcsv
@@ -1,69 +1,77 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"math/rand"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"text/tabwriter"
 	"time"
 
-	"github.com/ldsec/lattigo/v2/bfv"
-	"github.com/ldsec/lattigo/v2/rlwe"
+	"main/summation"
 )
 
+// compare the per-value and the packed BFV summation for growing numbers of values
+// go run ./large -sizes 1000,10000,100000,1000000 -per-value-limit 1000000
 func main() {
-	// 1. Parameters setup (128-bit security)
-	params, err := bfv.NewParametersFromLiteral(bfv.PN12QP109)
+	sizes := flag.String("sizes", "1000,10000,100000,1000000", "comma separated numbers of values")
+	perValueLimit := flag.Int("per-value-limit", 100000, "largest number of values summed one ciphertext per value, it takes one to two milliseconds per value")
+	flag.Parse()
+
+	// 1. Parameters setup (128-bit security) with a plaintext modulus for sums of 10^6 values
+	s, err := summation.NewSummer(summation.DefaultParameters)
 	if err != nil {
 		panic(err)
 	}
 
-	// 2. Key generation
-	kgen := bfv.NewKeyGenerator(params)
-	sk := kgen.GenSecretKey()
-	pk := kgen.GenPublicKey(sk)
-	rlk := kgen.GenRelinearizationKey(sk, 1)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "approach\tvalues\tciphertexts\tciphertext MB\tallocated MB\tencrypt\taggregate\tmatch\t")
 
-	// 3. Create primitives
-	encoder := bfv.NewEncoder(params)
-	encryptor := bfv.NewEncryptor(params, pk)
-	decryptor := bfv.NewDecryptor(params, sk)
-	evaluator := bfv.NewEvaluator(params, rlwe.EvaluationKey{Rlk: rlk})
+	for _, field := range strings.Split(*sizes, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || n < 1 {
+			fmt.Fprintf(os.Stderr, "invalid size %q\n", field)
+			os.Exit(1)
+		}
 
-	// 4. Generate 50 random numbers (0-99)
-	rand.Seed(time.Now().UnixNano())
-	data := make([]uint64, 1000)
-	for i := range data {
-		data[i] = uint64(rand.Intn(100))
-	}
+		// 2. Generate n random numbers (0-99)
+		data := make([]uint64, n)
+		var actualSum uint64
+		for i := range data {
+			data[i] = uint64(rand.Intn(100))
+			actualSum += data[i]
+		}
 
-	// 5. Encrypt each number individually (simple approach)
-	ciphertexts := make([]*bfv.Ciphertext, len(data))
-	for i, num := range data {
-		pt := bfv.NewPlaintext(params)
-		encoder.EncodeUint([]uint64{num}, pt)
-		ciphertexts[i] = encryptor.EncryptNew(pt)
-	}
+		// 3. Homomorphic summation with both approaches
+		for _, approach := range []struct {
+			name string
+			sum  func([]uint64) (summation.Result, error)
+		}{{"per-value", s.SumPerValue}, {"packed", s.SumPacked}} {
+			if approach.name == "per-value" && n > *perValueLimit {
+				fmt.Fprintf(w, "%s\t%d\tskipped\t\t\t\t\t\t\n", approach.name, n)
+				continue
+			}
 
-	// 6. Homomorphic summation
-	sumCt := ciphertexts[0].CopyNew()
-	for _, ct := range ciphertexts[1:] {
-		evaluator.Add(sumCt, ct, sumCt) // sumCt += ct
+			runtime.GC()
+			var before runtime.MemStats
+			runtime.ReadMemStats(&before)
 
-	}
+			result, err := approach.sum(data)
+			if err != nil {
+				panic(err)
+			}
 
-	// 7. Decrypt
-	ptResult := decryptor.DecryptNew(sumCt)
-	result := make([]uint64, params.N())
-	encoder.DecodeUint(ptResult, result)
+			var after runtime.MemStats
+			runtime.ReadMemStats(&after)
 
-	// 8. Verification
-	var actualSum uint64
-	for _, v := range data {
-		actualSum += v
+			// 4. Verification
+			fmt.Fprintf(w, "%s\t%d\t%d\t%.1f\t%.1f\t%s\t%s\t%v\t\n", approach.name, n, result.Ciphertexts,
+				float64(result.CiphertextBytes)/1e6, float64(after.TotalAlloc-before.TotalAlloc)/1e6,
+				result.Encrypt.Round(time.Millisecond), result.Aggregate.Round(time.Millisecond), result.Sum == actualSum)
+		}
 	}
-
-	fmt.Println("=== BFV Summation Results ===")
-	fmt.Printf("Homomorphic sum: %d\n", result[0])
-	fmt.Printf("Actual sum:      %d\n", actualSum)
-	fmt.Printf("Match:           %v\n", result[0] == actualSum)
-	fmt.Printf("Number of adds:  %d\n", len(data)-1)
+	w.Flush()
 }
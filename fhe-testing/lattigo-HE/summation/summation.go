@@ -0,0 +1,133 @@
+/*
+
+Encrypted summation with and without SIMD packing
+The per-value approach encrypts every value into its own ciphertext and only uses the first slot.
+The packed approach fills all N slots of a ciphertext, adds the ciphertexts slot-wise and then adds up the slots
+with log2(N) rotations, which need Galois keys
+
+*/
+
+package summation
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ldsec/lattigo/v2/bfv"
+	"github.com/ldsec/lattigo/v2/rlwe"
+)
+
+// PN12QP109 with a plaintext modulus large enough for the sum of 10^6 values below 100
+// the modulus is a prime congruent 1 modulo 2N, so values can be encoded in slots
+var DefaultParameters = bfv.ParametersLiteral{
+	LogN:  12,
+	T:     0x8008001,
+	Q:     bfv.PN12QP109.Q,
+	P:     bfv.PN12QP109.P,
+	Sigma: rlwe.DefaultSigma,
+}
+
+type Summer struct {
+	Params    bfv.Parameters
+	encoder   bfv.Encoder
+	encryptor bfv.Encryptor
+	decryptor bfv.Decryptor
+	evaluator bfv.Evaluator
+}
+
+type Result struct {
+	Sum             uint64
+	Ciphertexts     int // number of ciphertexts the values were encrypted into
+	CiphertextBytes int // size of all these ciphertexts, as they would be stored or sent
+	Encrypt         time.Duration
+	Aggregate       time.Duration // adding the ciphertexts and, for packed values, the slots
+}
+
+func NewSummer(literal bfv.ParametersLiteral) (*Summer, error) {
+	params, err := bfv.NewParametersFromLiteral(literal)
+	if err != nil {
+		return nil, err
+	}
+	kgen := bfv.NewKeyGenerator(params)
+	sk, pk := kgen.GenKeyPair()
+
+	return &Summer{
+		Params:    params,
+		encoder:   bfv.NewEncoder(params),
+		encryptor: bfv.NewEncryptor(params, pk),
+		decryptor: bfv.NewDecryptor(params, sk),
+		evaluator: bfv.NewEvaluator(params, rlwe.EvaluationKey{Rtks: kgen.GenRotationKeysForInnerSum(sk)}),
+	}, nil
+}
+
+func (s *Summer) encrypt(values []uint64) *bfv.Ciphertext {
+	pt := bfv.NewPlaintext(s.Params)
+	s.encoder.EncodeUint(values, pt)
+	return s.encryptor.EncryptNew(pt)
+}
+
+func (s *Summer) decryptFirst(ct *bfv.Ciphertext) uint64 {
+	slots := make([]uint64, s.Params.N())
+	s.encoder.DecodeUint(s.decryptor.DecryptNew(ct), slots)
+	return slots[0]
+}
+
+// one ciphertext per value. The ciphertexts are added as soon as they are encrypted, so only one is kept in memory,
+// but all of them would have to be stored
+func (s *Summer) SumPerValue(values []uint64) (Result, error) {
+	if len(values) == 0 {
+		return Result{}, fmt.Errorf("no values to sum")
+	}
+	var result Result
+	var sum *bfv.Ciphertext
+	for _, v := range values {
+		start := time.Now()
+		ct := s.encrypt([]uint64{v})
+		result.Encrypt += time.Since(start)
+		result.Ciphertexts++
+		result.CiphertextBytes += ct.GetDataLen(true)
+
+		start = time.Now()
+		if sum == nil {
+			sum = ct
+		} else {
+			s.evaluator.Add(sum, ct, sum)
+		}
+		result.Aggregate += time.Since(start)
+	}
+	result.Sum = s.decryptFirst(sum)
+	return result, nil
+}
+
+// N values per ciphertext, the slot-wise sum is reduced to a single value with rotations
+func (s *Summer) SumPacked(values []uint64) (Result, error) {
+	if len(values) == 0 {
+		return Result{}, fmt.Errorf("no values to sum")
+	}
+	var result Result
+	var sum *bfv.Ciphertext
+	slots := s.Params.N()
+	for i := 0; i < len(values); i += slots {
+		start := time.Now()
+		ct := s.encrypt(values[i:min(i+slots, len(values))])
+		result.Encrypt += time.Since(start)
+		result.Ciphertexts++
+		result.CiphertextBytes += ct.GetDataLen(true)
+
+		start = time.Now()
+		if sum == nil {
+			sum = ct
+		} else {
+			s.evaluator.Add(sum, ct, sum)
+		}
+		result.Aggregate += time.Since(start)
+	}
+
+	start := time.Now()
+	total := bfv.NewCiphertext(s.Params, 1)
+	s.evaluator.InnerSum(sum, total)
+	result.Aggregate += time.Since(start)
+
+	result.Sum = s.decryptFirst(total)
+	return result, nil
+}
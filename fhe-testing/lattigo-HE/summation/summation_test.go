@@ -0,0 +1,89 @@
+package summation
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func randomValues(n int) []uint64 {
+	values := make([]uint64, n)
+	for i := range values {
+		values[i] = uint64(rand.Intn(100))
+	}
+	return values
+}
+
+func plainSum(values []uint64) uint64 {
+	var sum uint64
+	for _, v := range values {
+		sum += v
+	}
+	return sum
+}
+
+func TestSums(t *testing.T) {
+	s, err := NewSummer(DefaultParameters)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//packed sums over parts of a ciphertext, both rows of slots and several ciphertexts
+	for _, n := range []int{1, 1000, s.Params.N()/2 + 1, s.Params.N(), 3*s.Params.N() + 17} {
+		values := randomValues(n)
+		packed, err := s.SumPacked(values)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if packed.Sum != plainSum(values) {
+			t.Errorf("%d values: packed sum %d, want %d", n, packed.Sum, plainSum(values))
+		}
+		if want := (n + s.Params.N() - 1) / s.Params.N(); packed.Ciphertexts != want {
+			t.Errorf("%d values: %d packed ciphertexts, want %d", n, packed.Ciphertexts, want)
+		}
+	}
+
+	values := randomValues(500)
+	perValue, err := s.SumPerValue(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perValue.Sum != plainSum(values) || perValue.Ciphertexts != 500 {
+		t.Errorf("per value sum %d in %d ciphertexts, want %d in 500", perValue.Sum, perValue.Ciphertexts, plainSum(values))
+	}
+}
+
+// go test -bench . ./summation, the per value approach is only run up to 10^4 values, see the large command for more
+func BenchmarkSum(b *testing.B) {
+	s, err := NewSummer(DefaultParameters)
+	if err != nil {
+		b.Fatal(err)
+	}
+	approaches := []struct {
+		name  string
+		sum   func([]uint64) (Result, error)
+		limit int
+	}{
+		{"per-value", s.SumPerValue, 10000},
+		{"packed", s.SumPacked, 1000000},
+	}
+
+	for _, n := range []int{1000, 10000, 100000, 1000000} {
+		values := randomValues(n)
+		for _, approach := range approaches {
+			if n > approach.limit {
+				continue
+			}
+			b.Run(fmt.Sprintf("%s/%d", approach.name, n), func(b *testing.B) {
+				var result Result
+				for range b.N {
+					if result, err = approach.sum(values); err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.ReportMetric(float64(result.Ciphertexts), "ciphertexts")
+				b.ReportMetric(float64(result.CiphertextBytes), "ciphertext-bytes")
+			})
+		}
+	}
+}
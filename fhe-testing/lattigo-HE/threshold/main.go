@@ -3,69 +3,41 @@ package main
 import (
 	"fmt"
 	"math/rand"
-	"time"
 
-	"github.com/ldsec/lattigo/v2/bfv"
-	"github.com/ldsec/lattigo/v2/rlwe"
+	"main/comparison"
 )
 
 func main() {
-	// 1. Simplified parameters - fewer multiplications needed
-	params, err := bfv.NewParametersFromLiteral(bfv.PN12QP109)
+	// 1. Keys for 7 bit values, the comparison needs relinearization and rotation keys
+	c, err := comparison.NewContext(comparison.DefaultParameters, 7)
 	if err != nil {
 		panic(err)
 	}
 
-	// 2. Key generation
-	kgen := bfv.NewKeyGenerator(params)
-	sk := kgen.GenSecretKey()
-	pk := kgen.GenPublicKey(sk)
-
-	// 3. Create primitives
-	encoder := bfv.NewEncoder(params)
-	encryptor := bfv.NewEncryptor(params, pk)
-	decryptor := bfv.NewDecryptor(params, sk)
-	evaluator := bfv.NewEvaluator(params, rlwe.EvaluationKey{})
-
-	// 4. Generate dataset and threshold
-	rand.Seed(time.Now().UnixNano())
+	// 2. Generate dataset and threshold
 	data := make([]uint64, 10)
 	for i := range data {
 		data[i] = uint64(rand.Intn(100)) // 0-99
 	}
 	threshold := uint64(50)
 
-	// 5. Encrypt threshold
-	ptThreshold := bfv.NewPlaintext(params)
-	encoder.EncodeUint([]uint64{threshold}, ptThreshold)
-	ctThreshold := encryptor.EncryptNew(ptThreshold)
-
-	// 6. Initialize counter
-	ptZero := bfv.NewPlaintext(params)
-	encoder.EncodeUint([]uint64{0}, ptZero)
-	countCt := encryptor.EncryptNew(ptZero)
-
-	// 7. Process each number
-	for _, num := range data {
-		// Encrypt current number
-		ptNum := bfv.NewPlaintext(params)
-		encoder.EncodeUint([]uint64{num}, ptNum)
-		ctNum := encryptor.EncryptNew(ptNum)
-
-		// Compute difference: (num - threshold)
-		diff := evaluator.SubNew(ctNum, ctThreshold)
-
-		// Simplified comparison: if diff > 0, add 1 to count
-		// (In practice, you'd need proper comparison logic here)
-		evaluator.Add(countCt, diff, countCt)
+	// 3. Encrypt the dataset bit by bit, all values are packed into the slots of the ciphertexts
+	ctData, err := c.EncryptVector(data)
+	if err != nil {
+		panic(err)
+	}
+	ctThreshold, err := c.EncryptThreshold(threshold, len(data))
+	if err != nil {
+		panic(err)
 	}
 
-	// 8. Decrypt and scale the result
-	ptResult := decryptor.DecryptNew(countCt)
-	result := make([]uint64, params.N())
-	encoder.DecodeUint(ptResult, result)
+	// 4. Compare every value with the threshold and add up the results
+	countCt, err := c.CountAbove(ctData, ctThreshold)
+	if err != nil {
+		panic(err)
+	}
 
-	// 9. Verification
+	// 5. Verification
 	var actualCount uint64
 	for _, num := range data {
 		if num > threshold {
@@ -73,10 +45,8 @@ func main() {
 		}
 	}
 
-	// Print results
 	fmt.Println("Dataset:", data)
 	fmt.Printf("Threshold: %d\n", threshold)
-	fmt.Printf("Encrypted count > %d: %d\n", threshold, result[0]/100) // Scaling factor
+	fmt.Printf("Encrypted count > %d: %d\n", threshold, c.DecryptCount(countCt))
 	fmt.Printf("Actual count > %d:    %d\n", threshold, actualCount)
-	fmt.Println("Note: This uses simplified comparison for demonstration")
 }
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+
+	circl "github.com/cloudflare/circl/abe/cpabe/tkn20"
+)
+
+// CP-ABE, Tomida, Kawahara and Nishimaki, attributes are pairs of a name and a value
+type tkn20 struct {
+	pubKey circl.PublicKey
+	secKey circl.SystemSecretKey
+}
+
+func (t *tkn20) Name() string { return "circl_tkn20" }
+
+func (t *tkn20) Setup() (err error) {
+	t.pubKey, t.secKey, err = circl.Setup(rand.Reader)
+	return err
+}
+
+func (t *tkn20) KeyGen(_ Policy, attributes []int) (any, error) {
+	values := map[string]string{}
+	for _, a := range attributes {
+		values[fmt.Sprintf("attribute_%d", a)] = "true"
+	}
+	gamma := circl.Attributes{}
+	gamma.FromMap(values)
+	return t.secKey.KeyGen(rand.Reader, gamma)
+}
+
+func (t *tkn20) Encrypt(policy Policy, _ []int, msg string) (any, error) {
+	p := circl.Policy{}
+	if err := p.FromString("(" + policy.format("(attribute_%d: true)", " and ", " or ") + ")"); err != nil {
+		return nil, err
+	}
+	return t.pubKey.Encrypt(rand.Reader, p, []byte(msg))
+}
+
+func (t *tkn20) Decrypt(ciphertext any, key any) (string, error) {
+	k := key.(circl.AttributeKey)
+	msg, err := k.Decrypt(ciphertext.([]byte))
+	return string(msg), err
+}
+
+func (t *tkn20) Size(ciphertext any) (int, error) {
+	return len(ciphertext.([]byte)), nil
+}
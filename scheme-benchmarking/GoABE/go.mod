@@ -0,0 +1,15 @@
+module github.com/pzkt/abe-scripts/scheme-benchmarking/GoABE
+
+go 1.23.4
+
+require (
+	github.com/cloudflare/circl v1.6.1
+	github.com/fentec-project/gofe v0.0.0-20220829150550-ccc7482d20ef
+)
+
+require (
+	github.com/fentec-project/bn256 v0.0.0-20190726093940-0d0fc8bfeed0 // indirect
+	github.com/pkg/errors v0.9.1 // indirect
+	golang.org/x/crypto v0.11.1-0.20230711161743-2e82bdd1719d // indirect
+	golang.org/x/sys v0.10.0 // indirect
+)
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+
+	"github.com/fentec-project/gofe/abe"
+	"github.com/fentec-project/gofe/data"
+)
+
+// the size of the gob encoding, gofe has no other serialization of its ciphertexts
+func gobSize(s any) (int, error) {
+	var buffer bytes.Buffer
+	if err := gob.NewEncoder(&buffer).Encode(s); err != nil {
+		return 0, err
+	}
+	return buffer.Len(), nil
+}
+
+// CP-ABE, Agrawal and Chase
+type fame struct {
+	scheme *abe.FAME
+	pubKey *abe.FAMEPubKey
+	secKey *abe.FAMESecKey
+}
+
+func (f *fame) Name() string { return "gofe_fame" }
+
+func (f *fame) Setup() (err error) {
+	f.scheme = abe.NewFAME()
+	f.pubKey, f.secKey, err = f.scheme.GenerateMasterKeys()
+	return err
+}
+
+func (f *fame) KeyGen(_ Policy, attributes []int) (any, error) {
+	gamma := make([]string, len(attributes))
+	for i, a := range attributes {
+		gamma[i] = fmt.Sprintf("attribute_%d", a)
+	}
+	return f.scheme.GenerateAttribKeys(gamma, f.secKey)
+}
+
+func (f *fame) Encrypt(policy Policy, _ []int, msg string) (any, error) {
+	msp, err := abe.BooleanToMSP(policy.format("attribute_%d", " AND ", " OR "), false)
+	if err != nil {
+		return nil, err
+	}
+	return f.scheme.Encrypt(msg, msp, f.pubKey)
+}
+
+func (f *fame) Decrypt(ciphertext any, key any) (string, error) {
+	return f.scheme.Decrypt(ciphertext.(*abe.FAMECipher), key.(*abe.FAMEAttribKeys), f.pubKey)
+}
+
+func (f *fame) Size(ciphertext any) (int, error) {
+	return gobSize(ciphertext)
+}
+
+// KP-ABE, Goyal, Pandey, Sahai and Waters, the attributes are the numbers below the size of the universe
+type gpsw struct {
+	universe int
+	scheme   *abe.GPSW
+	pubKey   *abe.GPSWPubKey
+	secKey   data.Vector
+}
+
+func (g *gpsw) Name() string { return "gofe_gpsw" }
+
+func (g *gpsw) Setup() (err error) {
+	g.scheme = abe.NewGPSW(g.universe)
+	g.pubKey, g.secKey, err = g.scheme.GenerateMasterKeys()
+	return err
+}
+
+func (g *gpsw) KeyGen(policy Policy, _ []int) (any, error) {
+	msp, err := abe.BooleanToMSP(policy.format("%d", " AND ", " OR "), true)
+	if err != nil {
+		return nil, err
+	}
+	return g.scheme.GeneratePolicyKey(msp, g.secKey)
+}
+
+func (g *gpsw) Encrypt(_ Policy, attributes []int, msg string) (any, error) {
+	return g.scheme.Encrypt(msg, attributes, g.pubKey)
+}
+
+func (g *gpsw) Decrypt(ciphertext any, key any) (string, error) {
+	return g.scheme.Decrypt(ciphertext.(*abe.GPSWCipher), key.(*abe.GPSWKey))
+}
+
+func (g *gpsw) Size(ciphertext any) (int, error) {
+	return gobSize(ciphertext)
+}
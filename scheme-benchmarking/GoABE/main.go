@@ -0,0 +1,167 @@
+/*
+
+One benchmark driver for the Go ABE libraries
+Every scheme is measured with the same policies: the conjunction of all attributes, decrypted with a key for all of them,
+and the disjunction of all attributes, decrypted with a key for the last one. The results of all schemes go into a
+single CSV with one row per scheme, operation and number of attributes, together with the Go version, the CPU and the date
+With -storage the sizes of single and hybrid ciphertexts for plaintexts of up to 2^plaintexts bytes go into one table per scheme
+
+	go run . -schemes gofe_fame,circl_tkn20 -attributes 1,10,50 -iterations 20
+	go run . -schemes gofe_fame,circl_tkn20 -storage ../Results/storage -plaintexts 24
+
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// the message stands in for the symmetric key of hybrid encryption
+const message = "Svx7QqFWUqDJ6hOo4dByAGqmXOUNOeGP"
+
+type Result struct {
+	Scheme     string
+	Operation  string
+	Attributes int
+	Value      float64
+	Unit       string
+	Iterations int
+}
+
+// the mean time of f in seconds
+func measure(iterations int, f func() error) (float64, error) {
+	start := time.Now()
+	for range iterations {
+		if err := f(); err != nil {
+			return 0, err
+		}
+	}
+	return time.Since(start).Seconds() / float64(iterations), nil
+}
+
+func run(s Scheme, counts []int, iterations int) ([]Result, error) {
+	results := []Result{}
+	add := func(operation string, attributes int, value float64, unit string) {
+		results = append(results, Result{s.Name(), operation, attributes, value, unit, iterations})
+	}
+
+	setup, err := measure(iterations, s.Setup)
+	if err != nil {
+		return nil, fmt.Errorf("setup: %w", err)
+	}
+	add("setup", 0, setup, "s")
+
+	for _, n := range counts {
+		all := attributes(n)
+		keygen, err := measure(iterations, func() error {
+			_, err := s.KeyGen(Policy{all, true}, all)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("keygen with %d attributes: %w", n, err)
+		}
+		add("keygen", n, keygen, "s")
+
+		for _, c := range []struct {
+			name       string
+			policy     Policy
+			attributes []int
+		}{
+			{"and", Policy{all, true}, all},
+			{"or", Policy{all, false}, all[n-1:]},
+		} {
+			encrypt, err := measure(iterations, func() error {
+				_, err := s.Encrypt(c.policy, c.attributes, message)
+				return err
+			})
+			if err != nil {
+				return nil, fmt.Errorf("%s encrypt with %d attributes: %w", c.name, n, err)
+			}
+
+			ciphertext, err := s.Encrypt(c.policy, c.attributes, message)
+			if err != nil {
+				return nil, err
+			}
+			key, err := s.KeyGen(c.policy, c.attributes)
+			if err != nil {
+				return nil, err
+			}
+			decrypt, err := measure(iterations, func() error {
+				msg, err := s.Decrypt(ciphertext, key)
+				if err == nil && msg != message {
+					err = fmt.Errorf("decrypted %q", msg)
+				}
+				return err
+			})
+			if err != nil {
+				return nil, fmt.Errorf("%s decrypt with %d attributes: %w", c.name, n, err)
+			}
+			size, err := s.Size(ciphertext)
+			if err != nil {
+				return nil, err
+			}
+
+			add(c.name+" encrypt", n, encrypt, "s")
+			add(c.name+" decrypt", n, decrypt, "s")
+			add(c.name+" ciphertext", n, float64(size), "bytes")
+		}
+	}
+	return results, nil
+}
+
+func main() {
+	names := flag.String("schemes", "gofe_fame,gofe_gpsw,circl_tkn20", "comma separated schemes")
+	counts := flag.String("attributes", "1,5,10,15,20,25,30,35,40,45,50", "comma separated numbers of attributes")
+	iterations := flag.Int("iterations", 10, "runs averaged for every time")
+	out := flag.String("out", "../Results/go_abe.csv", "the CSV file for the results, rows of other schemes are kept")
+	storage := flag.String("storage", "", "folder for the ciphertext size tables, they are skipped without it")
+	plaintexts := flag.Int("plaintexts", 24, "the largest plaintext of the size tables has 2^plaintexts bytes")
+	flag.Parse()
+
+	attributeCounts := []int{}
+	for _, c := range strings.Split(*counts, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(c))
+		if err != nil || n < 1 {
+			log.Fatalf("invalid number of attributes %q", c)
+		}
+		attributeCounts = append(attributeCounts, n)
+	}
+
+	results := []Result{}
+	for _, name := range strings.Split(*names, ",") {
+		newScheme, found := schemes[name]
+		if !found {
+			log.Fatalf("unknown scheme %s", name)
+		}
+		//the universe of the KP-ABE scheme has to cover the largest policy
+		s := newScheme(slices.Max(attributeCounts))
+		log.Printf("benchmarking %s", name)
+		r, err := run(s, attributeCounts, *iterations)
+		if err != nil {
+			log.Fatalf("%s: %v", name, err)
+		}
+		results = append(results, r...)
+
+		if *storage != "" {
+			records, err := ciphertextSizes(s, attributeCounts, *plaintexts)
+			if err != nil {
+				log.Fatalf("%s: %v", name, err)
+			}
+			if err := writeSizes(*storage, s, records); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+
+	if err := writeResults(*out, results, currentMetadata()); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("wrote %d results to %s", len(results), *out)
+}
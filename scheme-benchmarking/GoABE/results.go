@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// the environment of a run, written next to every result so files of different machines can be concatenated
+type Metadata struct {
+	GoVersion string
+	Platform  string
+	CPU       string
+	Date      string
+}
+
+func currentMetadata() Metadata {
+	return Metadata{
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+		CPU:       cpuModel(),
+		Date:      time.Now().UTC().Format(time.RFC3339),
+	}
+}
+
+// the model name from /proc/cpuinfo, only the number of cores on other systems
+func cpuModel() string {
+	file, err := os.Open("/proc/cpuinfo")
+	if err == nil {
+		defer file.Close()
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			if key, value, found := strings.Cut(scanner.Text(), ":"); found && strings.TrimSpace(key) == "model name" {
+				return strings.TrimSpace(value)
+			}
+		}
+	}
+	return strconv.Itoa(runtime.NumCPU()) + " cores"
+}
+
+var header = []string{"scheme", "operation", "attributes", "value", "unit", "iterations", "go_version", "platform", "cpu", "date"}
+
+// the rows of schemes that are not in results are kept, so runs of single schemes add up to one file
+func writeResults(fileName string, results []Result, meta Metadata) error {
+	run := map[string]bool{}
+	for _, r := range results {
+		run[r.Scheme] = true
+	}
+
+	records := [][]string{header}
+	previous, err := readResults(fileName)
+	if err != nil {
+		return err
+	}
+	for _, record := range previous {
+		if !run[record[0]] {
+			records = append(records, record)
+		}
+	}
+
+	for _, r := range results {
+		records = append(records, []string{
+			r.Scheme,
+			r.Operation,
+			strconv.Itoa(r.Attributes),
+			strconv.FormatFloat(r.Value, 'g', -1, 64),
+			r.Unit,
+			strconv.Itoa(r.Iterations),
+			meta.GoVersion,
+			meta.Platform,
+			meta.CPU,
+			meta.Date,
+		})
+	}
+
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return csv.NewWriter(file).WriteAll(records)
+}
+
+// the rows of an earlier result file without its header, none if there is no file yet
+func readResults(fileName string) ([][]string, error) {
+	file, err := os.Open(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fileName, err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	if !slices.Equal(records[0], header) {
+		return nil, fmt.Errorf("%s has different columns, write the results to another file", fileName)
+	}
+	return records[1:], nil
+}
@@ -0,0 +1,59 @@
+/*
+
+A common interface for the Go ABE libraries
+Every adapter translates the benchmark policies and attributes into the syntax of its library. Attributes are numbered,
+the adapters name them attribute_0, attribute_1, ... or just by their number where the library needs integers.
+CP-ABE schemes attach the policy to the ciphertext and the attributes to the key, KP-ABE schemes do it the other way round,
+so key generation and encryption get both and every adapter uses the side its scheme needs
+
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// the conjunction or the disjunction of all attributes
+type Policy struct {
+	Attributes []int
+	And        bool
+}
+
+type Scheme interface {
+	Name() string
+	// new master keys, they replace the previous ones
+	Setup() error
+	KeyGen(policy Policy, attributes []int) (any, error)
+	Encrypt(policy Policy, attributes []int, msg string) (any, error)
+	Decrypt(ciphertext any, key any) (string, error)
+	// the size of a ciphertext as it would be stored or sent
+	Size(ciphertext any) (int, error)
+}
+
+// the policy in the syntax of a library, e.g. with attribute_%d and AND/OR
+func (p Policy) format(attribute string, and string, or string) string {
+	terms := make([]string, len(p.Attributes))
+	for i, a := range p.Attributes {
+		terms[i] = fmt.Sprintf(attribute, a)
+	}
+	if p.And {
+		return strings.Join(terms, and)
+	}
+	return strings.Join(terms, or)
+}
+
+func attributes(n int) []int {
+	out := make([]int, n)
+	for i := range out {
+		out[i] = i
+	}
+	return out
+}
+
+var schemes = map[string]func(universe int) Scheme{
+	"gofe_fame":   func(int) Scheme { return &fame{} },
+	"gofe_gpsw":   func(universe int) Scheme { return &gpsw{universe: universe} },
+	"circl_tkn20": func(int) Scheme { return &tkn20{} },
+}
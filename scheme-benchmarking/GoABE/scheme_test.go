@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestSchemes(t *testing.T) {
+	for name, newScheme := range schemes {
+		t.Run(name, func(t *testing.T) {
+			s := newScheme(4)
+			if err := s.Setup(); err != nil {
+				t.Fatal(err)
+			}
+			all := attributes(4)
+			and := Policy{all, true}
+
+			//the policy needs all four attributes but there are only three, on the key or on the ciphertext
+			ciphertext, err := s.Encrypt(and, all[:3], message)
+			if err != nil {
+				t.Fatal(err)
+			}
+			key, err := s.KeyGen(and, all[:3])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if msg, err := s.Decrypt(ciphertext, key); err == nil && msg == message {
+				t.Error("three of four attributes satisfied the AND policy")
+			}
+			if size, err := s.Size(ciphertext); err != nil || size == 0 {
+				t.Errorf("ciphertext size %d: %v", size, err)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	results, err := run(&fame{}, []int{1, 3}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//setup and 7 operations per number of attributes
+	if len(results) != 1+2*7 {
+		t.Errorf("got %d results, want %d", len(results), 1+2*7)
+	}
+
+	file := filepath.Join(t.TempDir(), "results.csv")
+	if err := writeResults(file, results, currentMetadata()); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != len(results)+1 || len(records[0]) != len(header) {
+		t.Errorf("got %d rows of %d columns", len(records), len(records[0]))
+	}
+}
+
+// a run of a single scheme replaces only its own rows
+func TestWriteResultsKeepsOtherSchemes(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "results.csv")
+	meta := currentMetadata()
+	for _, results := range [][]Result{
+		{{Scheme: "gofe_fame", Operation: "setup", Value: 1}, {Scheme: "circl_tkn20", Operation: "setup", Value: 2}},
+		{{Scheme: "gofe_fame", Operation: "setup", Value: 3}},
+	} {
+		if err := writeResults(file, results, meta); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	records, err := readResults(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := map[string]string{}
+	for _, r := range records {
+		values[r[0]] = r[3]
+	}
+	if len(records) != 2 || values["circl_tkn20"] != "2" || values["gofe_fame"] != "3" {
+		t.Errorf("unexpected rows %v", records)
+	}
+}
+
+func TestCiphertextSizes(t *testing.T) {
+	s := &tkn20{}
+	if err := s.Setup(); err != nil {
+		t.Fatal(err)
+	}
+	records, err := ciphertextSizes(s, []int{1, 3}, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || len(records[0]) != 1+2*5 || records[0][9] != "single 16" || records[2][0] != "3" {
+		t.Fatalf("unexpected table %v", records)
+	}
+	//tkn20 adds the same overhead to every plaintext, so the hybrid ciphertext is larger by the key and the nonce and tag of AES-GCM
+	single, _ := strconv.Atoi(records[1][9])
+	hybrid, _ := strconv.Atoi(records[1][10])
+	if hybrid-single != len(message)+12+16 {
+		t.Errorf("single %d and hybrid %d bytes for 16 byte plaintexts", single, hybrid)
+	}
+
+	folder := t.TempDir()
+	if err := writeSizes(folder, s, records); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(folder, "circl_tkn20_ct.csv")); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// the plaintext encrypted directly with ABE against the plaintext encrypted with AES-GCM under a key that is encrypted with ABE
+// the policy is the disjunction of all attributes, the plaintexts have 2^0 to 2^maxExponent bytes
+func ciphertextSizes(s Scheme, counts []int, maxExponent int) ([][]string, error) {
+	header := []string{"index"}
+	for e := 0; e <= maxExponent; e++ {
+		header = append(header, fmt.Sprintf("single %d", 1<<e), fmt.Sprintf("hybrid %d", 1<<e))
+	}
+	records := [][]string{header}
+
+	for _, n := range counts {
+		policy := Policy{attributes(n), false}
+		key, err := s.Encrypt(policy, policy.Attributes, message)
+		if err != nil {
+			return nil, err
+		}
+		keySize, err := s.Size(key)
+		if err != nil {
+			return nil, err
+		}
+
+		row := []string{strconv.Itoa(n)}
+		for e := 0; e <= maxExponent; e++ {
+			content := make([]byte, 1<<e)
+			rand.Read(content)
+
+			single, err := s.Encrypt(policy, policy.Attributes, string(content))
+			if err != nil {
+				return nil, fmt.Errorf("%d bytes with %d attributes: %w", len(content), n, err)
+			}
+			singleSize, err := s.Size(single)
+			if err != nil {
+				return nil, err
+			}
+			sealed, err := encryptAES([]byte(message), content)
+			if err != nil {
+				return nil, err
+			}
+			row = append(row, strconv.Itoa(singleSize), strconv.Itoa(len(sealed)+keySize))
+		}
+		records = append(records, row)
+	}
+	return records, nil
+}
+
+// the symmetric part of hybrid encryption, the nonce is stored in front of the ciphertext
+func encryptAES(key []byte, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// one <scheme>_ct.csv per scheme in the folder, in the layout of the other libraries in Results/storage
+func writeSizes(folder string, s Scheme, records [][]string) error {
+	file, err := os.Create(filepath.Join(folder, s.Name()+"_ct.csv"))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return csv.NewWriter(file).WriteAll(records)
+}